@@ -95,6 +95,22 @@ func registerAdminRoutes(r *gin.Engine, logger *zap.Logger) {
 		admin.GET("/keys/:key_id", getKey)
 		admin.PATCH("/keys/:key_id", updateKey)
 		admin.DELETE("/keys/:key_id", deleteKey)
+
+		admin.GET("/model-groups", listModelGroups)
+		admin.POST("/model-groups", createModelGroup)
+		admin.GET("/model-groups/:group_id", getModelGroup)
+		admin.PATCH("/model-groups/:group_id", updateModelGroup)
+		admin.DELETE("/model-groups/:group_id", deleteModelGroup)
+		admin.GET("/model-groups/:group_id/endpoints", listModelEndpoints)
+		admin.POST("/model-groups/:group_id/endpoints", createModelEndpoint)
+		admin.GET("/model-groups/:group_id/endpoints/:endpoint_id", getModelEndpoint)
+		admin.PATCH("/model-groups/:group_id/endpoints/:endpoint_id", updateModelEndpoint)
+		admin.DELETE("/model-groups/:group_id/endpoints/:endpoint_id", deleteModelEndpoint)
+
+		admin.POST("/config/reload", reloadModelConfig)
+
+		admin.GET("/spend", getSpendReport)
+		admin.GET("/spend/logs", listSpendLogs)
 	}
 }
 
@@ -310,26 +326,29 @@ func deleteOrganization(c *gin.Context) {
 }
 
 type teamDTO struct {
-	TeamID         int64            `gorm:"primaryKey;autoIncrement;column:team_id" json:"team_id"`
-	TeamName       string           `gorm:"column:team_name" json:"team_name"`
-	ModelList      auth.StringSlice `gorm:"column:model_list" json:"model_list"`
-	OrganizationID int64            `gorm:"column:organization_id" json:"organization_id"`
-	CreateTime     time.Time        `gorm:"column:create_time" json:"-"`
-	UpdateTime     time.Time        `gorm:"column:update_time" json:"-"`
+	TeamID          int64            `gorm:"primaryKey;autoIncrement;column:team_id" json:"team_id"`
+	TeamName        string           `gorm:"column:team_name" json:"team_name"`
+	ModelList       auth.StringSlice `gorm:"column:model_list" json:"model_list"`
+	OrganizationID  int64            `gorm:"column:organization_id" json:"organization_id"`
+	TokensPerMinute int              `gorm:"column:tokens_per_minute" json:"tokens_per_minute"`
+	CreateTime      time.Time        `gorm:"column:create_time" json:"-"`
+	UpdateTime      time.Time        `gorm:"column:update_time" json:"-"`
 }
 
 type teamRequest struct {
-	TeamName       string   `json:"team_name" binding:"required"`
-	AllModels      bool     `json:"all_models"`
-	ModelList      []string `json:"model_list"`
-	OrganizationID int64    `json:"organization_id" binding:"required"`
+	TeamName        string   `json:"team_name" binding:"required"`
+	AllModels       bool     `json:"all_models"`
+	ModelList       []string `json:"model_list"`
+	OrganizationID  int64    `json:"organization_id" binding:"required"`
+	TokensPerMinute int      `json:"tokens_per_minute"`
 }
 
 type teamPatchRequest struct {
-	TeamName       *string   `json:"team_name"`
-	AllModels      *bool     `json:"all_models"`
-	ModelList      *[]string `json:"model_list"`
-	OrganizationID *int64    `json:"organization_id"`
+	TeamName        *string   `json:"team_name"`
+	AllModels       *bool     `json:"all_models"`
+	ModelList       *[]string `json:"model_list"`
+	OrganizationID  *int64    `json:"organization_id"`
+	TokensPerMinute *int      `json:"tokens_per_minute"`
 }
 
 func listTeams(c *gin.Context) {
@@ -352,10 +371,14 @@ func createTeam(c *gin.Context) {
 	if !bindAdminJSON(c, &req) {
 		return
 	}
+	if !validateTokensPerMinute(c, req.TokensPerMinute) {
+		return
+	}
 	team := teamDTO{
-		TeamName:       strings.TrimSpace(req.TeamName),
-		ModelList:      normalizeModelList(req.ModelList, req.AllModels),
-		OrganizationID: req.OrganizationID,
+		TeamName:        strings.TrimSpace(req.TeamName),
+		ModelList:       normalizeModelList(req.ModelList, req.AllModels),
+		OrganizationID:  req.OrganizationID,
+		TokensPerMinute: req.TokensPerMinute,
 	}
 	if team.TeamName == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "team_name is required"})
@@ -425,6 +448,12 @@ func updateTeam(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "team organization_id cannot be updated"})
 		return
 	}
+	if req.TokensPerMinute != nil {
+		if !validateTokensPerMinute(c, *req.TokensPerMinute) {
+			return
+		}
+		updates["tokens_per_minute"] = *req.TokensPerMinute
+	}
 	if len(updates) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
 		return
@@ -484,6 +513,7 @@ type keyDTO struct {
 	TotalSpend        float64          `gorm:"column:total_spend" json:"total_spend"`
 	RequestPerMinute  int              `gorm:"column:request_per_minute" json:"request_per_minute"`
 	SpendLimitPerWeek float64          `gorm:"column:spend_limit_per_week" json:"spend_limit_per_week"`
+	TokensPerMinute   int              `gorm:"column:tokens_per_minute" json:"tokens_per_minute"`
 	CreateTime        time.Time        `gorm:"column:create_time" json:"-"`
 	UpdateTime        time.Time        `gorm:"column:update_time" json:"-"`
 	ExpireTime        *time.Time       `gorm:"column:expire_time" json:"expire_time"`
@@ -499,6 +529,7 @@ type keyRequest struct {
 	Balance           float64    `json:"balance"`
 	RequestPerMinute  int        `json:"request_per_minute"`
 	SpendLimitPerWeek float64    `json:"spend_limit_per_week"`
+	TokensPerMinute   int        `json:"tokens_per_minute"`
 	ExpireTime        *time.Time `json:"expire_time"`
 }
 
@@ -512,6 +543,7 @@ type keyPatchRequest struct {
 	Balance           *float64   `json:"balance"`
 	RequestPerMinute  *int       `json:"request_per_minute"`
 	SpendLimitPerWeek *float64   `json:"spend_limit_per_week"`
+	TokensPerMinute   *int       `json:"tokens_per_minute"`
 	ExpireTime        *time.Time `json:"expire_time"`
 }
 
@@ -575,6 +607,9 @@ func createKey(c *gin.Context) {
 	if !validateRequestPerMinute(c, req.RequestPerMinute) {
 		return
 	}
+	if !validateTokensPerMinute(c, req.TokensPerMinute) {
+		return
+	}
 
 	db, ok := connectAdminDB(c)
 	if !ok {
@@ -598,6 +633,7 @@ func createKey(c *gin.Context) {
 		TotalSpend:        0,
 		RequestPerMinute:  req.RequestPerMinute,
 		SpendLimitPerWeek: req.SpendLimitPerWeek,
+		TokensPerMinute:   req.TokensPerMinute,
 		ExpireTime:        req.ExpireTime,
 	}
 	if key.KeyName == "" {
@@ -693,6 +729,12 @@ func updateKey(c *gin.Context) {
 	if req.SpendLimitPerWeek != nil {
 		updates["spend_limit_per_week"] = *req.SpendLimitPerWeek
 	}
+	if req.TokensPerMinute != nil {
+		if !validateTokensPerMinute(c, *req.TokensPerMinute) {
+			return
+		}
+		updates["tokens_per_minute"] = *req.TokensPerMinute
+	}
 	if req.ExpireTime != nil {
 		updates["expire_time"] = *req.ExpireTime
 	}
@@ -790,6 +832,14 @@ func validateRequestPerMinute(c *gin.Context, rpm int) bool {
 	return true
 }
 
+func validateTokensPerMinute(c *gin.Context, tpm int) bool {
+	if tpm < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tokens_per_minute must be non-negative"})
+		return false
+	}
+	return true
+}
+
 func firstByID(c *gin.Context, query *gorm.DB, out interface{}) bool {
 	if err := query.First(out).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
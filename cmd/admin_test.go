@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/spend"
 )
 
 func TestValidateRequestPerMinuteRejectsNegativeValue(t *testing.T) {
@@ -46,3 +47,101 @@ func TestInvalidKeyResultRejectsStoredNegativeRPM(t *testing.T) {
 		t.Fatalf("unexpected error code: %q", result.ErrorCode)
 	}
 }
+
+func TestRequiresModelIncludesResponsesEndpoint(t *testing.T) {
+	if !requiresModel("/v1/responses") {
+		t.Fatal("expected /v1/responses to require a model")
+	}
+	if requiresModel("/v1/models") {
+		t.Fatal("expected /v1/models not to require a model")
+	}
+}
+
+func TestInvalidKeyResultRejectsWeeklySpendLimit(t *testing.T) {
+	previous := spend.WeeklySpend
+	spend.WeeklySpend = spend.NewWeeklySpendTracker()
+	defer func() { spend.WeeklySpend = previous }()
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	key := auth.Key{KeyId: 7, Balance: 100, SpendLimitPerWeek: 10}
+
+	spend.WeeklySpend.Seed(key.KeyId, 6, now)
+	if _, invalid := invalidKeyResult(key, now); invalid {
+		t.Fatal("expected key under weekly limit to be valid")
+	}
+
+	spend.WeeklySpend.Add(key.KeyId, 4, now)
+	result, invalid := invalidKeyResult(key, now)
+	if !invalid {
+		t.Fatal("expected key at weekly limit to be invalid")
+	}
+	if result.StatusCode != http.StatusTooManyRequests || result.ErrorCode != "weekly_spend_limit_exceeded" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if want := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC); !result.ResetAt.Equal(want) {
+		t.Fatalf("expected reset at %v, got %v", want, result.ResetAt)
+	}
+
+	if _, invalid := invalidKeyResult(key, now.AddDate(0, 0, 7)); invalid {
+		t.Fatal("expected weekly spend to reset in the next week")
+	}
+}
+
+func TestSyncWeeklySpendAddsUnflushedSpendToSpendLog(t *testing.T) {
+	previousWeekly, previousLoad, previousBalances := spend.WeeklySpend, loadWeeklySpend, spend.Balances
+	spend.WeeklySpend = spend.NewWeeklySpendTracker()
+	spend.Balances = spend.NewBalanceLedger()
+	defer func() {
+		spend.WeeklySpend, loadWeeklySpend, spend.Balances = previousWeekly, previousLoad, previousBalances
+	}()
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	key := auth.Key{KeyId: 7, Balance: 100, SpendLimitPerWeek: 10}
+	persisted := 3.0
+	loadWeeklySpend = func(keyID int, since time.Time) (float64, error) { return persisted, nil }
+
+	spend.WeeklySpend.Add(key.KeyId, 2, now)
+	spend.Balances.Charge(key.KeyId, 2)
+	if err := syncWeeklySpend(key, now); err != nil {
+		t.Fatalf("sync weekly spend: %v", err)
+	}
+	if got, _ := spend.WeeklySpend.Spend(key.KeyId, now); got != 5 {
+		t.Fatalf("expected spend log plus unflushed spend 5, got %v", got)
+	}
+
+	// Other replicas spent 4 more and ours was flushed.
+	persisted = 9
+	spend.Balances.Flushed(key.KeyId, 2)
+	if err := syncWeeklySpend(key, now); err != nil {
+		t.Fatalf("sync weekly spend: %v", err)
+	}
+	if got, _ := spend.WeeklySpend.Spend(key.KeyId, now); got != 9 {
+		t.Fatalf("expected re-seeded spend 9, got %v", got)
+	}
+}
+
+func TestModelGroupLabelFoldsUnconfiguredNames(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modelGroupMu.Lock()
+	previous := modelGroupSet
+	modelGroupSet = map[string]struct{}{"chat": {}}
+	modelGroupMu.Unlock()
+	defer func() {
+		modelGroupMu.Lock()
+		modelGroupSet = previous
+		modelGroupMu.Unlock()
+	}()
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if got := modelGroupLabel(ctx); got != "" {
+		t.Fatalf("expected empty label without a model, got %q", got)
+	}
+	ctx.Set("modelGroup", "chat")
+	if got := modelGroupLabel(ctx); got != "chat" {
+		t.Fatalf("expected configured group label, got %q", got)
+	}
+	ctx.Set("modelGroup", "made-up-12345")
+	if got := modelGroupLabel(ctx); got != unknownModelGroupLabel {
+		t.Fatalf("expected unconfigured name to be folded, got %q", got)
+	}
+}
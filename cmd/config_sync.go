@@ -27,22 +27,32 @@ type modelGroupRecord struct {
 	CostPerInputToken  float64 `gorm:"column:cost_per_input_token"`
 	CostPerOutputToken float64 `gorm:"column:cost_per_output_token"`
 	RequestDefaults    []byte  `gorm:"column:request_defaults"`
+	TokensPerMinute    int     `gorm:"column:tokens_per_minute"`
+	CacheTTLSeconds    int     `gorm:"column:cache_ttl_seconds"`
+	CacheHitCostRatio  float64 `gorm:"column:cache_hit_cost_ratio"`
+	RetryPolicy        []byte  `gorm:"column:retry_policy"`
+	Fallbacks          string  `gorm:"column:fallbacks"`
 	Enabled            bool    `gorm:"column:enabled"`
 }
 
 type modelEndpointRecord struct {
-	EndpointID        int64  `gorm:"column:endpoint_id"`
-	GroupID           int64  `gorm:"column:group_id"`
-	EndpointName      string `gorm:"column:endpoint_name"`
-	ProviderType      string `gorm:"column:provider_type"`
-	UpstreamModelName string `gorm:"column:upstream_model_name"`
-	BaseURL           string `gorm:"column:base_url"`
-	APIKeySecretRef   string `gorm:"column:api_key_secret_ref"`
-	Weight            int    `gorm:"column:weight"`
-	TimeoutSeconds    int    `gorm:"column:timeout_seconds"`
-	RetryTimes        int    `gorm:"column:retry_times"`
-	SkipTLSVerify     bool   `gorm:"column:skip_tls_verify"`
-	Enabled           bool   `gorm:"column:enabled"`
+	EndpointID         int64  `gorm:"column:endpoint_id"`
+	GroupID            int64  `gorm:"column:group_id"`
+	EndpointName       string `gorm:"column:endpoint_name"`
+	ProviderType       string `gorm:"column:provider_type"`
+	UpstreamModelName  string `gorm:"column:upstream_model_name"`
+	BaseURL            string `gorm:"column:base_url"`
+	APIKeySecretRef    string `gorm:"column:api_key_secret_ref"`
+	APIKeySecretRefs   string `gorm:"column:api_key_secret_refs"`
+	Weight             int    `gorm:"column:weight"`
+	TimeoutSeconds     int    `gorm:"column:timeout_seconds"`
+	ContextWindow      int    `gorm:"column:context_window"`
+	RetryTimes         int    `gorm:"column:retry_times"`
+	SkipTLSVerify      bool   `gorm:"column:skip_tls_verify"`
+	DisableStreamUsage bool   `gorm:"column:disable_stream_usage"`
+	HealthCheck        []byte `gorm:"column:health_check"`
+	Pricing            []byte `gorm:"column:pricing"`
+	Enabled            bool   `gorm:"column:enabled"`
 }
 
 type configSyncPlan struct {
@@ -143,6 +153,10 @@ func buildConfigSyncPlan(groups []models.ModelGroup, existingGroups []modelGroup
 		if err != nil {
 			return configSyncPlan{}, fmt.Errorf("marshal request_defaults for group %s: %w", groupName, err)
 		}
+		retryPolicy, err := marshalRetryPolicy(group.Retry)
+		if err != nil {
+			return configSyncPlan{}, fmt.Errorf("marshal retry for group %s: %w", groupName, err)
+		}
 
 		plan.Groups = append(plan.Groups, modelGroupRecord{
 			GroupName:          groupName,
@@ -150,6 +164,11 @@ func buildConfigSyncPlan(groups []models.ModelGroup, existingGroups []modelGroup
 			CostPerInputToken:  group.CostPerInputToken,
 			CostPerOutputToken: group.CostPerOutputToken,
 			RequestDefaults:    requestDefaults,
+			TokensPerMinute:    group.TokensPerMinute,
+			CacheTTLSeconds:    group.CacheTTLSeconds,
+			CacheHitCostRatio:  group.CacheHitCostRatio,
+			RetryPolicy:        retryPolicy,
+			Fallbacks:          joinTextList(group.Fallbacks),
 			Enabled:            true,
 		})
 
@@ -164,19 +183,33 @@ func buildConfigSyncPlan(groups []models.ModelGroup, existingGroups []modelGroup
 			}
 			desiredEndpoints[key] = struct{}{}
 
+			healthCheck, err := marshalHealthCheck(endpoint.HealthCheck)
+			if err != nil {
+				return configSyncPlan{}, fmt.Errorf("marshal health_check for endpoint %s/%s: %w", groupName, endpointName, err)
+			}
+			pricing, err := marshalPricing(endpoint.Pricing)
+			if err != nil {
+				return configSyncPlan{}, fmt.Errorf("marshal pricing for endpoint %s/%s: %w", groupName, endpointName, err)
+			}
+
 			plan.Endpoints = append(plan.Endpoints, plannedModelEndpoint{
 				GroupName: groupName,
 				modelEndpointRecord: modelEndpointRecord{
-					EndpointName:      endpointName,
-					ProviderType:      strings.TrimSpace(endpoint.Type),
-					UpstreamModelName: endpointName,
-					BaseURL:           strings.TrimSpace(endpoint.BaseURL),
-					APIKeySecretRef:   strings.TrimSpace(endpoint.APIKeySecretRef),
-					Weight:            normalizePositive(endpoint.Weight, defaultEndpointWeight),
-					TimeoutSeconds:    normalizePositive(endpoint.TimeoutSeconds, defaultEndpointTimeoutSeconds),
-					RetryTimes:        normalizeNonNegative(endpoint.RetryTimes, defaultEndpointRetryTimes),
-					SkipTLSVerify:     endpoint.SkipTLSVerify,
-					Enabled:           true,
+					EndpointName:       endpointName,
+					ProviderType:       strings.TrimSpace(endpoint.Type),
+					UpstreamModelName:  endpointName,
+					BaseURL:            strings.TrimSpace(endpoint.BaseURL),
+					APIKeySecretRef:    strings.TrimSpace(endpoint.APIKeySecretRef),
+					APIKeySecretRefs:   joinTextList(endpoint.APIKeySecretRefs),
+					Weight:             normalizePositive(endpoint.Weight, defaultEndpointWeight),
+					TimeoutSeconds:     normalizePositive(endpoint.TimeoutSeconds, defaultEndpointTimeoutSeconds),
+					ContextWindow:      normalizeNonNegative(endpoint.ContextWindow, 0),
+					RetryTimes:         normalizeNonNegative(endpoint.RetryTimes, defaultEndpointRetryTimes),
+					SkipTLSVerify:      endpoint.SkipTLSVerify,
+					DisableStreamUsage: endpoint.DisableStreamUsage,
+					HealthCheck:        healthCheck,
+					Pricing:            pricing,
+					Enabled:            true,
 				},
 			})
 		}
@@ -211,6 +244,11 @@ func applyModelGroupPlan(tx *gorm.DB, desired []modelGroupRecord, existing []mod
 			"cost_per_input_token":  group.CostPerInputToken,
 			"cost_per_output_token": group.CostPerOutputToken,
 			"request_defaults":      gorm.Expr("?::jsonb", string(group.RequestDefaults)),
+			"tokens_per_minute":     group.TokensPerMinute,
+			"cache_ttl_seconds":     group.CacheTTLSeconds,
+			"cache_hit_cost_ratio":  group.CacheHitCostRatio,
+			"retry_policy":          jsonbOrNull(group.RetryPolicy),
+			"fallbacks":             group.Fallbacks,
 			"enabled":               true,
 		}
 		if existingGroup, ok := existingByName[group.GroupName]; ok {
@@ -231,11 +269,17 @@ func applyModelGroupPlan(tx *gorm.DB, desired []modelGroupRecord, existing []mod
 				cost_per_input_token,
 				cost_per_output_token,
 				request_defaults,
+				tokens_per_minute,
+				cache_ttl_seconds,
+				cache_hit_cost_ratio,
+				retry_policy,
+				fallbacks,
 				enabled
 			)
-			VALUES (?, ?, ?, ?, ?::jsonb, TRUE)
+			VALUES (?, ?, ?, ?, ?::jsonb, ?, ?, ?, NULLIF(?, '')::jsonb, ?, TRUE)
 			RETURNING group_id
-		`, group.GroupName, group.Strategy, group.CostPerInputToken, group.CostPerOutputToken, string(group.RequestDefaults)).
+		`, group.GroupName, group.Strategy, group.CostPerInputToken, group.CostPerOutputToken, string(group.RequestDefaults),
+			group.TokensPerMinute, group.CacheTTLSeconds, group.CacheHitCostRatio, string(group.RetryPolicy), group.Fallbacks).
 			Scan(&newGroupID).Error; err != nil {
 			return nil, err
 		}
@@ -257,15 +301,20 @@ func applyModelEndpointPlan(tx *gorm.DB, desired []plannedModelEndpoint, existin
 		}
 		endpoint.GroupID = groupID
 		updates := map[string]interface{}{
-			"provider_type":       endpoint.ProviderType,
-			"upstream_model_name": endpoint.UpstreamModelName,
-			"base_url":            endpoint.BaseURL,
-			"api_key_secret_ref":  endpoint.APIKeySecretRef,
-			"weight":              endpoint.Weight,
-			"timeout_seconds":     endpoint.TimeoutSeconds,
-			"retry_times":         endpoint.RetryTimes,
-			"skip_tls_verify":     endpoint.SkipTLSVerify,
-			"enabled":             true,
+			"provider_type":        endpoint.ProviderType,
+			"upstream_model_name":  endpoint.UpstreamModelName,
+			"base_url":             endpoint.BaseURL,
+			"api_key_secret_ref":   endpoint.APIKeySecretRef,
+			"api_key_secret_refs":  endpoint.APIKeySecretRefs,
+			"weight":               endpoint.Weight,
+			"timeout_seconds":      endpoint.TimeoutSeconds,
+			"context_window":       endpoint.ContextWindow,
+			"retry_times":          endpoint.RetryTimes,
+			"skip_tls_verify":      endpoint.SkipTLSVerify,
+			"disable_stream_usage": endpoint.DisableStreamUsage,
+			"health_check":         jsonbOrNull(endpoint.HealthCheck),
+			"pricing":              jsonbOrNull(endpoint.Pricing),
+			"enabled":              true,
 		}
 
 		if existingEndpoint, ok := existingByKey[endpointDBKey{GroupID: groupID, EndpointName: endpoint.EndpointName}]; ok {
@@ -277,11 +326,9 @@ func applyModelEndpointPlan(tx *gorm.DB, desired []plannedModelEndpoint, existin
 			continue
 		}
 
-		newEndpoint := endpoint.modelEndpointRecord
-		newEndpoint.GroupID = groupID
-		if err := tx.Table("janus_model_endpoint").
-			Omit("endpoint_id", "create_time", "update_time").
-			Create(&newEndpoint).Error; err != nil {
+		updates["group_id"] = groupID
+		updates["endpoint_name"] = endpoint.EndpointName
+		if err := tx.Table("janus_model_endpoint").Create(updates).Error; err != nil {
 			return err
 		}
 	}
@@ -321,6 +368,62 @@ func marshalRequestDefaults(defaults map[string]interface{}) ([]byte, error) {
 	return json.Marshal(defaults)
 }
 
+func marshalHealthCheck(check *models.HealthCheckConfig) ([]byte, error) {
+	if check == nil {
+		return nil, nil
+	}
+	return json.Marshal(check)
+}
+
+func marshalPricing(pricing *models.Pricing) ([]byte, error) {
+	if pricing == nil {
+		return nil, nil
+	}
+	if err := pricing.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(pricing)
+}
+
+func marshalRetryPolicy(policy *models.RetryPolicy) ([]byte, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	return json.Marshal(policy)
+}
+
+// jsonbOrNull writes raw JSON into a JSONB column, or NULL when it is empty.
+func jsonbOrNull(raw []byte) interface{} {
+	if len(raw) == 0 {
+		return gorm.Expr("NULL")
+	}
+	return gorm.Expr("?::jsonb", string(raw))
+}
+
+// joinTextList stores a string list such as a secret ref pool in one TEXT column, like model_list.
+func joinTextList(values []string) string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+func splitTextList(joined string) []string {
+	if strings.TrimSpace(joined) == "" {
+		return nil
+	}
+	var values []string
+	for _, value := range strings.Split(joined, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func normalizeStrategy(strategy string) string {
 	return balancer.NormalizeStrategy(strategy)
 }
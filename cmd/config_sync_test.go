@@ -20,14 +20,15 @@ func TestBuildConfigSyncPlanUsesYAMLAsDesiredState(t *testing.T) {
 			},
 			Models: []models.ModelConfig{
 				{
-					Name:           "fast",
-					Type:           "openai",
-					BaseURL:        "https://fast.example/v1",
-					APIKey:         "plaintext-key-must-not-sync",
-					Weight:         10,
-					TimeoutSeconds: 30,
-					RetryTimes:     2,
-					SkipTLSVerify:  true,
+					Name:               "fast",
+					Type:               "openai",
+					BaseURL:            "https://fast.example/v1",
+					APIKey:             "plaintext-key-must-not-sync",
+					Weight:             10,
+					TimeoutSeconds:     30,
+					RetryTimes:         2,
+					SkipTLSVerify:      true,
+					DisableStreamUsage: true,
 				},
 				{
 					Name:            "safe",
@@ -80,7 +81,7 @@ func TestBuildConfigSyncPlanUsesYAMLAsDesiredState(t *testing.T) {
 	if fast.APIKeySecretRef != "" {
 		t.Fatalf("plaintext api_key should not be synced, got secret ref %q", fast.APIKeySecretRef)
 	}
-	if fast.Weight != 10 || fast.TimeoutSeconds != 30 || fast.RetryTimes != 2 || !fast.SkipTLSVerify {
+	if fast.Weight != 10 || fast.TimeoutSeconds != 30 || fast.RetryTimes != 2 || !fast.SkipTLSVerify || !fast.DisableStreamUsage {
 		t.Fatalf("unexpected fast endpoint settings: %+v", fast)
 	}
 	safe := plan.Endpoints[1]
@@ -2,27 +2,36 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/yaml.v3"
 
 	"github.com/Uuq114/JanusLLM/internal/auth"
 	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+	"github.com/Uuq114/JanusLLM/internal/metrics"
 	"github.com/Uuq114/JanusLLM/internal/models"
 	"github.com/Uuq114/JanusLLM/internal/proxy"
 	"github.com/Uuq114/JanusLLM/internal/request"
+	"github.com/Uuq114/JanusLLM/internal/secrets"
 	"github.com/Uuq114/JanusLLM/internal/spend"
+	"github.com/Uuq114/JanusLLM/internal/tracing"
 )
 
 var (
@@ -30,11 +39,24 @@ var (
 	mutex     sync.RWMutex
 
 	modelGroupSet = make(map[string]struct{})
+
+	loadWeeklySpend = spend.GetKeySpendSince
+	loadDailySpend  = spend.GetKeyDailySpend
+
+	spendPipeline *spend.Pipeline
 )
 
 const (
 	keyCacheSyncTTL = 1 * time.Minute
 	keyCacheIdleTTL = 30 * time.Minute
+	// spendSummaryReconcileTicks is how many background ticks pass between rebuilds of the
+	// recent days of janus_key_spend_daily.
+	spendSummaryReconcileTicks = 60
+
+	defaultSecretRefreshIntervalSeconds = 300
+
+	// shutdownTimeout bounds both in-flight requests and the spend drain on shutdown.
+	shutdownTimeout = 30 * time.Second
 )
 
 type cachedKey struct {
@@ -49,6 +71,7 @@ type keyValidationResult struct {
 	StatusCode   int
 	ErrorCode    string
 	ErrorMessage string
+	ResetAt      time.Time
 }
 
 func main() {
@@ -59,26 +82,82 @@ func main() {
 }
 
 func run() error {
-	config, err := loadJanusConfig("../config/config.yaml")
+	configPath, err := resolveConfigPath("../config/config.yaml")
 	if err != nil {
 		return fmt.Errorf("load config: %w", err)
 	}
+	config, err := loadJanusConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	janusDb.DatabaseDsn = config.Secrets.DatabaseURL
 	logger := buildLogger(config.Service.LogLevel)
 	defer logger.Sync()
 
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing)
+	if err != nil {
+		return fmt.Errorf("configure tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdownTracing(ctx)
+	}()
+
 	if err := syncMasterAdminUser(config.Admin, logger); err != nil {
 		return fmt.Errorf("sync master admin user: %w", err)
 	}
-	if err := syncConfigToDB(config, logger); err != nil {
-		return fmt.Errorf("sync model config: %w", err)
+
+	if err := configureBalanceBackend(config.Service.BalanceBackend); err != nil {
+		return err
+	}
+	logger.Info("Configured balance backend", zap.String("backend", config.Service.BalanceBackend))
+	if err := configureRateLimitBackend(config.Service.RateLimitBackend, config.Service.RateLimitFallback); err != nil {
+		return fmt.Errorf("configure rate limit backend: %w", err)
+	}
+	logger.Info("Configured rate limit backend",
+		zap.String("backend", config.Service.RateLimitBackend),
+		zap.String("fallback", config.Service.RateLimitFallback),
+	)
+
+	pipeline, err := spend.NewPipeline(config.Spend, spend.NewPostgresSpendStore())
+	if err != nil {
+		return fmt.Errorf("open spend pipeline: %w", err)
+	}
+	spendPipeline = pipeline
+	go pipeline.Run()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		pipeline.Close(ctx)
+		records, _ := pipeline.Backlog()
+		logger.Info("Stopped spend pipeline", zap.Int("unwritten_records", records))
+	}()
+	if records, size := pipeline.Backlog(); records > 0 {
+		logger.Info("Replaying spooled spend records", zap.Int("records", records), zap.Int64("bytes", size))
+	}
+
+	resolver := secrets.NewRegistry()
+	resolver.Register("vault", secrets.NewVaultResolver(config.Secrets.Vault))
+	secretResolver = resolver
+	if config.Secrets.RefreshIntervalSeconds > 0 {
+		secretRefreshInterval = time.Duration(config.Secrets.RefreshIntervalSeconds) * time.Second
 	}
 
 	p := proxy.NewProxy()
-	for _, group := range config.Models.ModelGroups {
-		p.RegisterModelGroup(&group)
-		modelGroupSet[group.Name] = struct{}{}
-		logger.Info("Registered model group", zap.String("name", group.Name))
+	modelSource = config.Models.Source
+	if modelSource == modelSourceDatabase && len(config.Models.ModelGroups) > 0 {
+		logger.Warn("models.source is database; model groups in config.yaml are ignored")
+	}
+	pollInterval := time.Duration(config.Models.PollIntervalSeconds) * time.Second
+	activeConfigReloader = newConfigReloader(configPath, modelSource, pollInterval, p, logger)
+	defer activeConfigReloader.Stop()
+	if _, err := activeConfigReloader.Reload("startup"); err != nil {
+		return fmt.Errorf("load model config: %w", err)
 	}
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	go activeConfigReloader.Watch(stopWatching)
 
 	r := gin.Default()
 	go startBackgroundTasks(logger)
@@ -86,10 +165,13 @@ func run() error {
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "pong"})
 	})
+	registerMetrics(r)
 	registerSwaggerRoutes(r)
 	registerAdminRoutes(r, logger)
 
 	api := r.Group("/v1")
+	api.Use(tracingMiddleware())
+	api.Use(metricsMiddleware())
 	api.Use(logReqHeadersMiddleware(logger))
 	api.Use(checkKeyMiddleware(logger))
 	api.Use(logSpendMiddleware(logger))
@@ -98,11 +180,38 @@ func run() error {
 		api.POST("/completions", p.HandleRequest)
 		api.POST("/embeddings", p.HandleRequest)
 		api.POST("/messages", p.HandleRequest)
+		api.POST("/responses", p.HandleRequest)
 		api.GET("/models", p.HandleListModels)
+		api.GET("/key/info", getKeyInfo(p))
+		api.GET("/usage", getKeyUsage(p))
 	}
 
-	if err := r.Run(":" + strconv.Itoa(config.Service.Port)); err != nil {
+	return serve(r, config.Service.Port, logger)
+}
+
+// serve runs the server until SIGINT or SIGTERM, then lets in-flight requests finish so their
+// spend is recorded before the caller drains the spend pipeline.
+func serve(handler http.Handler, port int, logger *zap.Logger) error {
+	server := &http.Server{Addr: ":" + strconv.Itoa(port), Handler: handler}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
 		return fmt.Errorf("start server: %w", err)
+	case sig := <-stop:
+		logger.Info("Shutting down", zap.String("signal", sig.String()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shut down server: %w", err)
 	}
 	return nil
 }
@@ -110,14 +219,31 @@ func run() error {
 type ServiceConfig struct {
 	Port     int    `yaml:"port"`
 	LogLevel string `yaml:"log_level"`
+	// RateLimitBackend is "memory" (default, per process) or "postgres" (shared by replicas).
+	RateLimitBackend string `yaml:"rate_limit_backend"`
+	// RateLimitFallback is what the postgres backend does while the database is unreachable:
+	// "local" (default, per-process limits), "allow" (fail open) or "deny" (fail closed).
+	RateLimitFallback string `yaml:"rate_limit_fallback"`
+	// BalanceBackend holds balance for in-flight requests: "memory" (default, per process) or
+	// "postgres" (shared by replicas).
+	BalanceBackend string `yaml:"balance_backend"`
 }
 
 type ModelsConfig struct {
-	ModelGroups []models.ModelGroup `yaml:"model_groups"`
+	// Source is "yaml" (default) to route from model_groups below, or "database" to route from
+	// the model tables managed through /v1/admin/model-groups.
+	Source string `yaml:"source"`
+	// PollIntervalSeconds is how often the database source is checked for changes.
+	PollIntervalSeconds int                 `yaml:"poll_interval_seconds"`
+	ModelGroups         []models.ModelGroup `yaml:"model_groups"`
 }
 
 type SecretsConfig struct {
 	DatabaseURL string `yaml:"database_url"`
+	// RefreshIntervalSeconds is how often api_key_secret_ref values are re-resolved; a negative
+	// value disables refreshing.
+	RefreshIntervalSeconds int                 `yaml:"refresh_interval_seconds"`
+	Vault                  secrets.VaultConfig `yaml:"vault"`
 }
 
 type AdminConfig struct {
@@ -125,26 +251,30 @@ type AdminConfig struct {
 }
 
 type JanusConfig struct {
-	Service ServiceConfig `yaml:"service"`
-	Models  ModelsConfig  `yaml:"models"`
-	Secrets SecretsConfig `yaml:"secrets"`
-	Admin   AdminConfig   `yaml:"admin"`
+	Service ServiceConfig        `yaml:"service"`
+	Models  ModelsConfig         `yaml:"models"`
+	Secrets SecretsConfig        `yaml:"secrets"`
+	Admin   AdminConfig          `yaml:"admin"`
+	Tracing tracing.Config       `yaml:"tracing"`
+	Spend   spend.PipelineConfig `yaml:"spend"`
 
 	LegacyModelGroups []models.ModelGroup `yaml:"model_groups"`
 	LegacyDatabaseURL string              `yaml:"database_url"`
 }
 
-func loadJanusConfig(path string) (*JanusConfig, error) {
-	paths := []string{path, "config/config.yaml"}
-	var file []byte
+// resolveConfigPath returns path, or config/config.yaml when path does not exist.
+func resolveConfigPath(path string) (string, error) {
 	var err error
-
-	for _, configPath := range paths {
-		file, err = os.ReadFile(configPath)
-		if err == nil {
-			break
+	for _, configPath := range []string{path, "config/config.yaml"} {
+		if _, err = os.Stat(configPath); err == nil {
+			return configPath, nil
 		}
 	}
+	return "", err
+}
+
+func loadJanusConfig(path string) (*JanusConfig, error) {
+	file, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -167,6 +297,48 @@ func loadJanusConfig(path string) (*JanusConfig, error) {
 	if config.Service.LogLevel == "" {
 		config.Service.LogLevel = "info"
 	}
+	config.Service.RateLimitBackend = strings.ToLower(strings.TrimSpace(config.Service.RateLimitBackend))
+	if config.Service.RateLimitBackend == "" {
+		config.Service.RateLimitBackend = "memory"
+	}
+	config.Service.RateLimitFallback = strings.ToLower(strings.TrimSpace(config.Service.RateLimitFallback))
+	if config.Service.RateLimitFallback == "" {
+		config.Service.RateLimitFallback = proxy.LimiterFallbackLocal
+	}
+	config.Service.BalanceBackend = strings.ToLower(strings.TrimSpace(config.Service.BalanceBackend))
+	if config.Service.BalanceBackend == "" {
+		config.Service.BalanceBackend = "memory"
+	}
+	config.Models.Source = strings.ToLower(strings.TrimSpace(config.Models.Source))
+	switch config.Models.Source {
+	case "":
+		config.Models.Source = modelSourceYAML
+	case modelSourceYAML, modelSourceDatabase:
+	default:
+		return nil, fmt.Errorf("unsupported models.source %q", config.Models.Source)
+	}
+	for _, group := range config.Models.ModelGroups {
+		if err := proxy.ValidateRetryPolicy(group.Retry); err != nil {
+			return nil, fmt.Errorf("model group %s retry: %w", group.Name, err)
+		}
+		for _, endpoint := range group.Models {
+			if endpoint.Pricing == nil {
+				continue
+			}
+			if err := endpoint.Pricing.Validate(); err != nil {
+				return nil, fmt.Errorf("model group %s endpoint %s: %w", group.Name, endpoint.Name, err)
+			}
+		}
+	}
+	if err := proxy.ValidateFallbacks(config.Models.ModelGroups); err != nil {
+		return nil, err
+	}
+	if config.Models.PollIntervalSeconds <= 0 {
+		config.Models.PollIntervalSeconds = defaultModelPollIntervalSeconds
+	}
+	if config.Secrets.RefreshIntervalSeconds == 0 {
+		config.Secrets.RefreshIntervalSeconds = defaultSecretRefreshIntervalSeconds
+	}
 
 	if dbURL := strings.TrimSpace(os.Getenv("JANUS_DATABASE_URL")); dbURL != "" {
 		config.Secrets.DatabaseURL = dbURL
@@ -180,12 +352,35 @@ func loadJanusConfig(path string) (*JanusConfig, error) {
 	if strings.TrimSpace(config.Admin.MasterKey) == "" {
 		return nil, errors.New("admin.master_key is empty; set admin.master_key or JANUS_ADMIN_MASTER_KEY")
 	}
+	return &config, nil
+}
 
-	janusDb.DatabaseDsn = config.Secrets.DatabaseURL
-	for _, group := range config.Models.ModelGroups {
-		spend.ModelPrice[group.Name] = []float64{group.CostPerInputToken, group.CostPerOutputToken}
+func configureRateLimitBackend(name string, fallback string) error {
+	switch name {
+	case "", "memory":
+		proxy.SetLimiterBackend(proxy.NewMemoryLimiterBackend())
+	case "postgres":
+		backend, err := proxy.NewPostgresLimiterBackend(fallback)
+		if err != nil {
+			return err
+		}
+		proxy.SetLimiterBackend(backend)
+	default:
+		return fmt.Errorf("unsupported rate_limit_backend %q", name)
 	}
-	return &config, nil
+	return nil
+}
+
+func configureBalanceBackend(name string) error {
+	switch name {
+	case "", "memory":
+		spend.SetBalanceBackend(spend.Balances)
+	case "postgres":
+		spend.SetBalanceBackend(spend.NewPostgresBalanceBackend())
+	default:
+		return fmt.Errorf("unsupported balance_backend %q", name)
+	}
+	return nil
 }
 
 func buildLogger(level string) *zap.Logger {
@@ -210,6 +405,8 @@ func buildLogger(level string) *zap.Logger {
 
 func logReqHeadersMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		endSpan := middlewareSpan(c, "janus.middleware.read_request")
+		defer endSpan()
 		c.Set("logger", logger)
 		c.Set("requestStart", time.Now())
 
@@ -256,10 +453,108 @@ func logReqHeadersMiddleware(logger *zap.Logger) gin.HandlerFunc {
 			zap.Any("headers", headers),
 			zap.String("model", modelName),
 		)
+		endSpan()
+		c.Next()
+	}
+}
+
+// registerMetrics exposes Prometheus metrics and the gauges sampled from in-process state.
+func registerMetrics(r *gin.Engine) {
+	metrics.RegisterGauge("spend_queue_depth", "Spend records waiting to be written to the database.", func() float64 {
+		if spendPipeline == nil {
+			return 0
+		}
+		records, _ := spendPipeline.Backlog()
+		return float64(records)
+	})
+	metrics.RegisterGauge("spend_spool_bytes", "Size of the on-disk spend spool not yet written to the database.", func() float64 {
+		if spendPipeline == nil {
+			return 0
+		}
+		_, size := spendPipeline.Backlog()
+		return float64(size)
+	})
+	metrics.RegisterGauge("key_cache_size", "API keys held in the in-memory key cache.", func() float64 {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		return float64(len(validKeys))
+	})
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+}
+
+// metricsMiddleware records every /v1 request, including ones rejected before proxying.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		metrics.ObserveRequest(
+			modelGroupLabel(c),
+			stringContext(c, spend.ContextUpstream),
+			stringContext(c, spend.ContextProvider),
+			c.Writer.Status(),
+			isStreamRequestContext(c),
+			time.Since(start),
+		)
+	}
+}
+
+// unknownModelGroupLabel stands in for model names that are not configured.
+const unknownModelGroupLabel = "unknown"
+
+// modelGroupLabel returns the request's model group for metric labels and span attributes.
+// The name comes from the request body before any validation, so names that are not
+// configured are folded into one value; otherwise any client could mint new series.
+func modelGroupLabel(c *gin.Context) string {
+	name := stringContext(c, "modelGroup")
+	if name == "" || modelGroupConfigured(name) {
+		return name
+	}
+	return unknownModelGroupLabel
+}
+
+// tracingMiddleware opens the server span for a /v1 request, continuing the caller's trace
+// when a traceparent header is present.
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, "janus.request",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", c.FullPath()),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", status),
+			attribute.String("janus.model_group", modelGroupLabel(c)),
+			attribute.String("janus.upstream", stringContext(c, spend.ContextUpstream)),
+			attribute.Bool("janus.stream", isStreamRequestContext(c)),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
 	}
 }
 
+// middlewareSpan traces the work a middleware does itself. Call the returned function before
+// c.Next() so the span excludes later handlers; calling it again is a no-op.
+func middlewareSpan(c *gin.Context, name string) func() {
+	_, span := tracing.Start(c.Request.Context(), name)
+	return sync.OnceFunc(func() {
+		if c.IsAborted() {
+			span.SetAttributes(attribute.Int("http.response.status_code", c.Writer.Status()))
+			span.SetStatus(codes.Error, "request rejected")
+		}
+		span.End()
+	})
+}
+
 func ioReadAll(c *gin.Context) ([]byte, error) {
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -271,6 +566,8 @@ func ioReadAll(c *gin.Context) ([]byte, error) {
 
 func checkKeyMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		endSpan := middlewareSpan(c, "janus.middleware.check_key")
+		defer endSpan()
 		keyContent := c.Request.Header.Get("Authorization")
 		if keyContent == "" {
 			respondAPIError(c, http.StatusUnauthorized, "missing_authorization_header", "no authorization header")
@@ -287,6 +584,19 @@ func checkKeyMiddleware(logger *zap.Logger) gin.HandlerFunc {
 			return
 		}
 		if !result.Valid {
+			if result.ErrorCode == "weekly_spend_limit_exceeded" {
+				metrics.IncRateLimitRejection("weekly_spend")
+			}
+			if !result.ResetAt.IsZero() {
+				c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(time.Until(result.ResetAt))))
+				c.JSON(result.StatusCode, gin.H{
+					"code":     result.ErrorCode,
+					"error":    result.ErrorMessage,
+					"reset_at": result.ResetAt.UTC().Format(time.RFC3339),
+				})
+				c.Abort()
+				return
+			}
 			respondAPIError(c, result.StatusCode, result.ErrorCode, result.ErrorMessage)
 			c.Abort()
 			return
@@ -298,12 +608,9 @@ func checkKeyMiddleware(logger *zap.Logger) gin.HandlerFunc {
 			if ring != nil {
 				allowed, retryAfter := ring.AllowAt(time.Now())
 				if !allowed {
+					metrics.IncRateLimitRejection("rpm")
 					if retryAfter > 0 {
-						retryAfterSeconds := int((retryAfter + time.Second - 1) / time.Second)
-						if retryAfterSeconds < 1 {
-							retryAfterSeconds = 1
-						}
-						c.Header("Retry-After", strconv.Itoa(retryAfterSeconds))
+						c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
 					}
 					respondAPIError(c, http.StatusTooManyRequests, "rate_limit_exceeded", "reach rate limit")
 					c.Abort()
@@ -313,12 +620,13 @@ func checkKeyMiddleware(logger *zap.Logger) gin.HandlerFunc {
 		}
 
 		c.Set("key", keyInfo)
-		if c.Request.URL.Path == "/v1/models" {
+		if !requiresModel(c.Request.URL.Path) {
 			logger.Info("Key authorized",
 				zap.String("key name", keyInfo.KeyName),
 				zap.Int("team id", keyInfo.TeamId),
 				zap.Int("organization id", keyInfo.OrganizationId),
 			)
+			endSpan()
 			c.Next()
 			return
 		}
@@ -342,6 +650,7 @@ func checkKeyMiddleware(logger *zap.Logger) gin.HandlerFunc {
 			zap.Int("team id", keyInfo.TeamId),
 			zap.Int("organization id", keyInfo.OrganizationId),
 		)
+		endSpan()
 		c.Next()
 	}
 }
@@ -373,6 +682,10 @@ func getValidKeyForRequest(keyContent string, now time.Time) (keyValidationResul
 			ErrorMessage: "invalid authorization key",
 		}, nil
 	}
+	if err := ensureWeeklySpendSeeded(*loaded, now); err != nil {
+		return keyValidationResult{}, err
+	}
+	spend.Balances.Sync(loaded.KeyId, loaded.Balance)
 	if failure, invalid := invalidKeyResult(*loaded, now); invalid {
 		deleteCachedKey(keyContent)
 		proxy.RemoveRequestRing(keyContent)
@@ -384,6 +697,31 @@ func getValidKeyForRequest(keyContent string, now time.Time) (keyValidationResul
 	return keyValidationResult{Key: effective, Valid: true}, nil
 }
 
+// ensureWeeklySpendSeeded loads the key's spend for the current week from the spend log
+// the first time it is seen each week, so that the limit survives restarts.
+func ensureWeeklySpendSeeded(key auth.Key, now time.Time) error {
+	if key.SpendLimitPerWeek <= 0 || spend.WeeklySpend.Seeded(key.KeyId, now) {
+		return nil
+	}
+	return syncWeeklySpend(key, now)
+}
+
+// syncWeeklySpend re-seeds the key's weekly spend from the spend log, which holds what every
+// replica has flushed, plus this replica's spend still waiting to be flushed. Unflushed spend
+// is read first so that a flush during the query is counted twice rather than not at all.
+func syncWeeklySpend(key auth.Key, now time.Time) error {
+	if key.SpendLimitPerWeek <= 0 {
+		return nil
+	}
+	unflushed := spend.Balances.Unflushed(key.KeyId)
+	total, err := loadWeeklySpend(key.KeyId, spend.WeekStart(now))
+	if err != nil {
+		return err
+	}
+	spend.WeeklySpend.Seed(key.KeyId, total+unflushed, now)
+	return nil
+}
+
 func getCachedKey(keyContent string) (cachedKey, bool) {
 	mutex.RLock()
 	defer mutex.RUnlock()
@@ -445,7 +783,7 @@ func isKeyLocallyValid(key auth.Key, now time.Time) bool {
 func resolveModelGroup(c *gin.Context) (string, error) {
 	if modelValue, ok := c.Get("modelGroup"); ok {
 		if modelName, ok := modelValue.(string); ok && modelName != "" {
-			if _, exists := modelGroupSet[modelName]; !exists {
+			if !modelGroupConfigured(modelName) {
 				return "", errors.New("model group not configured")
 			}
 			return modelName, nil
@@ -457,8 +795,12 @@ func resolveModelGroup(c *gin.Context) (string, error) {
 func logSpendMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
+		endSpan := middlewareSpan(c, "janus.middleware.log_spend")
+		defer endSpan()
+		// Runs after the spend below is recorded, or releases the hold when there is none.
+		defer spend.SettleBalanceHold(c)
 
-		if c.Request.URL.Path == "/v1/models" {
+		if !requiresModel(c.Request.URL.Path) {
 			return
 		}
 
@@ -477,10 +819,14 @@ func logSpendMiddleware(logger *zap.Logger) gin.HandlerFunc {
 			return
 		}
 
-		spend.CreateSpendRecord(c, proxy.SpendLogQueue)
+		if spendPipeline == nil {
+			return
+		}
+		spend.CreateSpendRecord(c, spendPipeline)
 		keyInfo := key.(auth.Key)
-		keySpendQueue := proxy.GetOrCreateKeySpendQueue(keyInfo.KeyId)
-		spend.CreateKeySpendRecord(c, keySpendQueue)
+		if amount, ok := spend.SpendFromContext(c); ok {
+			spend.WeeklySpend.Add(keyInfo.KeyId, amount, time.Now())
+		}
 
 		if start, ok := c.Get("requestStart"); ok {
 			if startTime, ok := start.(time.Time); ok {
@@ -513,7 +859,7 @@ func stringContext(c *gin.Context, key string) string {
 
 func requiresModel(path string) bool {
 	switch path {
-	case "/v1/chat/completions", "/v1/completions", "/v1/embeddings", "/v1/messages":
+	case "/v1/chat/completions", "/v1/completions", "/v1/embeddings", "/v1/messages", "/v1/responses":
 		return true
 	default:
 		return false
@@ -562,9 +908,27 @@ func invalidKeyResult(key auth.Key, now time.Time) (keyValidationResult, bool) {
 			ErrorMessage: "authorization key expired",
 		}, true
 	}
+	if key.SpendLimitPerWeek > 0 {
+		if weekly, _ := spend.WeeklySpend.Spend(key.KeyId, now); weekly >= key.SpendLimitPerWeek {
+			return keyValidationResult{
+				StatusCode:   http.StatusTooManyRequests,
+				ErrorCode:    "weekly_spend_limit_exceeded",
+				ErrorMessage: "authorization key weekly spend limit exceeded",
+				ResetAt:      spend.NextWeekStart(now),
+			}, true
+		}
+	}
 	return keyValidationResult{}, false
 }
 
+func retryAfterSeconds(wait time.Duration) int {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
 func respondAPIError(c *gin.Context, statusCode int, code string, message string) {
 	c.JSON(statusCode, gin.H{
 		"code":  code,
@@ -578,11 +942,11 @@ func startBackgroundTasks(logger *zap.Logger) {
 
 	logger.Info("Starting background tasks")
 
-	for range ticker.C {
+	for tick := 1; ; tick++ {
+		<-ticker.C
 		logger.Info("Performing background task")
 		refreshCachedKeys(logger)
-		go FlushSpendLog(logger, proxy.SpendLogQueue)
-		go FlushKeySpend(logger, proxy.SnapshotKeySpendQueue())
+		refreshSpendSummary(logger, tick%spendSummaryReconcileTicks == 0)
 	}
 }
 
@@ -593,6 +957,7 @@ func refreshCachedKeys(logger *zap.Logger) {
 		if !keyInfo.LastAccessAt.IsZero() && now.Sub(keyInfo.LastAccessAt) > keyCacheIdleTTL {
 			deleteCachedKey(keyContent)
 			proxy.RemoveRequestRing(keyContent)
+			spend.Balances.Remove(keyInfo.Key.KeyId)
 			logger.Info("Evicted idle key cache entry", zap.String("key", auth.RedactKeyContent(keyContent)))
 			continue
 		}
@@ -613,61 +978,10 @@ func refreshCachedKeys(logger *zap.Logger) {
 			continue
 		}
 
-		upsertCachedKey(applyEffectiveModelPermissions(*latest), now, keyInfo.LastAccessAt)
-	}
-}
-
-func FlushSpendLog(logger *zap.Logger, ch <-chan spend.SpendRecord) {
-	var batch []spend.SpendRecord
-	for {
-		select {
-		case logRecord, ok := <-ch:
-			if !ok {
-				if len(batch) > 0 {
-					spend.InsertBatchSpendRecord(batch)
-					logger.Info("Flushed spend log records to database", zap.Int("batch size", len(batch)))
-				}
-				return
-			}
-			batch = append(batch, logRecord)
-		default:
-			if len(batch) > 0 {
-				spend.InsertBatchSpendRecord(batch)
-				logger.Info("Flushed spend log records to database", zap.Int("batch size", len(batch)))
-			}
-			return
-		}
-	}
-}
-
-func FlushKeySpend(logger *zap.Logger, queue map[int]chan float64) {
-	for keyID, ch := range queue {
-		totalSpend := 0.0
-		for {
-			select {
-			case spd, ok := <-ch:
-				if !ok {
-					if totalSpend > 0 {
-						spend.UpdateKeySpendRecord(totalSpend, keyID)
-						logger.Info("Flushed key spend records to database",
-							zap.Int("key_id", keyID),
-							zap.Float64("total spend", totalSpend),
-						)
-					}
-					goto nextKey
-				}
-				totalSpend += spd
-			default:
-				if totalSpend > 0 {
-					spend.UpdateKeySpendRecord(totalSpend, keyID)
-					logger.Info("Flushed key spend records to database",
-						zap.Int("key_id", keyID),
-						zap.Float64("total spend", totalSpend),
-					)
-				}
-				goto nextKey
-			}
+		if err := syncWeeklySpend(*latest, now); err != nil {
+			logger.Warn("Failed to load weekly key spend", zap.String("key", auth.RedactKeyContent(keyContent)), zap.Error(err))
 		}
-	nextKey:
+		spend.Balances.Sync(latest.KeyId, latest.Balance)
+		upsertCachedKey(applyEffectiveModelPermissions(*latest), now, keyInfo.LastAccessAt)
 	}
 }
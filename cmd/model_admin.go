@@ -0,0 +1,812 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/proxy"
+)
+
+type modelGroupResponse struct {
+	GroupID            int64                   `json:"group_id"`
+	GroupName          string                  `json:"group_name"`
+	Strategy           string                  `json:"strategy"`
+	CostPerInputToken  float64                 `json:"cost_per_input_token"`
+	CostPerOutputToken float64                 `json:"cost_per_output_token"`
+	RequestDefaults    map[string]interface{}  `json:"request_defaults"`
+	TokensPerMinute    int                     `json:"tokens_per_minute"`
+	CacheTTLSeconds    int                     `json:"cache_ttl_seconds"`
+	CacheHitCostRatio  float64                 `json:"cache_hit_cost_ratio"`
+	Retry              *models.RetryPolicy     `json:"retry,omitempty"`
+	Fallbacks          []string                `json:"fallbacks"`
+	Enabled            bool                    `json:"enabled"`
+	Endpoints          []modelEndpointResponse `json:"endpoints,omitempty"`
+}
+
+type modelEndpointResponse struct {
+	EndpointID         int64                     `json:"endpoint_id"`
+	GroupID            int64                     `json:"group_id"`
+	EndpointName       string                    `json:"endpoint_name"`
+	ProviderType       string                    `json:"provider_type"`
+	UpstreamModelName  string                    `json:"upstream_model_name"`
+	BaseURL            string                    `json:"base_url"`
+	APIKeySecretRef    string                    `json:"api_key_secret_ref"`
+	APIKeySecretRefs   []string                  `json:"api_key_secret_refs"`
+	Weight             int                       `json:"weight"`
+	TimeoutSeconds     int                       `json:"timeout_seconds"`
+	ContextWindow      int                       `json:"context_window"`
+	RetryTimes         int                       `json:"retry_times"`
+	SkipTLSVerify      bool                      `json:"skip_tls_verify"`
+	DisableStreamUsage bool                      `json:"disable_stream_usage"`
+	HealthCheck        *models.HealthCheckConfig `json:"health_check,omitempty"`
+	Pricing            *models.Pricing           `json:"pricing,omitempty"`
+	Enabled            bool                      `json:"enabled"`
+}
+
+type modelGroupRequest struct {
+	GroupName          string                 `json:"group_name" binding:"required"`
+	Strategy           string                 `json:"strategy"`
+	CostPerInputToken  float64                `json:"cost_per_input_token"`
+	CostPerOutputToken float64                `json:"cost_per_output_token"`
+	RequestDefaults    map[string]interface{} `json:"request_defaults"`
+	TokensPerMinute    int                    `json:"tokens_per_minute"`
+	CacheTTLSeconds    int                    `json:"cache_ttl_seconds"`
+	CacheHitCostRatio  float64                `json:"cache_hit_cost_ratio"`
+	Retry              *models.RetryPolicy    `json:"retry"`
+	Fallbacks          []string               `json:"fallbacks"`
+	Enabled            *bool                  `json:"enabled"`
+}
+
+type modelGroupPatchRequest struct {
+	GroupName          *string                 `json:"group_name"`
+	Strategy           *string                 `json:"strategy"`
+	CostPerInputToken  *float64                `json:"cost_per_input_token"`
+	CostPerOutputToken *float64                `json:"cost_per_output_token"`
+	RequestDefaults    *map[string]interface{} `json:"request_defaults"`
+	TokensPerMinute    *int                    `json:"tokens_per_minute"`
+	CacheTTLSeconds    *int                    `json:"cache_ttl_seconds"`
+	CacheHitCostRatio  *float64                `json:"cache_hit_cost_ratio"`
+	Retry              *models.RetryPolicy     `json:"retry"`
+	Fallbacks          *[]string               `json:"fallbacks"`
+	Enabled            *bool                   `json:"enabled"`
+}
+
+type modelEndpointRequest struct {
+	EndpointName       string                    `json:"endpoint_name" binding:"required"`
+	ProviderType       string                    `json:"provider_type" binding:"required"`
+	UpstreamModelName  string                    `json:"upstream_model_name"`
+	BaseURL            string                    `json:"base_url" binding:"required"`
+	APIKeySecretRef    string                    `json:"api_key_secret_ref"`
+	APIKeySecretRefs   []string                  `json:"api_key_secret_refs"`
+	Weight             int                       `json:"weight"`
+	TimeoutSeconds     int                       `json:"timeout_seconds"`
+	ContextWindow      int                       `json:"context_window"`
+	RetryTimes         *int                      `json:"retry_times"`
+	SkipTLSVerify      bool                      `json:"skip_tls_verify"`
+	DisableStreamUsage bool                      `json:"disable_stream_usage"`
+	HealthCheck        *models.HealthCheckConfig `json:"health_check"`
+	Pricing            *models.Pricing           `json:"pricing"`
+	Enabled            *bool                     `json:"enabled"`
+}
+
+type modelEndpointPatchRequest struct {
+	EndpointName       *string                   `json:"endpoint_name"`
+	ProviderType       *string                   `json:"provider_type"`
+	UpstreamModelName  *string                   `json:"upstream_model_name"`
+	BaseURL            *string                   `json:"base_url"`
+	APIKeySecretRef    *string                   `json:"api_key_secret_ref"`
+	APIKeySecretRefs   *[]string                 `json:"api_key_secret_refs"`
+	Weight             *int                      `json:"weight"`
+	TimeoutSeconds     *int                      `json:"timeout_seconds"`
+	ContextWindow      *int                      `json:"context_window"`
+	RetryTimes         *int                      `json:"retry_times"`
+	SkipTLSVerify      *bool                     `json:"skip_tls_verify"`
+	DisableStreamUsage *bool                     `json:"disable_stream_usage"`
+	HealthCheck        *models.HealthCheckConfig `json:"health_check"`
+	Pricing            *models.Pricing           `json:"pricing"`
+	Enabled            *bool                     `json:"enabled"`
+}
+
+// requireDatabaseModelSource rejects writes that a YAML sync would silently overwrite.
+func requireDatabaseModelSource(c *gin.Context) bool {
+	if modelSource != modelSourceDatabase {
+		c.JSON(http.StatusConflict, gin.H{"error": "model config is managed by config.yaml; set models.source to database to edit it via the admin API"})
+		return false
+	}
+	return true
+}
+
+// refreshRoutedModels applies model table changes to this replica right away; other replicas
+// pick them up on their next poll.
+func refreshRoutedModels() {
+	if activeConfigReloader != nil {
+		_, _ = activeConfigReloader.Reload("admin")
+	}
+}
+
+func listModelGroups(c *gin.Context) {
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	var records []modelGroupRecord
+	if err := db.Table("janus_model_group").Order("group_id").Find(&records).Error; err != nil {
+		respondDBError(c, "list model groups failed", err)
+		return
+	}
+	data := make([]modelGroupResponse, 0, len(records))
+	for _, record := range records {
+		data = append(data, modelGroupToResponse(record))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func createModelGroup(c *gin.Context) {
+	if !requireDatabaseModelSource(c) {
+		return
+	}
+	var req modelGroupRequest
+	if !bindAdminJSON(c, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.GroupName)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_name is required"})
+		return
+	}
+	if msg := validateModelGroupLimits(req.TokensPerMinute, req.CacheTTLSeconds, req.CacheHitCostRatio); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	requestDefaults, err := marshalRequestDefaults(req.RequestDefaults)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request_defaults must be a JSON object"})
+		return
+	}
+	if err := proxy.ValidateRetryPolicy(req.Retry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	retryPolicy, err := marshalRetryPolicy(req.Retry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid retry"})
+		return
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	if msg, err := validateFallbackGroups(db, 0, name, req.Fallbacks); err != nil {
+		respondDBError(c, "query model groups failed", err)
+		return
+	} else if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var groupID int64
+	if err := db.Raw(`
+		INSERT INTO janus_model_group (
+			group_name,
+			strategy,
+			cost_per_input_token,
+			cost_per_output_token,
+			request_defaults,
+			tokens_per_minute,
+			cache_ttl_seconds,
+			cache_hit_cost_ratio,
+			retry_policy,
+			fallbacks,
+			enabled
+		)
+		VALUES (?, ?, ?, ?, ?::jsonb, ?, ?, ?, NULLIF(?, '')::jsonb, ?, ?)
+		RETURNING group_id
+	`, name, normalizeStrategy(req.Strategy), req.CostPerInputToken, req.CostPerOutputToken, string(requestDefaults),
+		req.TokensPerMinute, req.CacheTTLSeconds, req.CacheHitCostRatio, string(retryPolicy), joinTextList(req.Fallbacks), enabled).
+		Scan(&groupID).Error; err != nil {
+		respondDBError(c, "create model group failed", err)
+		return
+	}
+	refreshRoutedModels()
+
+	group, err := findModelGroup(db, groupID)
+	if err != nil {
+		respondDBError(c, "query model group failed", err)
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+func getModelGroup(c *gin.Context) {
+	id, ok := parseIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	group, err := findModelGroup(db, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model group not found"})
+		return
+	}
+	if err != nil {
+		respondDBError(c, "query model group failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func updateModelGroup(c *gin.Context) {
+	if !requireDatabaseModelSource(c) {
+		return
+	}
+	id, ok := parseIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+	var req modelGroupPatchRequest
+	if !bindAdminJSON(c, &req) {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.GroupName != nil {
+		name := strings.TrimSpace(*req.GroupName)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_name cannot be empty"})
+			return
+		}
+		updates["group_name"] = name
+	}
+	if req.Strategy != nil {
+		updates["strategy"] = normalizeStrategy(*req.Strategy)
+	}
+	if req.CostPerInputToken != nil {
+		updates["cost_per_input_token"] = *req.CostPerInputToken
+	}
+	if req.CostPerOutputToken != nil {
+		updates["cost_per_output_token"] = *req.CostPerOutputToken
+	}
+	if req.RequestDefaults != nil {
+		requestDefaults, err := marshalRequestDefaults(*req.RequestDefaults)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "request_defaults must be a JSON object"})
+			return
+		}
+		updates["request_defaults"] = gorm.Expr("?::jsonb", string(requestDefaults))
+	}
+	if req.TokensPerMinute != nil {
+		updates["tokens_per_minute"] = *req.TokensPerMinute
+	}
+	if req.CacheTTLSeconds != nil {
+		updates["cache_ttl_seconds"] = *req.CacheTTLSeconds
+	}
+	if req.CacheHitCostRatio != nil {
+		updates["cache_hit_cost_ratio"] = *req.CacheHitCostRatio
+	}
+	if req.Retry != nil {
+		if err := proxy.ValidateRetryPolicy(req.Retry); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		retryPolicy, err := marshalRetryPolicy(req.Retry)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid retry"})
+			return
+		}
+		updates["retry_policy"] = jsonbOrNull(retryPolicy)
+	}
+	if req.Fallbacks != nil {
+		updates["fallbacks"] = joinTextList(*req.Fallbacks)
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if msg := validateModelGroupLimits(derefInt(req.TokensPerMinute), derefInt(req.CacheTTLSeconds), derefFloat(req.CacheHitCostRatio)); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	if req.Fallbacks != nil {
+		name := ""
+		if req.GroupName != nil {
+			name = strings.TrimSpace(*req.GroupName)
+		}
+		if msg, err := validateFallbackGroups(db, id, name, *req.Fallbacks); err != nil {
+			respondDBError(c, "query model groups failed", err)
+			return
+		} else if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	result := db.Table("janus_model_group").Where("group_id = ?", id).Updates(updates)
+	if result.Error != nil {
+		respondDBError(c, "update model group failed", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model group not found"})
+		return
+	}
+	refreshRoutedModels()
+	getModelGroup(c)
+}
+
+func deleteModelGroup(c *gin.Context) {
+	if !requireDatabaseModelSource(c) {
+		return
+	}
+	id, ok := parseIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	// Endpoints are removed by ON DELETE CASCADE.
+	result := db.Table("janus_model_group").Where("group_id = ?", id).Delete(&modelGroupRecord{})
+	if result.Error != nil {
+		respondDBError(c, "delete model group failed", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model group not found"})
+		return
+	}
+	refreshRoutedModels()
+	c.Status(http.StatusNoContent)
+}
+
+func listModelEndpoints(c *gin.Context) {
+	groupID, ok := parseIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	endpoints, err := findModelEndpoints(db, groupID)
+	if err != nil {
+		respondDBError(c, "list model endpoints failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": endpoints})
+}
+
+func createModelEndpoint(c *gin.Context) {
+	if !requireDatabaseModelSource(c) {
+		return
+	}
+	groupID, ok := parseIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+	var req modelEndpointRequest
+	if !bindAdminJSON(c, &req) {
+		return
+	}
+
+	retryTimes := defaultEndpointRetryTimes
+	if req.RetryTimes != nil {
+		retryTimes = *req.RetryTimes
+	}
+	values := map[string]interface{}{
+		"group_id":             groupID,
+		"endpoint_name":        strings.TrimSpace(req.EndpointName),
+		"provider_type":        strings.TrimSpace(req.ProviderType),
+		"upstream_model_name":  strings.TrimSpace(req.UpstreamModelName),
+		"base_url":             strings.TrimSpace(req.BaseURL),
+		"api_key_secret_ref":   strings.TrimSpace(req.APIKeySecretRef),
+		"api_key_secret_refs":  joinTextList(req.APIKeySecretRefs),
+		"weight":               normalizePositive(req.Weight, defaultEndpointWeight),
+		"timeout_seconds":      normalizePositive(req.TimeoutSeconds, defaultEndpointTimeoutSeconds),
+		"context_window":       req.ContextWindow,
+		"retry_times":          retryTimes,
+		"skip_tls_verify":      req.SkipTLSVerify,
+		"disable_stream_usage": req.DisableStreamUsage,
+		"enabled":              req.Enabled == nil || *req.Enabled,
+	}
+	if values["upstream_model_name"] == "" {
+		values["upstream_model_name"] = values["endpoint_name"]
+	}
+	if msg := validateModelEndpointValues(values); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	healthCheck, err := marshalHealthCheck(req.HealthCheck)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid health_check"})
+		return
+	}
+	values["health_check"] = jsonbOrNull(healthCheck)
+	pricing, err := marshalPricing(req.Pricing)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pricing: " + err.Error()})
+		return
+	}
+	values["pricing"] = jsonbOrNull(pricing)
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	if err := db.Table("janus_model_endpoint").Create(values).Error; err != nil {
+		respondDBError(c, "create model endpoint failed", err)
+		return
+	}
+	refreshRoutedModels()
+
+	var record modelEndpointRecord
+	if err := db.Table("janus_model_endpoint").
+		Where("group_id = ? AND endpoint_name = ?", groupID, values["endpoint_name"]).
+		First(&record).Error; err != nil {
+		respondDBError(c, "query model endpoint failed", err)
+		return
+	}
+	c.JSON(http.StatusCreated, modelEndpointToResponse(record))
+}
+
+func getModelEndpoint(c *gin.Context) {
+	groupID, ok := parseIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+	endpointID, ok := parseIDParam(c, "endpoint_id")
+	if !ok {
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	var record modelEndpointRecord
+	if !firstByID(c, db.Table("janus_model_endpoint").Where("endpoint_id = ? AND group_id = ?", endpointID, groupID), &record) {
+		return
+	}
+	c.JSON(http.StatusOK, modelEndpointToResponse(record))
+}
+
+func updateModelEndpoint(c *gin.Context) {
+	if !requireDatabaseModelSource(c) {
+		return
+	}
+	groupID, ok := parseIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+	endpointID, ok := parseIDParam(c, "endpoint_id")
+	if !ok {
+		return
+	}
+	var req modelEndpointPatchRequest
+	if !bindAdminJSON(c, &req) {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	setTrimmed := func(column string, value *string) {
+		if value != nil {
+			updates[column] = strings.TrimSpace(*value)
+		}
+	}
+	setTrimmed("endpoint_name", req.EndpointName)
+	setTrimmed("provider_type", req.ProviderType)
+	setTrimmed("upstream_model_name", req.UpstreamModelName)
+	setTrimmed("base_url", req.BaseURL)
+	setTrimmed("api_key_secret_ref", req.APIKeySecretRef)
+	if req.APIKeySecretRefs != nil {
+		updates["api_key_secret_refs"] = joinTextList(*req.APIKeySecretRefs)
+	}
+	if req.Weight != nil {
+		updates["weight"] = *req.Weight
+	}
+	if req.TimeoutSeconds != nil {
+		updates["timeout_seconds"] = *req.TimeoutSeconds
+	}
+	if req.ContextWindow != nil {
+		updates["context_window"] = *req.ContextWindow
+	}
+	if req.RetryTimes != nil {
+		updates["retry_times"] = *req.RetryTimes
+	}
+	if req.SkipTLSVerify != nil {
+		updates["skip_tls_verify"] = *req.SkipTLSVerify
+	}
+	if req.DisableStreamUsage != nil {
+		updates["disable_stream_usage"] = *req.DisableStreamUsage
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if msg := validateModelEndpointValues(updates); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if req.HealthCheck != nil {
+		healthCheck, err := marshalHealthCheck(req.HealthCheck)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid health_check"})
+			return
+		}
+		updates["health_check"] = jsonbOrNull(healthCheck)
+	}
+	if req.Pricing != nil {
+		pricing, err := marshalPricing(req.Pricing)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pricing: " + err.Error()})
+			return
+		}
+		updates["pricing"] = jsonbOrNull(pricing)
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	result := db.Table("janus_model_endpoint").
+		Where("endpoint_id = ? AND group_id = ?", endpointID, groupID).
+		Updates(updates)
+	if result.Error != nil {
+		respondDBError(c, "update model endpoint failed", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model endpoint not found"})
+		return
+	}
+	refreshRoutedModels()
+	getModelEndpoint(c)
+}
+
+func deleteModelEndpoint(c *gin.Context) {
+	if !requireDatabaseModelSource(c) {
+		return
+	}
+	groupID, ok := parseIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+	endpointID, ok := parseIDParam(c, "endpoint_id")
+	if !ok {
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	result := db.Table("janus_model_endpoint").
+		Where("endpoint_id = ? AND group_id = ?", endpointID, groupID).
+		Delete(&modelEndpointRecord{})
+	if result.Error != nil {
+		respondDBError(c, "delete model endpoint failed", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model endpoint not found"})
+		return
+	}
+	refreshRoutedModels()
+	c.Status(http.StatusNoContent)
+}
+
+func findModelGroup(db *gorm.DB, groupID int64) (modelGroupResponse, error) {
+	var record modelGroupRecord
+	if err := db.Table("janus_model_group").Where("group_id = ?", groupID).First(&record).Error; err != nil {
+		return modelGroupResponse{}, err
+	}
+	group := modelGroupToResponse(record)
+	endpoints, err := findModelEndpoints(db, groupID)
+	if err != nil {
+		return modelGroupResponse{}, err
+	}
+	group.Endpoints = endpoints
+	return group, nil
+}
+
+func findModelEndpoints(db *gorm.DB, groupID int64) ([]modelEndpointResponse, error) {
+	var records []modelEndpointRecord
+	if err := db.Table("janus_model_endpoint").Where("group_id = ?", groupID).Order("endpoint_id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	out := make([]modelEndpointResponse, 0, len(records))
+	for _, record := range records {
+		out = append(out, modelEndpointToResponse(record))
+	}
+	return out, nil
+}
+
+func modelGroupToResponse(record modelGroupRecord) modelGroupResponse {
+	group := modelGroupResponse{
+		GroupID:            record.GroupID,
+		GroupName:          record.GroupName,
+		Strategy:           record.Strategy,
+		CostPerInputToken:  record.CostPerInputToken,
+		CostPerOutputToken: record.CostPerOutputToken,
+		RequestDefaults:    map[string]interface{}{},
+		TokensPerMinute:    record.TokensPerMinute,
+		CacheTTLSeconds:    record.CacheTTLSeconds,
+		CacheHitCostRatio:  record.CacheHitCostRatio,
+		Fallbacks:          splitTextList(record.Fallbacks),
+		Enabled:            record.Enabled,
+	}
+	if group.Fallbacks == nil {
+		group.Fallbacks = []string{}
+	}
+	if len(record.RequestDefaults) > 0 {
+		_ = json.Unmarshal(record.RequestDefaults, &group.RequestDefaults)
+	}
+	if len(record.RetryPolicy) > 0 && string(record.RetryPolicy) != "null" {
+		var policy models.RetryPolicy
+		if err := json.Unmarshal(record.RetryPolicy, &policy); err == nil {
+			group.Retry = &policy
+		}
+	}
+	return group
+}
+
+func modelEndpointToResponse(record modelEndpointRecord) modelEndpointResponse {
+	endpoint := modelEndpointResponse{
+		EndpointID:         record.EndpointID,
+		GroupID:            record.GroupID,
+		EndpointName:       record.EndpointName,
+		ProviderType:       record.ProviderType,
+		UpstreamModelName:  record.UpstreamModelName,
+		BaseURL:            record.BaseURL,
+		APIKeySecretRef:    record.APIKeySecretRef,
+		APIKeySecretRefs:   splitTextList(record.APIKeySecretRefs),
+		Weight:             record.Weight,
+		TimeoutSeconds:     record.TimeoutSeconds,
+		ContextWindow:      record.ContextWindow,
+		RetryTimes:         record.RetryTimes,
+		SkipTLSVerify:      record.SkipTLSVerify,
+		DisableStreamUsage: record.DisableStreamUsage,
+		Enabled:            record.Enabled,
+	}
+	if len(record.HealthCheck) > 0 && string(record.HealthCheck) != "null" {
+		var check models.HealthCheckConfig
+		if err := json.Unmarshal(record.HealthCheck, &check); err == nil {
+			endpoint.HealthCheck = &check
+		}
+	}
+	if len(record.Pricing) > 0 && string(record.Pricing) != "null" {
+		var pricing models.Pricing
+		if err := json.Unmarshal(record.Pricing, &pricing); err == nil {
+			endpoint.Pricing = &pricing
+		}
+	}
+	return endpoint
+}
+
+func validateModelGroupLimits(tokensPerMinute int, cacheTTLSeconds int, cacheHitCostRatio float64) string {
+	switch {
+	case tokensPerMinute < 0:
+		return "tokens_per_minute must be non-negative"
+	case cacheTTLSeconds < 0:
+		return "cache_ttl_seconds must be non-negative"
+	case cacheHitCostRatio < 0:
+		return "cache_hit_cost_ratio must be non-negative"
+	}
+	return ""
+}
+
+// validateFallbackGroups checks that fallbacks name other existing groups. groupID and
+// groupName identify the group being written; either may be zero when not known yet.
+func validateFallbackGroups(db *gorm.DB, groupID int64, groupName string, fallbacks []string) (string, error) {
+	names := splitTextList(joinTextList(fallbacks))
+	if len(names) == 0 {
+		return "", nil
+	}
+	var records []modelGroupRecord
+	if err := db.Table("janus_model_group").Select("group_id", "group_name").Where("group_name IN ?", names).Find(&records).Error; err != nil {
+		return "", err
+	}
+	ids := make(map[string]int64, len(records))
+	for _, record := range records {
+		ids[record.GroupName] = record.GroupID
+	}
+	for _, name := range names {
+		id, ok := ids[name]
+		if name == groupName || (ok && id == groupID) {
+			return "a model group cannot fall back to itself", nil
+		}
+		if !ok {
+			return "unknown fallback group: " + name, nil
+		}
+	}
+	return "", nil
+}
+
+// validateModelEndpointValues checks the endpoint columns present in values.
+func validateModelEndpointValues(values map[string]interface{}) string {
+	for _, column := range []string{"endpoint_name", "provider_type", "upstream_model_name"} {
+		if value, ok := values[column]; ok && value == "" {
+			return column + " cannot be empty"
+		}
+	}
+	if value, ok := values["base_url"]; ok {
+		parsed, err := url.Parse(value.(string))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "base_url must be an absolute http(s) URL"
+		}
+	}
+	if value, ok := values["weight"]; ok && value.(int) <= 0 {
+		return "weight must be positive"
+	}
+	if value, ok := values["timeout_seconds"]; ok && value.(int) <= 0 {
+		return "timeout_seconds must be positive"
+	}
+	if value, ok := values["retry_times"]; ok && value.(int) < 0 {
+		return "retry_times must be non-negative"
+	}
+	if value, ok := values["context_window"]; ok && value.(int) < 0 {
+		return "context_window must be non-negative"
+	}
+	return ""
+}
+
+func derefInt(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func derefFloat(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
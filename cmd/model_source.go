@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/proxy"
+)
+
+const (
+	// modelSourceYAML routes from config.yaml and syncs it to the model tables.
+	modelSourceYAML = "yaml"
+	// modelSourceDatabase routes from janus_model_group/janus_model_endpoint, managed through
+	// the admin API; model groups in config.yaml are ignored.
+	modelSourceDatabase = "database"
+
+	defaultModelPollIntervalSeconds = 30
+)
+
+// modelSource is the configured models.source; admin model writes require "database".
+var modelSource = modelSourceYAML
+
+func loadModelGroupsFromDatabase() ([]models.ModelGroup, error) {
+	db, err := janusDb.ConnectDatabase()
+	if err != nil {
+		return nil, err
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+	return loadModelGroupsFromGormDB(db)
+}
+
+func loadModelGroupsFromGormDB(db *gorm.DB) ([]models.ModelGroup, error) {
+	var groups []modelGroupRecord
+	if err := db.Table("janus_model_group").Where("enabled = TRUE").Order("group_name").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	var endpoints []modelEndpointRecord
+	if err := db.Table("janus_model_endpoint").Where("enabled = TRUE").Order("endpoint_id").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return buildModelGroupsFromRecords(groups, endpoints)
+}
+
+// buildModelGroupsFromRecords turns enabled model table rows into routing config. Endpoints
+// call the upstream model by upstream_model_name and authenticate with api_key_secret_ref.
+func buildModelGroupsFromRecords(groups []modelGroupRecord, endpoints []modelEndpointRecord) ([]models.ModelGroup, error) {
+	endpointsByGroup := make(map[int64][]modelEndpointRecord, len(groups))
+	for _, endpoint := range endpoints {
+		endpointsByGroup[endpoint.GroupID] = append(endpointsByGroup[endpoint.GroupID], endpoint)
+	}
+
+	out := make([]models.ModelGroup, 0, len(groups))
+	for _, record := range groups {
+		group := models.ModelGroup{
+			Name:               record.GroupName,
+			Strategy:           record.Strategy,
+			CostPerInputToken:  record.CostPerInputToken,
+			CostPerOutputToken: record.CostPerOutputToken,
+			TokensPerMinute:    record.TokensPerMinute,
+			CacheTTLSeconds:    record.CacheTTLSeconds,
+			CacheHitCostRatio:  record.CacheHitCostRatio,
+			Fallbacks:          splitTextList(record.Fallbacks),
+		}
+		if len(record.RequestDefaults) > 0 {
+			var defaults map[string]interface{}
+			if err := json.Unmarshal(record.RequestDefaults, &defaults); err != nil {
+				return nil, fmt.Errorf("decode request_defaults for group %s: %w", record.GroupName, err)
+			}
+			if len(defaults) > 0 {
+				group.RequestDefaults = defaults
+			}
+		}
+		if len(record.RetryPolicy) > 0 && string(record.RetryPolicy) != "null" {
+			var policy models.RetryPolicy
+			if err := json.Unmarshal(record.RetryPolicy, &policy); err != nil {
+				return nil, fmt.Errorf("decode retry_policy for group %s: %w", record.GroupName, err)
+			}
+			if err := proxy.ValidateRetryPolicy(&policy); err != nil {
+				return nil, fmt.Errorf("retry_policy for group %s: %w", record.GroupName, err)
+			}
+			group.Retry = &policy
+		}
+
+		for _, endpoint := range endpointsByGroup[record.GroupID] {
+			model := models.ModelConfig{
+				Name:               strings.TrimSpace(endpoint.UpstreamModelName),
+				Type:               endpoint.ProviderType,
+				BaseURL:            endpoint.BaseURL,
+				APIKeySecretRef:    endpoint.APIKeySecretRef,
+				APIKeySecretRefs:   splitTextList(endpoint.APIKeySecretRefs),
+				Weight:             endpoint.Weight,
+				TimeoutSeconds:     endpoint.TimeoutSeconds,
+				ContextWindow:      endpoint.ContextWindow,
+				RetryTimes:         endpoint.RetryTimes,
+				SkipTLSVerify:      endpoint.SkipTLSVerify,
+				DisableStreamUsage: endpoint.DisableStreamUsage,
+			}
+			if model.Name == "" {
+				model.Name = endpoint.EndpointName
+			}
+			if len(endpoint.HealthCheck) > 0 && string(endpoint.HealthCheck) != "null" {
+				var check models.HealthCheckConfig
+				if err := json.Unmarshal(endpoint.HealthCheck, &check); err != nil {
+					return nil, fmt.Errorf("decode health_check for endpoint %s/%s: %w", record.GroupName, endpoint.EndpointName, err)
+				}
+				model.HealthCheck = &check
+			}
+			if len(endpoint.Pricing) > 0 && string(endpoint.Pricing) != "null" {
+				var pricing models.Pricing
+				if err := json.Unmarshal(endpoint.Pricing, &pricing); err != nil {
+					return nil, fmt.Errorf("decode pricing for endpoint %s/%s: %w", record.GroupName, endpoint.EndpointName, err)
+				}
+				if err := pricing.Validate(); err != nil {
+					return nil, fmt.Errorf("pricing for endpoint %s/%s: %w", record.GroupName, endpoint.EndpointName, err)
+				}
+				model.Pricing = &pricing
+			}
+			group.Models = append(group.Models, model)
+		}
+		out = append(out, group)
+	}
+	return out, nil
+}
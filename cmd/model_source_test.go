@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+func TestBuildModelGroupsFromRecordsUsesUpstreamModelName(t *testing.T) {
+	groups := []modelGroupRecord{
+		{GroupID: 1, GroupName: "chat", Strategy: "latency", CostPerInputToken: 0.1, RequestDefaults: []byte(`{"temperature":0.2}`), TokensPerMinute: 500},
+		{GroupID: 2, GroupName: "empty", Strategy: "round-robin", RequestDefaults: []byte(`{}`)},
+	}
+	endpoints := []modelEndpointRecord{
+		{GroupID: 1, EndpointName: "primary", ProviderType: "openai", UpstreamModelName: "gpt-4o-mini", BaseURL: "https://a", APIKeySecretRef: "sk-a", Weight: 10, TimeoutSeconds: 30, RetryTimes: 2},
+		{GroupID: 1, EndpointName: "backup", ProviderType: "openai", BaseURL: "https://b", Weight: 1, HealthCheck: []byte(`{"path":"/health","interval_seconds":15}`)},
+	}
+
+	out, err := buildModelGroupsFromRecords(groups, endpoints)
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if len(out) != 2 || out[0].Name != "chat" || out[0].Strategy != "latency" || out[0].TokensPerMinute != 500 {
+		t.Fatalf("unexpected groups: %+v", out)
+	}
+	if out[0].RequestDefaults["temperature"] != 0.2 || out[1].RequestDefaults != nil {
+		t.Fatalf("unexpected request defaults: %v / %v", out[0].RequestDefaults, out[1].RequestDefaults)
+	}
+	if len(out[0].Models) != 2 || len(out[1].Models) != 0 {
+		t.Fatalf("unexpected endpoint grouping: %+v", out)
+	}
+	primary, backup := out[0].Models[0], out[0].Models[1]
+	if primary.Name != "gpt-4o-mini" || primary.APIKeySecretRef != "sk-a" || primary.RetryTimes != 2 {
+		t.Fatalf("unexpected primary endpoint: %+v", primary)
+	}
+	if backup.Name != "backup" || backup.HealthCheck == nil || backup.HealthCheck.Path != "/health" {
+		t.Fatalf("expected backup to fall back to endpoint name and keep its health check: %+v", backup)
+	}
+}
+
+func TestBuildModelGroupsFromRecordsRejectsInvalidJSON(t *testing.T) {
+	_, err := buildModelGroupsFromRecords([]modelGroupRecord{{GroupID: 1, GroupName: "chat", RequestDefaults: []byte(`[`)}}, nil)
+	if err == nil {
+		t.Fatalf("expected invalid request_defaults to fail")
+	}
+}
+
+func TestModelAdminWritesRequireDatabaseSource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	original := modelSource
+	t.Cleanup(func() { modelSource = original })
+	modelSource = modelSourceYAML
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodDelete, "/v1/admin/model-groups/1", nil)
+	ctx.Params = gin.Params{{Key: "group_id", Value: "1"}}
+
+	deleteModelGroup(ctx)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 when models.source is yaml, got %d", rec.Code)
+	}
+}
+
+func TestValidateModelEndpointValues(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"base_url must be an absolute http(s) URL": {"base_url": "ftp://host"},
+		"weight must be positive":                  {"weight": 0},
+		"retry_times must be non-negative":         {"retry_times": -1},
+		"endpoint_name cannot be empty":            {"endpoint_name": ""},
+	}
+	for want, values := range cases {
+		if got := validateModelEndpointValues(values); got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+	if got := validateModelEndpointValues(map[string]interface{}{"base_url": "https://api.example.com/v1", "weight": 5}); got != "" {
+		t.Fatalf("expected valid values, got %q", got)
+	}
+}
+
+func TestEndpointPricingSurvivesDatabaseRoundTrip(t *testing.T) {
+	cached := 0.25
+	pricing := &models.Pricing{
+		PriceRates: models.PriceRates{InputPerToken: 1, OutputPerToken: 2, CachedInputPerToken: &cached},
+		Tiers:      []models.PricingTier{{AbovePromptTokens: 1000, PriceRates: models.PriceRates{InputPerToken: 3, OutputPerToken: 4}}},
+	}
+	plan, err := buildConfigSyncPlan([]models.ModelGroup{
+		{Name: "chat", Models: []models.ModelConfig{{Name: "priced", Pricing: pricing}}},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildConfigSyncPlan returned error: %v", err)
+	}
+	record := plan.Endpoints[0].modelEndpointRecord
+	record.GroupID = 1
+
+	out, err := buildModelGroupsFromRecords([]modelGroupRecord{{GroupID: 1, GroupName: "chat"}}, []modelEndpointRecord{record})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if got := out[0].Models[0].Pricing; !reflect.DeepEqual(got, pricing) {
+		t.Fatalf("expected pricing %+v, got %+v", pricing, got)
+	}
+
+	_, err = buildConfigSyncPlan([]models.ModelGroup{
+		{Name: "chat", Models: []models.ModelConfig{{Name: "bad", Pricing: &models.Pricing{PriceRates: models.PriceRates{InputPerToken: -1}}}}},
+	}, nil, nil)
+	if err == nil {
+		t.Fatal("expected negative pricing to be rejected")
+	}
+}
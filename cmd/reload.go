@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/proxy"
+	"github.com/Uuq114/JanusLLM/internal/secrets"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+const (
+	configWatchInterval  = 5 * time.Second
+	secretResolveTimeout = 30 * time.Second
+)
+
+var (
+	modelGroupMu sync.RWMutex
+
+	// activeConfigReloader serves the admin reload endpoint; nil until run() starts it.
+	activeConfigReloader *configReloader
+
+	syncReloadedConfig      = syncConfigToDB
+	loadDatabaseModelGroups = loadModelGroupsFromDatabase
+
+	// secretResolver turns api_key_secret_ref into provider keys; run() adds vault:// when configured.
+	secretResolver        secrets.Resolver = secrets.NewRegistry()
+	secretRefreshInterval time.Duration
+)
+
+// applyModelGroups makes groups the live routing table: proxy balancers, the model group
+// set checked by checkKeyMiddleware, and spend prices.
+func applyModelGroups(p *proxy.Proxy, groups []models.ModelGroup) {
+	p.ReplaceModelGroups(groups)
+
+	names := make(map[string]struct{}, len(groups))
+	prices := make(map[string][]float64, len(groups))
+	for _, group := range groups {
+		names[group.Name] = struct{}{}
+		prices[group.Name] = []float64{group.CostPerInputToken, group.CostPerOutputToken}
+	}
+	modelGroupMu.Lock()
+	modelGroupSet = names
+	modelGroupMu.Unlock()
+	spend.SetModelPrices(prices)
+}
+
+func modelGroupConfigured(name string) bool {
+	modelGroupMu.RLock()
+	defer modelGroupMu.RUnlock()
+	_, ok := modelGroupSet[name]
+	return ok
+}
+
+// configReloader re-reads model groups on SIGHUP or an admin request, and when config.yaml
+// changes (yaml source) or the model tables change (database source, polled). Service,
+// secrets and admin settings still require a restart.
+type configReloader struct {
+	path         string
+	source       string
+	pollInterval time.Duration
+	proxy        *proxy.Proxy
+	logger       *zap.Logger
+
+	mu          sync.Mutex
+	fingerprint [sha256.Size]byte
+	modTime     time.Time
+	// loaded is the model config as read from its source; applied is loaded with provider keys
+	// resolved from api_key_secret_ref.
+	loaded           []models.ModelGroup
+	applied          []models.ModelGroup
+	stopHealthChecks func()
+}
+
+func newConfigReloader(path string, source string, pollInterval time.Duration, p *proxy.Proxy, logger *zap.Logger) *configReloader {
+	r := &configReloader{path: path, source: source, pollInterval: pollInterval, proxy: p, logger: logger}
+	if data, err := os.ReadFile(path); err == nil {
+		r.fingerprint = sha256.Sum256(data)
+	}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	r.stopHealthChecks = func() {}
+	return r
+}
+
+// Reload applies the current model groups. Nothing changes when they cannot be loaded, the
+// file does not parse, the database sync fails, or a secret reference cannot be resolved.
+func (r *configReloader) Reload(trigger string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names, err := r.reloadLocked(trigger)
+	if err != nil {
+		r.logger.Error("Config reload failed", zap.String("trigger", trigger), zap.Error(err))
+		return nil, err
+	}
+	return names, nil
+}
+
+func (r *configReloader) reloadLocked(trigger string) ([]string, error) {
+	snapshot, err := r.loadModelGroups()
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := resolveModelSecrets(snapshot.groups)
+	if err != nil {
+		return nil, err
+	}
+	// The database and the file fingerprint only move once the config is known to apply, so
+	// a failed reload leaves both as they were and the next file check retries it.
+	if snapshot.config != nil {
+		if err := syncReloadedConfig(snapshot.config, r.logger); err != nil {
+			return nil, err
+		}
+		r.fingerprint = snapshot.fingerprint
+		r.modTime = snapshot.modTime
+	}
+	return r.applyLocked(snapshot.groups, resolved, trigger), nil
+}
+
+// modelConfigSnapshot is the model config read from its source but not applied yet. config
+// and the file fingerprint are only set for the yaml source.
+type modelConfigSnapshot struct {
+	groups      []models.ModelGroup
+	config      *JanusConfig
+	fingerprint [sha256.Size]byte
+	modTime     time.Time
+}
+
+func (r *configReloader) loadModelGroups() (modelConfigSnapshot, error) {
+	if r.source == modelSourceDatabase {
+		groups, err := loadDatabaseModelGroups()
+		return modelConfigSnapshot{groups: groups}, err
+	}
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return modelConfigSnapshot{}, err
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return modelConfigSnapshot{}, err
+	}
+	config, err := loadJanusConfig(r.path)
+	if err != nil {
+		return modelConfigSnapshot{}, err
+	}
+	return modelConfigSnapshot{
+		groups:      config.Models.ModelGroups,
+		config:      config,
+		fingerprint: sha256.Sum256(data),
+		modTime:     info.ModTime(),
+	}, nil
+}
+
+func (r *configReloader) applyLocked(loaded []models.ModelGroup, groups []models.ModelGroup, trigger string) []string {
+	applyModelGroups(r.proxy, groups)
+	r.loaded = loaded
+	r.applied = groups
+	r.stopHealthChecks()
+	r.stopHealthChecks = r.proxy.StartHealthChecks(r.logger)
+
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	r.logger.Info("Reloaded model config",
+		zap.String("trigger", trigger),
+		zap.String("source", r.source),
+		zap.Strings("model_groups", names),
+	)
+	return names
+}
+
+// pollDatabase applies the model tables when they differ from what is currently routed.
+func (r *configReloader) pollDatabase() {
+	groups, err := loadDatabaseModelGroups()
+	if err != nil {
+		r.logger.Warn("Failed to poll model config from database", zap.Error(err))
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reflect.DeepEqual(groups, r.loaded) {
+		return
+	}
+	resolved, err := resolveModelSecrets(groups)
+	if err != nil {
+		r.logger.Warn("Failed to resolve secrets for polled model config", zap.Error(err))
+		return
+	}
+	r.applyLocked(groups, resolved, "poll")
+}
+
+// refreshSecrets re-resolves provider keys so rotated secrets are used without a restart.
+// Balancers are only rebuilt for groups whose keys changed; on failure the current keys stay.
+func (r *configReloader) refreshSecrets() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !hasSecretRefs(r.loaded) {
+		return
+	}
+	resolved, err := resolveModelSecrets(r.loaded)
+	if err != nil {
+		r.logger.Warn("Failed to refresh provider secrets", zap.Error(err))
+		return
+	}
+	if reflect.DeepEqual(resolved, r.applied) {
+		return
+	}
+	r.applyLocked(r.loaded, resolved, "secret_refresh")
+}
+
+// resolveModelSecrets returns a copy of groups with APIKey set from each endpoint's
+// api_key_secret_ref and api_key_secret_refs appended to APIKeys. A reference takes
+// precedence over a plain api_key.
+func resolveModelSecrets(groups []models.ModelGroup) ([]models.ModelGroup, error) {
+	if !hasSecretRefs(groups) {
+		return groups, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretResolveTimeout)
+	defer cancel()
+
+	values := make(map[string]string)
+	out := make([]models.ModelGroup, len(groups))
+	for i, group := range groups {
+		group.Models = append([]models.ModelConfig(nil), group.Models...)
+		for j := range group.Models {
+			model := &group.Models[j]
+			resolve := func(ref string) (string, error) {
+				value, ok := values[ref]
+				if !ok {
+					var err error
+					if value, err = secretResolver.Resolve(ctx, ref); err != nil {
+						return "", fmt.Errorf("resolve secret ref %s for %s/%s: %w", secrets.Redact(ref), group.Name, model.Name, err)
+					}
+					values[ref] = value
+				}
+				return value, nil
+			}
+			if model.APIKeySecretRef != "" {
+				value, err := resolve(model.APIKeySecretRef)
+				if err != nil {
+					return nil, err
+				}
+				model.APIKey = value
+			}
+			if len(model.APIKeySecretRefs) > 0 {
+				keys := append([]string(nil), model.APIKeys...)
+				for _, ref := range model.APIKeySecretRefs {
+					value, err := resolve(ref)
+					if err != nil {
+						return nil, err
+					}
+					keys = append(keys, value)
+				}
+				model.APIKeys = keys
+			}
+		}
+		out[i] = group
+	}
+	return out, nil
+}
+
+func hasSecretRefs(groups []models.ModelGroup) bool {
+	for _, group := range groups {
+		for _, model := range group.Models {
+			if model.APIKeySecretRef != "" || len(model.APIKeySecretRefs) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Watch reloads on SIGHUP and when the model source changes, until done is closed.
+func (r *configReloader) Watch(done <-chan struct{}) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	interval := configWatchInterval
+	if r.source == modelSourceDatabase {
+		interval = r.pollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var refreshSecrets <-chan time.Time
+	if secretRefreshInterval > 0 {
+		secretTicker := time.NewTicker(secretRefreshInterval)
+		defer secretTicker.Stop()
+		refreshSecrets = secretTicker.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-refreshSecrets:
+			r.refreshSecrets()
+		case <-hangup:
+			_, _ = r.Reload("sighup")
+		case <-ticker.C:
+			if r.source == modelSourceDatabase {
+				r.pollDatabase()
+			} else if r.fileChanged() {
+				_, _ = r.Reload("file")
+			}
+		}
+	}
+}
+
+// fileChanged compares content, not just mtime, so touching the file or saving it unchanged
+// does not rebuild balancers.
+func (r *configReloader) fileChanged() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info.ModTime().Equal(r.modTime) {
+		return false
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return false
+	}
+	if sha256.Sum256(data) != r.fingerprint {
+		// modTime moves with a successful reload, so a failed one is retried next time.
+		return true
+	}
+	r.modTime = info.ModTime()
+	return false
+}
+
+func (r *configReloader) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopHealthChecks()
+}
+
+func reloadModelConfig(c *gin.Context) {
+	if activeConfigReloader == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "config reload unavailable"})
+		return
+	}
+	groups, err := activeConfigReloader.Reload("admin")
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "config reload failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"model_groups": groups})
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/proxy"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+const reloadTestConfig = `
+secrets:
+  database_url: postgres://janus@localhost/janus
+admin:
+  master_key: test-master-key
+models:
+  model_groups:
+    - name: %s
+      cost_per_input_token: 0.5
+      cost_per_output_token: 1
+      models:
+        - name: upstream
+          base_url: http://127.0.0.1:1
+`
+
+func writeReloadTestConfig(t *testing.T, path string, group string) {
+	t.Helper()
+	content := []byte(fmt.Sprintf(reloadTestConfig, group))
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+}
+
+func TestConfigReloaderAppliesNewModelGroups(t *testing.T) {
+	originalSync, originalPrices := syncReloadedConfig, spend.ModelPrice
+	t.Cleanup(func() {
+		syncReloadedConfig = originalSync
+		spend.SetModelPrices(originalPrices)
+	})
+	syncs := 0
+	syncReloadedConfig = func(*JanusConfig, *zap.Logger) error {
+		syncs++
+		return nil
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "chat-v1")
+	p := proxy.NewProxy()
+	reloader := newConfigReloader(path, modelSourceYAML, time.Minute, p, zap.NewNop())
+	defer reloader.Stop()
+	if _, err := reloader.Reload("test"); err != nil {
+		t.Fatalf("initial reload failed: %v", err)
+	}
+	if !modelGroupConfigured("chat-v1") {
+		t.Fatalf("expected chat-v1 to be configured")
+	}
+
+	writeReloadTestConfig(t, path, "chat-v2")
+	groups, err := reloader.Reload("test")
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if len(groups) != 1 || groups[0] != "chat-v2" || syncs != 2 {
+		t.Fatalf("unexpected reload result groups=%v syncs=%d", groups, syncs)
+	}
+	if modelGroupConfigured("chat-v1") || !modelGroupConfigured("chat-v2") {
+		t.Fatalf("expected model group set to follow the reloaded config")
+	}
+	if price := spend.ModelPrice["chat-v2"]; len(price) != 2 || price[0] != 0.5 || price[1] != 1 {
+		t.Fatalf("expected prices for chat-v2, got %v", price)
+	}
+}
+
+func TestConfigReloaderKeepsCurrentGroupsWhenSyncFails(t *testing.T) {
+	originalSync, originalPrices := syncReloadedConfig, spend.ModelPrice
+	t.Cleanup(func() {
+		syncReloadedConfig = originalSync
+		spend.SetModelPrices(originalPrices)
+	})
+	syncReloadedConfig = func(*JanusConfig, *zap.Logger) error { return nil }
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "stable")
+	reloader := newConfigReloader(path, modelSourceYAML, time.Minute, proxy.NewProxy(), zap.NewNop())
+	defer reloader.Stop()
+	if _, err := reloader.Reload("test"); err != nil {
+		t.Fatalf("initial reload failed: %v", err)
+	}
+
+	syncReloadedConfig = func(*JanusConfig, *zap.Logger) error { return errors.New("database down") }
+	writeReloadTestConfig(t, path, "broken")
+	if _, err := reloader.Reload("test"); err == nil {
+		t.Fatalf("expected reload to fail when the database sync fails")
+	}
+	if !modelGroupConfigured("stable") || modelGroupConfigured("broken") {
+		t.Fatalf("expected the previous model groups to stay active")
+	}
+}
+
+func TestConfigReloaderDetectsContentChangesOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "watched")
+	reloader := newConfigReloader(path, modelSourceYAML, time.Minute, proxy.NewProxy(), zap.NewNop())
+	defer reloader.Stop()
+
+	writeReloadTestConfig(t, path, "watched")
+	touchLater(t, path)
+	if reloader.fileChanged() {
+		t.Fatalf("expected rewriting identical content not to count as a change")
+	}
+
+	writeReloadTestConfig(t, path, "watched-v2")
+	touchLater(t, path)
+	if !reloader.fileChanged() {
+		t.Fatalf("expected new content to count as a change")
+	}
+}
+
+func touchLater(t *testing.T, path string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	later := info.ModTime().Add(2 * time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+}
+
+func TestConfigReloaderPollsDatabaseSource(t *testing.T) {
+	originalLoad, originalPrices := loadDatabaseModelGroups, spend.ModelPrice
+	t.Cleanup(func() {
+		loadDatabaseModelGroups = originalLoad
+		spend.SetModelPrices(originalPrices)
+	})
+	current := []models.ModelGroup{{Name: "db-chat", Models: []models.ModelConfig{{Name: "upstream", BaseURL: "http://127.0.0.1:1"}}}}
+	loads := 0
+	loadDatabaseModelGroups = func() ([]models.ModelGroup, error) {
+		loads++
+		return append([]models.ModelGroup(nil), current...), nil
+	}
+
+	p := proxy.NewProxy()
+	reloader := newConfigReloader(filepath.Join(t.TempDir(), "missing.yaml"), modelSourceDatabase, time.Minute, p, zap.NewNop())
+	defer reloader.Stop()
+	if _, err := reloader.Reload("startup"); err != nil {
+		t.Fatalf("initial reload failed: %v", err)
+	}
+	if !modelGroupConfigured("db-chat") {
+		t.Fatalf("expected db-chat to be configured from the database")
+	}
+
+	before := &reloader.applied[0]
+	reloader.pollDatabase()
+	if &reloader.applied[0] != before {
+		t.Fatalf("expected an unchanged poll not to rebuild balancers")
+	}
+
+	current = []models.ModelGroup{{Name: "db-chat-v2", Models: []models.ModelConfig{{Name: "upstream", BaseURL: "http://127.0.0.1:1"}}}}
+	reloader.pollDatabase()
+	if loads != 3 || modelGroupConfigured("db-chat") || !modelGroupConfigured("db-chat-v2") {
+		t.Fatalf("expected poll to apply changed groups, loads=%d", loads)
+	}
+}
+
+type stubSecretResolver map[string]string
+
+func (s stubSecretResolver) Resolve(_ context.Context, ref string) (string, error) {
+	value, ok := s[ref]
+	if !ok {
+		return "", errors.New("secret not found")
+	}
+	return value, nil
+}
+
+func TestConfigReloaderResolvesAndRefreshesSecrets(t *testing.T) {
+	originalLoad, originalResolver, originalPrices := loadDatabaseModelGroups, secretResolver, spend.ModelPrice
+	t.Cleanup(func() {
+		loadDatabaseModelGroups = originalLoad
+		secretResolver = originalResolver
+		spend.SetModelPrices(originalPrices)
+	})
+	loaded := []models.ModelGroup{{Name: "secret-chat", Models: []models.ModelConfig{
+		{Name: "a", BaseURL: "http://127.0.0.1:1", APIKeySecretRef: "env://KEY"},
+		{Name: "b", BaseURL: "http://127.0.0.1:1", APIKeySecretRef: "env://KEY", APIKeySecretRefs: []string{"env://EXTRA"}},
+	}}}
+	loadDatabaseModelGroups = func() ([]models.ModelGroup, error) { return loaded, nil }
+	resolver := stubSecretResolver{"env://KEY": "sk-old", "env://EXTRA": "sk-extra"}
+	secretResolver = resolver
+
+	reloader := newConfigReloader(filepath.Join(t.TempDir(), "missing.yaml"), modelSourceDatabase, time.Minute, proxy.NewProxy(), zap.NewNop())
+	defer reloader.Stop()
+	if _, err := reloader.Reload("startup"); err != nil {
+		t.Fatalf("initial reload failed: %v", err)
+	}
+	if got := reloader.applied[0].Models[1]; got.APIKey != "sk-old" || len(got.APIKeys) != 1 || got.APIKeys[0] != "sk-extra" {
+		t.Fatalf("expected resolved key pool, got %q %v", got.APIKey, got.APIKeys)
+	}
+	if loaded[0].Models[0].APIKey != "" {
+		t.Fatalf("expected the loaded config not to be mutated")
+	}
+
+	resolver["env://KEY"] = "sk-new"
+	reloader.refreshSecrets()
+	if got := reloader.applied[0].Models[0].APIKey; got != "sk-new" {
+		t.Fatalf("expected refreshed key, got %q", got)
+	}
+
+	delete(resolver, "env://KEY")
+	reloader.refreshSecrets()
+	if got := reloader.applied[0].Models[0].APIKey; got != "sk-new" {
+		t.Fatalf("expected the current key to stay when refresh fails, got %q", got)
+	}
+	if _, err := reloader.Reload("admin"); err == nil {
+		t.Fatalf("expected reload to fail when a secret cannot be resolved")
+	}
+}
+
+func TestConfigReloaderDoesNotSyncOrRecordFileWhenSecretsFail(t *testing.T) {
+	originalSync, originalResolver, originalPrices := syncReloadedConfig, secretResolver, spend.ModelPrice
+	t.Cleanup(func() {
+		syncReloadedConfig = originalSync
+		secretResolver = originalResolver
+		spend.SetModelPrices(originalPrices)
+	})
+	syncs := 0
+	syncReloadedConfig = func(*JanusConfig, *zap.Logger) error {
+		syncs++
+		return nil
+	}
+	secretResolver = stubSecretResolver{}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "plain")
+	reloader := newConfigReloader(path, modelSourceYAML, time.Minute, proxy.NewProxy(), zap.NewNop())
+	defer reloader.Stop()
+	if _, err := reloader.Reload("test"); err != nil {
+		t.Fatalf("initial reload failed: %v", err)
+	}
+
+	content := fmt.Sprintf(reloadTestConfig, "vaulted") + "          api_key_secret_ref: vault://missing#key\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+	touchLater(t, path)
+	if !reloader.fileChanged() {
+		t.Fatalf("expected new content to count as a change")
+	}
+	if _, err := reloader.Reload("file"); err == nil || !strings.Contains(err.Error(), "resolve secret ref") {
+		t.Fatalf("expected reload to fail resolving the secret, got %v", err)
+	}
+	if syncs != 1 {
+		t.Fatalf("expected the failed reload not to sync the database, got %d syncs", syncs)
+	}
+	if !modelGroupConfigured("plain") || modelGroupConfigured("vaulted") {
+		t.Fatalf("expected the previous model groups to stay active")
+	}
+	if !reloader.fileChanged() {
+		t.Fatalf("expected the file watcher to retry the failed reload")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+// defaultSpendReportDays is the report range when start is not given.
+const defaultSpendReportDays = 7
+
+type paginationResponse struct {
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+	Total  int64 `json:"total"`
+}
+
+func getSpendReport(c *gin.Context) {
+	query, err := parseSpendReportQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	rows, total, err := spend.QuerySpendReport(db, query)
+	if err != nil {
+		respondDBError(c, "query spend report failed", err)
+		return
+	}
+	source := spend.ReportSourceLog
+	if query.UsesDailySummary() {
+		source = spend.ReportSourceDaily
+	}
+	groupBy := query.GroupBy
+	if groupBy == nil {
+		groupBy = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":       rows,
+		"start":      query.Start,
+		"end":        query.End,
+		"group_by":   groupBy,
+		"source":     source,
+		"pagination": paginationResponse{Limit: query.Limit, Offset: query.Offset, Total: total},
+	})
+}
+
+func listSpendLogs(c *gin.Context) {
+	query, err := parseSpendReportQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(query.GroupBy) > 0 || query.Source != spend.ReportSourceAuto {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by and source are not supported for spend logs"})
+		return
+	}
+
+	db, ok := connectAdminDB(c)
+	if !ok {
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	records, total, err := spend.ListSpendRecords(db, query)
+	if err != nil {
+		respondDBError(c, "list spend logs failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":       records,
+		"start":      query.Start,
+		"end":        query.End,
+		"pagination": paginationResponse{Limit: query.Limit, Offset: query.Offset, Total: total},
+	})
+}
+
+// parseSpendReportQuery reads the report range, grouping, filters and page from the query
+// string. The range defaults to the last seven UTC days including today.
+func parseSpendReportQuery(c *gin.Context) (spend.ReportQuery, error) {
+	var query spend.ReportQuery
+	var err error
+
+	if query.End, err = parseReportTime(c.Query("end")); err != nil {
+		return query, fmt.Errorf("end: %w", err)
+	}
+	if query.End.IsZero() {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		query.End = today.AddDate(0, 0, 1)
+	}
+	if query.Start, err = parseReportTime(c.Query("start")); err != nil {
+		return query, fmt.Errorf("start: %w", err)
+	}
+	if query.Start.IsZero() {
+		query.Start = query.End.AddDate(0, 0, -defaultSpendReportDays)
+	}
+
+	for _, dimension := range strings.Split(c.Query("group_by"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			query.GroupBy = append(query.GroupBy, dimension)
+		}
+	}
+	for name, target := range map[string]*int64{
+		"key_id":          &query.KeyID,
+		"team_id":         &query.TeamID,
+		"organization_id": &query.OrganizationID,
+	} {
+		if *target, err = parseOptionalInt(c.Query(name)); err != nil || *target < 0 {
+			return query, fmt.Errorf("%s must be a positive integer", name)
+		}
+	}
+	query.ModelGroup = strings.TrimSpace(c.Query("model_group"))
+	query.Provider = strings.TrimSpace(c.Query("provider"))
+	query.Source = strings.ToLower(strings.TrimSpace(c.Query("source")))
+
+	limit, err := parseOptionalInt(c.Query("limit"))
+	if err != nil {
+		return query, fmt.Errorf("limit must be an integer")
+	}
+	offset, err := parseOptionalInt(c.Query("offset"))
+	if err != nil {
+		return query, fmt.Errorf("offset must be an integer")
+	}
+	query.Limit, query.Offset = int(limit), int(offset)
+
+	return query, query.Validate()
+}
+
+// parseReportTime accepts RFC 3339 timestamps and dates, which mean 00:00 UTC.
+func parseReportTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 time or YYYY-MM-DD date")
+	}
+	return t, nil
+}
+
+func parseOptionalInt(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// refreshSpendSummary folds new spend records into janus_key_spend_daily and, when reconcile
+// is set, rebuilds the recent days to pick up records that committed out of order.
+func refreshSpendSummary(logger *zap.Logger, reconcile bool) {
+	db, err := janusDb.ConnectDatabase()
+	if err != nil {
+		logger.Warn("Failed to connect database for spend summary", zap.Error(err))
+		return
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	rows, err := spend.RefreshKeySpendDaily(db)
+	if err != nil {
+		logger.Warn("Failed to refresh daily key spend", zap.Error(err))
+		return
+	}
+	logger.Debug("Refreshed daily key spend", zap.Int64("rows", rows))
+	if !reconcile {
+		return
+	}
+
+	days, err := spend.ReconcileKeySpendDaily(db, time.Now())
+	if err != nil {
+		logger.Warn("Failed to reconcile daily key spend", zap.Error(err))
+		return
+	}
+	logger.Debug("Reconciled daily key spend", zap.Strings("days", days))
+}
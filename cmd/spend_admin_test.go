@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func spendQueryContext(rawQuery string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/admin/spend?"+rawQuery, nil)
+	return ctx
+}
+
+func TestParseSpendReportQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	query, err := parseSpendReportQuery(spendQueryContext("start=2026-10-01&end=2026-10-08T00:00:00Z&group_by=key,+day&team_id=3&limit=20&offset=40"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !query.Start.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) || !query.End.Equal(time.Date(2026, 10, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected range %v - %v", query.Start, query.End)
+	}
+	if !reflect.DeepEqual(query.GroupBy, []string{"key", "day"}) || query.TeamID != 3 || query.Limit != 20 || query.Offset != 40 {
+		t.Fatalf("unexpected query %+v", query)
+	}
+	if !query.UsesDailySummary() {
+		t.Fatal("expected whole-day key report to use the daily summary")
+	}
+
+	query, err = parseSpendReportQuery(spendQueryContext(""))
+	if err != nil {
+		t.Fatalf("unexpected error for defaults: %v", err)
+	}
+	if got := query.End.Sub(query.Start); got != defaultSpendReportDays*24*time.Hour || !query.End.After(time.Now()) {
+		t.Fatalf("expected the last %d days including today, got %v - %v", defaultSpendReportDays, query.Start, query.End)
+	}
+
+	for _, rawQuery := range []string{"start=yesterday", "group_by=region", "key_id=abc", "team_id=-1", "limit=5000"} {
+		if _, err := parseSpendReportQuery(spendQueryContext(rawQuery)); err == nil {
+			t.Errorf("%s: expected an error", rawQuery)
+		}
+	}
+}
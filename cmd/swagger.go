@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/spend"
 )
 
 func registerSwaggerRoutes(r *gin.Engine) {
@@ -36,6 +38,9 @@ func handleOpenAPISpec(c *gin.Context) {
 			{"name": "Admin Organizations", "description": "Management API for organizations."},
 			{"name": "Admin Teams", "description": "Management API for teams."},
 			{"name": "Admin Keys", "description": "Management API for API keys."},
+			{"name": "Admin Model Groups", "description": "Management API for model groups and endpoints when models.source is database."},
+			{"name": "Admin Config", "description": "Runtime configuration operations."},
+			{"name": "Admin Spend", "description": "Spend and usage reporting."},
 		},
 		"components": gin.H{
 			"securitySchemes": gin.H{
@@ -130,28 +135,31 @@ func handleOpenAPISpec(c *gin.Context) {
 				"Team": gin.H{
 					"type": "object",
 					"properties": gin.H{
-						"team_id":         gin.H{"type": "integer", "example": 1},
-						"team_name":       gin.H{"type": "string", "example": "platform-team"},
-						"model_list":      gin.H{"type": "array", "items": gin.H{"type": "string"}, "example": []string{"*"}},
-						"organization_id": gin.H{"type": "integer", "example": 1},
+						"team_id":           gin.H{"type": "integer", "example": 1},
+						"team_name":         gin.H{"type": "string", "example": "platform-team"},
+						"model_list":        gin.H{"type": "array", "items": gin.H{"type": "string"}, "example": []string{"*"}},
+						"organization_id":   gin.H{"type": "integer", "example": 1},
+						"tokens_per_minute": gin.H{"type": "integer", "description": "Team-wide TPM limit; 0 means unlimited.", "example": 0},
 					},
 				},
 				"TeamRequest": gin.H{
 					"type":     "object",
 					"required": []string{"team_name", "organization_id"},
 					"properties": gin.H{
-						"team_name":       gin.H{"type": "string", "example": "platform-team"},
-						"all_models":      gin.H{"type": "boolean", "description": "When true, grants all models to the team and stores model_list as [\"*\"].", "example": true},
-						"model_list":      gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Use [\"*\"] or all_models=true to grant all models.", "example": []string{"*"}},
-						"organization_id": gin.H{"type": "integer", "example": 1},
+						"team_name":         gin.H{"type": "string", "example": "platform-team"},
+						"all_models":        gin.H{"type": "boolean", "description": "When true, grants all models to the team and stores model_list as [\"*\"].", "example": true},
+						"model_list":        gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Use [\"*\"] or all_models=true to grant all models.", "example": []string{"*"}},
+						"organization_id":   gin.H{"type": "integer", "example": 1},
+						"tokens_per_minute": gin.H{"type": "integer", "description": "Team-wide TPM limit; 0 means unlimited.", "example": 0},
 					},
 				},
 				"TeamPatchRequest": gin.H{
 					"type": "object",
 					"properties": gin.H{
-						"team_name":  gin.H{"type": "string", "example": "platform-team"},
-						"all_models": gin.H{"type": "boolean", "description": "When true, grants all models to the team and stores model_list as [\"*\"].", "example": true},
-						"model_list": gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Use [\"*\"] or all_models=true to grant all models.", "example": []string{"*"}},
+						"team_name":         gin.H{"type": "string", "example": "platform-team"},
+						"all_models":        gin.H{"type": "boolean", "description": "When true, grants all models to the team and stores model_list as [\"*\"].", "example": true},
+						"model_list":        gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Use [\"*\"] or all_models=true to grant all models.", "example": []string{"*"}},
+						"tokens_per_minute": gin.H{"type": "integer", "description": "Team-wide TPM limit; 0 means unlimited.", "example": 0},
 					},
 				},
 				"Key": gin.H{
@@ -167,6 +175,7 @@ func handleOpenAPISpec(c *gin.Context) {
 						"total_spend":          gin.H{"type": "number", "example": 0},
 						"request_per_minute":   gin.H{"type": "integer", "example": 60},
 						"spend_limit_per_week": gin.H{"type": "number", "example": 0},
+						"tokens_per_minute":    gin.H{"type": "integer", "description": "Per-key TPM limit; 0 means unlimited.", "example": 0},
 						"expire_time":          gin.H{"type": "string", "format": "date-time", "nullable": true},
 					},
 				},
@@ -183,6 +192,7 @@ func handleOpenAPISpec(c *gin.Context) {
 						"balance":              gin.H{"type": "number", "example": 100},
 						"request_per_minute":   gin.H{"type": "integer", "example": 60},
 						"spend_limit_per_week": gin.H{"type": "number", "example": 0},
+						"tokens_per_minute":    gin.H{"type": "integer", "description": "Per-key TPM limit; 0 means unlimited.", "example": 0},
 						"expire_time":          gin.H{"type": "string", "format": "date-time"},
 					},
 				},
@@ -195,9 +205,263 @@ func handleOpenAPISpec(c *gin.Context) {
 						"balance":              gin.H{"type": "number", "example": 100},
 						"request_per_minute":   gin.H{"type": "integer", "example": 60},
 						"spend_limit_per_week": gin.H{"type": "number", "example": 0},
+						"tokens_per_minute":    gin.H{"type": "integer", "description": "Per-key TPM limit; 0 means unlimited.", "example": 0},
 						"expire_time":          gin.H{"type": "string", "format": "date-time"},
 					},
 				},
+				"ModelGroup": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"group_id":              gin.H{"type": "integer", "example": 1},
+						"group_name":            gin.H{"type": "string", "example": "deepseek-v3"},
+						"strategy":              gin.H{"type": "string", "enum": []string{"round-robin", "weighted", "latency", "client-sticky", "least-inflight"}, "example": "round-robin"},
+						"cost_per_input_token":  gin.H{"type": "number", "example": 0.000001},
+						"cost_per_output_token": gin.H{"type": "number", "example": 0.000002},
+						"request_defaults":      gin.H{"type": "object", "additionalProperties": true},
+						"tokens_per_minute":     gin.H{"type": "integer", "description": "Group-wide TPM limit; 0 means unlimited.", "example": 0},
+						"cache_ttl_seconds":     gin.H{"type": "integer", "description": "Response cache TTL; 0 disables caching for the group.", "example": 0},
+						"cache_hit_cost_ratio":  gin.H{"type": "number", "example": 0},
+						"retry":                 gin.H{"$ref": "#/components/schemas/RetryPolicy"},
+						"fallbacks":             gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Model groups tried in order when this group's endpoints are exhausted or reject the request for context length or content policy.", "example": []string{"deepseek-v3"}},
+						"enabled":               gin.H{"type": "boolean", "example": true},
+						"endpoints":             gin.H{"type": "array", "items": gin.H{"$ref": "#/components/schemas/ModelEndpoint"}},
+					},
+				},
+				"ModelGroupRequest": gin.H{
+					"type":     "object",
+					"required": []string{"group_name"},
+					"properties": gin.H{
+						"group_name":            gin.H{"type": "string", "example": "deepseek-v3"},
+						"strategy":              gin.H{"type": "string", "enum": []string{"round-robin", "weighted", "latency", "client-sticky", "least-inflight"}, "example": "round-robin"},
+						"cost_per_input_token":  gin.H{"type": "number", "example": 0.000001},
+						"cost_per_output_token": gin.H{"type": "number", "example": 0.000002},
+						"request_defaults":      gin.H{"type": "object", "additionalProperties": true},
+						"tokens_per_minute":     gin.H{"type": "integer", "example": 0},
+						"cache_ttl_seconds":     gin.H{"type": "integer", "example": 0},
+						"cache_hit_cost_ratio":  gin.H{"type": "number", "example": 0},
+						"retry":                 gin.H{"$ref": "#/components/schemas/RetryPolicy"},
+						"fallbacks":             gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Model groups tried in order when this group's endpoints are exhausted or reject the request for context length or content policy.", "example": []string{"deepseek-v3"}},
+						"enabled":               gin.H{"type": "boolean", "example": true},
+					},
+				},
+				"ModelEndpoint": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"endpoint_id":          gin.H{"type": "integer", "example": 1},
+						"group_id":             gin.H{"type": "integer", "example": 1},
+						"endpoint_name":        gin.H{"type": "string", "example": "deepseek-primary"},
+						"provider_type":        gin.H{"type": "string", "example": "openai"},
+						"upstream_model_name":  gin.H{"type": "string", "example": "deepseek-chat"},
+						"base_url":             gin.H{"type": "string", "example": "https://api.deepseek.com/v1"},
+						"api_key_secret_ref":   gin.H{"type": "string", "example": "env://DEEPSEEK_API_KEY"},
+						"api_key_secret_refs":  gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Additional provider keys rotated with api_key_secret_ref; a key rejected with 429 or 401 is benched."},
+						"weight":               gin.H{"type": "integer", "example": 100},
+						"timeout_seconds":      gin.H{"type": "integer", "example": 60},
+						"context_window":       gin.H{"type": "integer", "description": "Prompt plus completion token limit; requests estimated to exceed it skip the endpoint. 0 means unknown.", "example": 65536},
+						"retry_times":          gin.H{"type": "integer", "example": 1},
+						"skip_tls_verify":      gin.H{"type": "boolean", "example": false},
+						"disable_stream_usage": gin.H{"type": "boolean", "description": "Do not add stream_options.include_usage to streaming chat and text completions sent to this endpoint.", "example": false},
+						"health_check":         gin.H{"$ref": "#/components/schemas/HealthCheck"},
+						"pricing":              gin.H{"$ref": "#/components/schemas/Pricing"},
+						"enabled":              gin.H{"type": "boolean", "example": true},
+					},
+				},
+				"ModelEndpointRequest": gin.H{
+					"type":     "object",
+					"required": []string{"endpoint_name", "provider_type", "base_url"},
+					"properties": gin.H{
+						"endpoint_name":        gin.H{"type": "string", "example": "deepseek-primary"},
+						"provider_type":        gin.H{"type": "string", "example": "openai"},
+						"upstream_model_name":  gin.H{"type": "string", "description": "Model name sent upstream; defaults to endpoint_name.", "example": "deepseek-chat"},
+						"base_url":             gin.H{"type": "string", "example": "https://api.deepseek.com/v1"},
+						"api_key_secret_ref":   gin.H{"type": "string", "example": "env://DEEPSEEK_API_KEY"},
+						"api_key_secret_refs":  gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Additional provider keys rotated with api_key_secret_ref; a key rejected with 429 or 401 is benched."},
+						"weight":               gin.H{"type": "integer", "example": 100},
+						"timeout_seconds":      gin.H{"type": "integer", "example": 60},
+						"context_window":       gin.H{"type": "integer", "description": "Prompt plus completion token limit; requests estimated to exceed it skip the endpoint. 0 means unknown.", "example": 65536},
+						"retry_times":          gin.H{"type": "integer", "example": 1},
+						"skip_tls_verify":      gin.H{"type": "boolean", "example": false},
+						"disable_stream_usage": gin.H{"type": "boolean", "description": "Do not add stream_options.include_usage to streaming chat and text completions sent to this endpoint.", "example": false},
+						"health_check":         gin.H{"$ref": "#/components/schemas/HealthCheck"},
+						"pricing":              gin.H{"$ref": "#/components/schemas/Pricing"},
+						"enabled":              gin.H{"type": "boolean", "example": true},
+					},
+				},
+				"Pricing": gin.H{
+					"description": "Endpoint pricing billed instead of the group's cost_per_* fields. Unset detail rates fall back to input_per_token or output_per_token.",
+					"allOf": []gin.H{
+						{"$ref": "#/components/schemas/PriceRates"},
+						{
+							"type": "object",
+							"properties": gin.H{
+								"tiers": gin.H{
+									"type":        "array",
+									"description": "Rates for prompts longer than above_prompt_tokens; the highest exceeded threshold applies.",
+									"items": gin.H{"allOf": []gin.H{
+										{"$ref": "#/components/schemas/PriceRates"},
+										{"type": "object", "required": []string{"above_prompt_tokens"}, "properties": gin.H{"above_prompt_tokens": gin.H{"type": "integer", "example": 200000}}},
+									}},
+								},
+							},
+						},
+					},
+				},
+				"PriceRates": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"input_per_token":        gin.H{"type": "number", "example": 0.000003},
+						"output_per_token":       gin.H{"type": "number", "example": 0.000015},
+						"cached_input_per_token": gin.H{"type": "number", "example": 0.0000003},
+						"cache_write_per_token":  gin.H{"type": "number", "example": 0.00000375},
+						"reasoning_per_token":    gin.H{"type": "number"},
+						"image_input_per_token":  gin.H{"type": "number"},
+						"image_output_per_token": gin.H{"type": "number"},
+						"audio_input_per_token":  gin.H{"type": "number"},
+						"audio_output_per_token": gin.H{"type": "number"},
+					},
+				},
+				"PriceBreakdown": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"endpoint":                 gin.H{"type": "string", "description": "Serving endpoint, when its pricing applied instead of the group's."},
+						"tier_above_prompt_tokens": gin.H{"type": "integer", "description": "Threshold of the context-length tier that applied."},
+						"cost_multiplier":          gin.H{"type": "number", "description": "Factor applied to every item, e.g. cache_hit_cost_ratio for a gateway cache hit."},
+						"items": gin.H{
+							"type": "array",
+							"items": gin.H{
+								"type": "object",
+								"properties": gin.H{
+									"kind":      gin.H{"type": "string", "enum": []string{"input", "cached_input", "cache_write", "image_input", "audio_input", "output", "reasoning", "image_output", "audio_output"}},
+									"tokens":    gin.H{"type": "integer", "example": 1200},
+									"per_token": gin.H{"type": "number", "example": 0.000003},
+									"cost":      gin.H{"type": "number", "example": 0.0036},
+								},
+							},
+						},
+					},
+				},
+				"HealthCheck": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"path":             gin.H{"type": "string", "example": "/models"},
+						"interval_seconds": gin.H{"type": "integer", "example": 30},
+					},
+				},
+				"RetryPolicy": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"on":              gin.H{"type": "array", "items": gin.H{"type": "string"}, "description": "Retryable upstream statuses as codes or classes.", "example": []string{"5xx", "408", "429"}},
+						"backoff_ms":      gin.H{"type": "integer", "example": 200},
+						"max_backoff_ms":  gin.H{"type": "integer", "example": 2000},
+						"timeout_seconds": gin.H{"type": "integer", "description": "Deadline for all attempts of one request; 0 means none.", "example": 0},
+					},
+				},
+				"SpendReportRow": gin.H{
+					"type":        "object",
+					"description": "One group of a spend report; dimension fields appear only when grouped by.",
+					"properties": gin.H{
+						"key_id":            gin.H{"type": "integer", "example": 1},
+						"key_content":       gin.H{"type": "string", "example": "sk-a...f3d2"},
+						"team_id":           gin.H{"type": "integer", "example": 1},
+						"organization_id":   gin.H{"type": "integer", "example": 1},
+						"model_group":       gin.H{"type": "string", "example": "deepseek-v3"},
+						"provider":          gin.H{"type": "string", "example": "openai"},
+						"period":            gin.H{"type": "string", "format": "date-time", "description": "Start of the UTC day or hour bucket."},
+						"spend":             gin.H{"type": "number", "example": 1.25},
+						"prompt_tokens":     gin.H{"type": "integer", "example": 12000},
+						"completion_tokens": gin.H{"type": "integer", "example": 3400},
+						"total_tokens":      gin.H{"type": "integer", "example": 15400},
+						"request_count":     gin.H{"type": "integer", "example": 42},
+						"avg_latency_ms":    gin.H{"type": "number", "example": 830.5},
+						"cache_hit_ratio":   gin.H{"type": "number", "example": 0.1},
+					},
+				},
+				"SpendRecord": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"record_id":             gin.H{"type": "integer"},
+						"request_id":            gin.H{"type": "string"},
+						"key_id":                gin.H{"type": "integer"},
+						"key_content":           gin.H{"type": "string"},
+						"team_id":               gin.H{"type": "integer"},
+						"organization_id":       gin.H{"type": "integer"},
+						"tenant":                gin.H{"type": "string", "example": "org:1/team:1"},
+						"model_group":           gin.H{"type": "string"},
+						"provider":              gin.H{"type": "string"},
+						"credential":            gin.H{"type": "string"},
+						"latency_ms":            gin.H{"type": "integer"},
+						"cache_hit":             gin.H{"type": "boolean", "description": "Served from the gateway cache or with upstream cached prompt tokens."},
+						"spend":                 gin.H{"type": "number"},
+						"total_tokens":          gin.H{"type": "integer"},
+						"prompt_tokens":         gin.H{"type": "integer"},
+						"completion_tokens":     gin.H{"type": "integer"},
+						"cached_tokens":         gin.H{"type": "integer"},
+						"cache_creation_tokens": gin.H{"type": "integer"},
+						"reasoning_tokens":      gin.H{"type": "integer"},
+						"image_input_tokens":    gin.H{"type": "integer"},
+						"image_output_tokens":   gin.H{"type": "integer"},
+						"audio_input_tokens":    gin.H{"type": "integer"},
+						"audio_output_tokens":   gin.H{"type": "integer"},
+						"price_breakdown":       gin.H{"$ref": "#/components/schemas/PriceBreakdown"},
+						"spool_id":              gin.H{"type": "string"},
+						"create_time":           gin.H{"type": "string", "format": "date-time"},
+					},
+				},
+				"KeyInfo": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"key_id":          gin.H{"type": "integer", "example": 1},
+						"key_name":        gin.H{"type": "string", "example": "dev-key"},
+						"key":             gin.H{"type": "string", "example": "sk-a1b2...f3d2"},
+						"team_id":         gin.H{"type": "integer", "example": 1},
+						"organization_id": gin.H{"type": "integer", "example": 1},
+						"expire_time":     gin.H{"type": "string", "format": "date-time", "nullable": true},
+						"balance":         gin.H{"type": "number", "description": "Remaining balance net of spend not yet written and requests in flight.", "example": 38.75},
+						"total_spend":     gin.H{"type": "number", "example": 61.25},
+						"weekly_spend": gin.H{
+							"type": "object",
+							"properties": gin.H{
+								"spend":     gin.H{"type": "number", "example": 7.5},
+								"limit":     gin.H{"type": "number", "nullable": true, "description": "Null when the key has no weekly limit.", "example": 10},
+								"remaining": gin.H{"type": "number", "nullable": true, "example": 2.5},
+								"reset_at":  gin.H{"type": "string", "format": "date-time", "description": "Next Monday 00:00 UTC."},
+							},
+						},
+						"rate_limit": gin.H{
+							"type":        "object",
+							"description": "Current request window; zero limits mean unlimited. Calls to /v1/key/info and /v1/usage count toward the limit, so requests_used includes the call that returned it.",
+							"properties": gin.H{
+								"requests_per_minute": gin.H{"type": "integer", "example": 60},
+								"requests_used":       gin.H{"type": "integer", "example": 12},
+								"requests_remaining":  gin.H{"type": "integer", "example": 48},
+								"reset_seconds":       gin.H{"type": "integer", "example": 41},
+								"tokens_per_minute":   gin.H{"type": "integer", "example": 0},
+							},
+						},
+						"models": gin.H{"type": "array", "items": gin.H{"type": "string"}, "example": []string{"deepseek-v3"}},
+					},
+				},
+				"KeyUsage": gin.H{
+					"allOf": []gin.H{
+						{"$ref": "#/components/schemas/KeyInfo"},
+						{
+							"type": "object",
+							"properties": gin.H{
+								"start": gin.H{"type": "string", "format": "date-time"},
+								"end":   gin.H{"type": "string", "format": "date-time"},
+								"daily": gin.H{"type": "array", "items": gin.H{"$ref": "#/components/schemas/SpendReportRow"}, "description": "Spend per UTC day, oldest first; days without spend are omitted."},
+							},
+						},
+					},
+				},
+				"Pagination": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"limit":  gin.H{"type": "integer", "example": 100},
+						"offset": gin.H{"type": "integer", "example": 0},
+						"total":  gin.H{"type": "integer", "example": 3},
+					},
+				},
 			},
 		},
 		"paths": gin.H{
@@ -229,7 +493,8 @@ func handleOpenAPISpec(c *gin.Context) {
 							"balance_exhausted": {"value": gin.H{"code": "balance_exhausted", "error": "authorization key balance exhausted"}},
 						}),
 						"429": errorResponseWithExamples("Rate limited", map[string]gin.H{
-							"rate_limit_exceeded": {"value": gin.H{"code": "rate_limit_exceeded", "error": "reach rate limit"}},
+							"rate_limit_exceeded":         {"value": gin.H{"code": "rate_limit_exceeded", "error": "reach rate limit"}},
+							"weekly_spend_limit_exceeded": {"value": gin.H{"code": "weekly_spend_limit_exceeded", "error": "authorization key weekly spend limit exceeded", "reset_at": "2026-01-05T00:00:00Z"}},
 						}),
 						"503": errorResponseWithExamples("Authorization check unavailable", map[string]gin.H{
 							"authorization_check_unavailable": {"value": gin.H{"code": "authorization_check_unavailable", "error": "authorization check unavailable"}},
@@ -237,20 +502,153 @@ func handleOpenAPISpec(c *gin.Context) {
 					},
 				},
 			},
+			"/v1/key/info": keyUsagePath("Describe the current API key",
+				"Balance, total and weekly spend, the current request window and the accessible models of the calling key.",
+				"#/components/schemas/KeyInfo", nil),
+			"/v1/usage": keyUsagePath("Usage of the current API key",
+				"Everything /v1/key/info returns plus the key's spend per UTC day over a range of up to 90 days, read from the spend log so today is up to date.",
+				"#/components/schemas/KeyUsage", []gin.H{
+					{"name": "start", "in": "query", "schema": gin.H{"type": "string"}, "description": "Inclusive RFC 3339 time or YYYY-MM-DD, truncated to the UTC day; defaults to seven days before end.", "example": "2026-10-01"},
+					{"name": "end", "in": "query", "schema": gin.H{"type": "string"}, "description": "Exclusive RFC 3339 time or YYYY-MM-DD, truncated to the UTC day; defaults to the end of today.", "example": "2026-10-08"},
+				}),
 			"/v1/chat/completions":                      nativeProxyPath("Chat completions", "#/components/schemas/ChatCompletionRequest"),
 			"/v1/completions":                           nativeProxyPath("Text completions", "#/components/schemas/NativeModelRequest"),
 			"/v1/embeddings":                            nativeProxyPath("Embeddings", "#/components/schemas/NativeModelRequest"),
 			"/v1/messages":                              nativeProxyPath("Anthropic messages", "#/components/schemas/NativeModelRequest"),
+			"/v1/responses":                             nativeProxyPath("OpenAI responses", "#/components/schemas/NativeModelRequest"),
 			"/v1/admin/organizations":                   adminCollectionPath("Admin Organizations", "Organizations", "#/components/schemas/Organization", "#/components/schemas/OrganizationRequest"),
 			"/v1/admin/organizations/{organization_id}": adminItemPath("Admin Organizations", "Organization", "organization_id", "#/components/schemas/Organization", "#/components/schemas/OrganizationRequest"),
 			"/v1/admin/teams":                           adminCollectionPath("Admin Teams", "Teams", "#/components/schemas/Team", "#/components/schemas/TeamRequest"),
 			"/v1/admin/teams/{team_id}":                 adminItemPath("Admin Teams", "Team", "team_id", "#/components/schemas/Team", "#/components/schemas/TeamPatchRequest"),
 			"/v1/admin/keys":                            adminCollectionPath("Admin Keys", "Keys", "#/components/schemas/Key", "#/components/schemas/KeyRequest"),
 			"/v1/admin/keys/{key_id}":                   adminItemPath("Admin Keys", "Key", "key_id", "#/components/schemas/Key", "#/components/schemas/KeyPatchRequest"),
+			"/v1/admin/model-groups":                    adminCollectionPath("Admin Model Groups", "Model groups", "#/components/schemas/ModelGroup", "#/components/schemas/ModelGroupRequest"),
+			"/v1/admin/model-groups/{group_id}":         adminItemPath("Admin Model Groups", "Model group", "group_id", "#/components/schemas/ModelGroup", "#/components/schemas/ModelGroupRequest"),
+			"/v1/admin/model-groups/{group_id}/endpoints": withParentIDParam(
+				adminCollectionPath("Admin Model Groups", "Model endpoints", "#/components/schemas/ModelEndpoint", "#/components/schemas/ModelEndpointRequest"), "group_id"),
+			"/v1/admin/model-groups/{group_id}/endpoints/{endpoint_id}": withParentIDParam(
+				adminItemPath("Admin Model Groups", "Model endpoint", "endpoint_id", "#/components/schemas/ModelEndpoint", "#/components/schemas/ModelEndpointRequest"), "group_id"),
+			"/v1/admin/config/reload": gin.H{
+				"post": gin.H{
+					"summary":     "Reload model groups",
+					"description": "Re-reads model groups from config.yaml (syncing them to the database) or, when models.source is database, from the model tables, and swaps balancers without a restart. Service, secrets and admin settings still require a restart.",
+					"tags":        []string{"Admin Config"},
+					"security":    []gin.H{{"basicAuth": []string{}}},
+					"responses": gin.H{
+						"200": jsonResponse("Reloaded model groups", gin.H{
+							"type": "object",
+							"properties": gin.H{
+								"model_groups": gin.H{"type": "array", "items": gin.H{"type": "string"}, "example": []string{"deepseek-v3"}},
+							},
+						}),
+						"401": errorResponse("Unauthorized"),
+						"422": errorResponse("Config invalid or database sync failed; the running config is kept"),
+					},
+				},
+			},
+			"/v1/admin/spend": gin.H{
+				"get": gin.H{
+					"summary":     "Aggregate spend",
+					"description": "Spend, tokens, request count, average latency and cache-hit ratio grouped by any of key, team, organization, model_group, provider and day or hour (UTC). Reports that only group and filter by key, team, organization and whole days are answered from janus_key_spend_daily, which trails the spend log by up to a minute; pass source=log to read the log directly.",
+					"tags":        []string{"Admin Spend"},
+					"security":    []gin.H{{"basicAuth": []string{}}},
+					"parameters": append(spendQueryParameters(),
+						gin.H{"name": "group_by", "in": "query", "schema": gin.H{"type": "string"}, "description": "Comma-separated dimensions: key, team, organization, model_group, provider, day, hour.", "example": "model_group,day"},
+						gin.H{"name": "source", "in": "query", "schema": gin.H{"type": "string", "enum": []string{"log", "daily"}}, "description": "Force the spend log or the daily summary; chosen automatically by default."},
+					),
+					"responses": gin.H{
+						"200": jsonResponse("Spend report", gin.H{
+							"type": "object",
+							"properties": gin.H{
+								"data":       gin.H{"type": "array", "items": gin.H{"$ref": "#/components/schemas/SpendReportRow"}},
+								"start":      gin.H{"type": "string", "format": "date-time"},
+								"end":        gin.H{"type": "string", "format": "date-time"},
+								"group_by":   gin.H{"type": "array", "items": gin.H{"type": "string"}},
+								"source":     gin.H{"type": "string", "example": "daily"},
+								"pagination": gin.H{"$ref": "#/components/schemas/Pagination"},
+							},
+						}),
+						"400": errorResponse("Bad request"),
+						"401": errorResponse("Unauthorized"),
+					},
+				},
+			},
+			"/v1/admin/spend/logs": gin.H{
+				"get": gin.H{
+					"summary":    "List spend records",
+					"tags":       []string{"Admin Spend"},
+					"security":   []gin.H{{"basicAuth": []string{}}},
+					"parameters": spendQueryParameters(),
+					"responses": gin.H{
+						"200": jsonResponse("Spend records, newest first", gin.H{
+							"type": "object",
+							"properties": gin.H{
+								"data":       gin.H{"type": "array", "items": gin.H{"$ref": "#/components/schemas/SpendRecord"}},
+								"start":      gin.H{"type": "string", "format": "date-time"},
+								"end":        gin.H{"type": "string", "format": "date-time"},
+								"pagination": gin.H{"$ref": "#/components/schemas/Pagination"},
+							},
+						}),
+						"400": errorResponse("Bad request"),
+						"401": errorResponse("Unauthorized"),
+					},
+				},
+			},
 		},
 	})
 }
 
+// spendQueryParameters are the range, filter and page parameters shared by spend endpoints.
+func spendQueryParameters() []gin.H {
+	return []gin.H{
+		{"name": "start", "in": "query", "schema": gin.H{"type": "string"}, "description": "Inclusive RFC 3339 time or YYYY-MM-DD (UTC); defaults to seven days before end.", "example": "2026-10-01"},
+		{"name": "end", "in": "query", "schema": gin.H{"type": "string"}, "description": "Exclusive RFC 3339 time or YYYY-MM-DD (UTC); defaults to the end of today.", "example": "2026-10-08"},
+		{"name": "key_id", "in": "query", "schema": gin.H{"type": "integer"}},
+		{"name": "team_id", "in": "query", "schema": gin.H{"type": "integer"}},
+		{"name": "organization_id", "in": "query", "schema": gin.H{"type": "integer"}},
+		{"name": "model_group", "in": "query", "schema": gin.H{"type": "string"}},
+		{"name": "provider", "in": "query", "schema": gin.H{"type": "string"}},
+		{"name": "limit", "in": "query", "schema": gin.H{"type": "integer", "default": spend.DefaultReportLimit, "maximum": spend.MaxReportLimit}},
+		{"name": "offset", "in": "query", "schema": gin.H{"type": "integer", "default": 0}},
+	}
+}
+
+// keyUsagePath documents a read-only endpoint that reports on the calling API key.
+func keyUsagePath(summary string, description string, schemaRef string, parameters []gin.H) gin.H {
+	get := gin.H{
+		"summary":     summary,
+		"description": description,
+		"tags":        []string{"LLM API"},
+		"security":    []gin.H{{"bearerAuth": []string{}}},
+		"responses": gin.H{
+			"200": jsonResponse(summary, gin.H{"$ref": schemaRef}),
+			"401": errorResponseWithExamples("Unauthorized", map[string]gin.H{
+				"missing_authorization_header": {"value": gin.H{"code": "missing_authorization_header", "error": "no authorization header"}},
+				"invalid_authorization_key":    {"value": gin.H{"code": "invalid_authorization_key", "error": "invalid authorization key"}},
+				"authorization_key_expired":    {"value": gin.H{"code": "authorization_key_expired", "error": "authorization key expired"}},
+			}),
+			"402": errorResponseWithExamples("Balance exhausted", map[string]gin.H{
+				"balance_exhausted": {"value": gin.H{"code": "balance_exhausted", "error": "authorization key balance exhausted"}},
+			}),
+			"429": errorResponseWithExamples("Rate limited", map[string]gin.H{
+				"rate_limit_exceeded":         {"value": gin.H{"code": "rate_limit_exceeded", "error": "reach rate limit"}},
+				"weekly_spend_limit_exceeded": {"value": gin.H{"code": "weekly_spend_limit_exceeded", "error": "authorization key weekly spend limit exceeded", "reset_at": "2026-01-05T00:00:00Z"}},
+			}),
+			"503": errorResponseWithExamples("Unavailable", map[string]gin.H{
+				"authorization_check_unavailable": {"value": gin.H{"code": "authorization_check_unavailable", "error": "authorization check unavailable"}},
+				"usage_unavailable":               {"value": gin.H{"code": "usage_unavailable", "error": "usage unavailable"}},
+			}),
+		},
+	}
+	if len(parameters) > 0 {
+		get["parameters"] = parameters
+		get["responses"].(gin.H)["400"] = errorResponseWithExamples("Bad request", map[string]gin.H{
+			"invalid_usage_range": {"value": gin.H{"code": "invalid_usage_range", "error": "range must not exceed 90 days"}},
+		})
+	}
+	return gin.H{"get": get}
+}
+
 func nativeProxyPath(summary string, schemaRef string) gin.H {
 	return gin.H{
 		"post": gin.H{
@@ -277,13 +675,16 @@ func nativeProxyPath(summary string, schemaRef string) gin.H {
 					"authorization_key_expired":    {"value": gin.H{"code": "authorization_key_expired", "error": "authorization key expired"}},
 				}),
 				"402": errorResponseWithExamples("Balance exhausted", map[string]gin.H{
-					"balance_exhausted": {"value": gin.H{"code": "balance_exhausted", "error": "authorization key balance exhausted"}},
+					"balance_exhausted":    {"value": gin.H{"code": "balance_exhausted", "error": "authorization key balance exhausted"}},
+					"insufficient_balance": {"value": gin.H{"code": "insufficient_balance", "error": "authorization key balance cannot cover the estimated request cost"}},
 				}),
 				"403": errorResponseWithExamples("Forbidden", map[string]gin.H{
 					"model_not_allowed": {"value": gin.H{"code": "model_not_allowed", "error": "invalid request model"}},
 				}),
 				"429": errorResponseWithExamples("Rate limited", map[string]gin.H{
-					"rate_limit_exceeded": {"value": gin.H{"code": "rate_limit_exceeded", "error": "reach rate limit"}},
+					"rate_limit_exceeded":         {"value": gin.H{"code": "rate_limit_exceeded", "error": "reach rate limit"}},
+					"weekly_spend_limit_exceeded": {"value": gin.H{"code": "weekly_spend_limit_exceeded", "error": "authorization key weekly spend limit exceeded", "reset_at": "2026-01-05T00:00:00Z"}},
+					"token_rate_limit_exceeded":   {"value": gin.H{"code": "token_rate_limit_exceeded", "error": "reach token rate limit"}},
 				}),
 				"502": errorResponse("Upstream failed"),
 				"503": errorResponseWithExamples("Authorization check unavailable", map[string]gin.H{
@@ -413,6 +814,22 @@ func adminItemPath(tag string, name string, paramName string, responseSchemaRef
 	}
 }
 
+// withParentIDParam adds a path parameter for the owning resource to every operation.
+func withParentIDParam(path gin.H, paramName string) gin.H {
+	param := gin.H{
+		"name":     paramName,
+		"in":       "path",
+		"required": true,
+		"schema":   gin.H{"type": "integer"},
+	}
+	for _, op := range path {
+		operation := op.(gin.H)
+		params, _ := operation["parameters"].([]gin.H)
+		operation["parameters"] = append([]gin.H{param}, params...)
+	}
+	return path
+}
+
 const swaggerHTML = `<!doctype html>
 <html lang="en">
 <head>
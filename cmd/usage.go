@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/proxy"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+// maxUsageDays bounds the daily breakdown a key holder can request at once.
+const maxUsageDays = 90
+
+type keyInfoResponse struct {
+	KeyID          int              `json:"key_id"`
+	KeyName        string           `json:"key_name"`
+	Key            string           `json:"key"`
+	TeamID         int              `json:"team_id"`
+	OrganizationID int              `json:"organization_id"`
+	ExpireTime     *time.Time       `json:"expire_time"`
+	Balance        float64          `json:"balance"`
+	TotalSpend     float64          `json:"total_spend"`
+	WeeklySpend    weeklySpendUsage `json:"weekly_spend"`
+	RateLimit      rateLimitUsage   `json:"rate_limit"`
+	Models         []string         `json:"models"`
+}
+
+// weeklySpendUsage compares the current calendar week's spend with the key's limit. Limit
+// and Remaining are null when the key has no weekly limit.
+type weeklySpendUsage struct {
+	Spend     float64   `json:"spend"`
+	Limit     *float64  `json:"limit"`
+	Remaining *float64  `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// rateLimitUsage reports the request window as the key's limiter sees it. Zero limits mean
+// unlimited. The usage endpoints pass through the same limiter, so RequestsUsed includes the
+// call reporting it.
+type rateLimitUsage struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	RequestsUsed      int `json:"requests_used"`
+	RequestsRemaining int `json:"requests_remaining"`
+	ResetSeconds      int `json:"reset_seconds"`
+	TokensPerMinute   int `json:"tokens_per_minute"`
+}
+
+type keyUsageResponse struct {
+	keyInfoResponse
+	Start time.Time         `json:"start"`
+	End   time.Time         `json:"end"`
+	Daily []spend.ReportRow `json:"daily"`
+}
+
+func getKeyInfo(p *proxy.Proxy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyInfo, ok := keyFromContext(c)
+		if !ok {
+			return
+		}
+		info, err := buildKeyInfo(p, keyInfo, time.Now())
+		if err != nil {
+			respondAPIError(c, http.StatusServiceUnavailable, "usage_unavailable", "usage unavailable")
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+func getKeyUsage(p *proxy.Proxy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyInfo, ok := keyFromContext(c)
+		if !ok {
+			return
+		}
+		start, end, err := parseUsageRange(c, time.Now())
+		if err != nil {
+			respondAPIError(c, http.StatusBadRequest, "invalid_usage_range", err.Error())
+			return
+		}
+
+		info, err := buildKeyInfo(p, keyInfo, time.Now())
+		if err != nil {
+			respondAPIError(c, http.StatusServiceUnavailable, "usage_unavailable", "usage unavailable")
+			return
+		}
+		daily, err := loadDailySpend(keyInfo.KeyId, start, end)
+		if err != nil {
+			respondAPIError(c, http.StatusServiceUnavailable, "usage_unavailable", "usage unavailable")
+			return
+		}
+		if daily == nil {
+			daily = []spend.ReportRow{}
+		}
+		c.JSON(http.StatusOK, keyUsageResponse{keyInfoResponse: info, Start: start, End: end, Daily: daily})
+	}
+}
+
+func keyFromContext(c *gin.Context) (auth.Key, bool) {
+	value, ok := c.Get("key")
+	if !ok {
+		respondAPIError(c, http.StatusUnauthorized, "missing_authorization_header", "key context not found")
+		return auth.Key{}, false
+	}
+	return value.(auth.Key), true
+}
+
+// buildKeyInfo reports the key as this replica sees it: the balance includes spend still
+// waiting to be written, and the weekly spend comes from the tracker used to enforce the limit.
+func buildKeyInfo(p *proxy.Proxy, keyInfo auth.Key, now time.Time) (keyInfoResponse, error) {
+	info := keyInfoResponse{
+		KeyID:          keyInfo.KeyId,
+		KeyName:        keyInfo.KeyName,
+		Key:            auth.RedactKeyContent(keyInfo.KeyContent),
+		TeamID:         keyInfo.TeamId,
+		OrganizationID: keyInfo.OrganizationId,
+		Balance:        keyInfo.Balance,
+		TotalSpend:     keyInfo.TotalSpend + spend.Balances.Unflushed(keyInfo.KeyId),
+		Models:         p.AccessibleModelGroups(keyInfo.ModelList),
+	}
+	if !keyInfo.ExpireTime.IsZero() {
+		expireTime := keyInfo.ExpireTime.UTC()
+		info.ExpireTime = &expireTime
+	}
+	if available, ok := spend.Balances.Available(keyInfo.KeyId); ok {
+		info.Balance = available
+	}
+
+	weekly, seeded := spend.WeeklySpend.Spend(keyInfo.KeyId, now)
+	if !seeded {
+		total, err := loadWeeklySpend(keyInfo.KeyId, spend.WeekStart(now))
+		if err != nil {
+			return keyInfoResponse{}, err
+		}
+		weekly = total
+	}
+	info.WeeklySpend = weeklySpendUsage{Spend: weekly, ResetAt: spend.NextWeekStart(now)}
+	if keyInfo.SpendLimitPerWeek > 0 {
+		limit := keyInfo.SpendLimitPerWeek
+		remaining := max(limit-weekly, 0)
+		info.WeeklySpend.Limit = &limit
+		info.WeeklySpend.Remaining = &remaining
+	}
+
+	info.RateLimit = rateLimitUsage{
+		RequestsPerMinute: keyInfo.RequestPerMinute,
+		TokensPerMinute:   keyInfo.TokensPerMinute,
+	}
+	if keyInfo.RequestPerMinute > 0 {
+		used, resetAfter := proxy.RequestRingUsage(keyInfo.KeyContent, keyInfo.RequestPerMinute, now)
+		info.RateLimit.RequestsUsed = used
+		info.RateLimit.RequestsRemaining = max(keyInfo.RequestPerMinute-used, 0)
+		if resetAfter > 0 {
+			info.RateLimit.ResetSeconds = retryAfterSeconds(resetAfter)
+		}
+	}
+	return info, nil
+}
+
+// parseUsageRange reads the daily breakdown range. Both ends are truncated to UTC days and
+// the range defaults to the last seven days including today.
+func parseUsageRange(c *gin.Context, now time.Time) (time.Time, time.Time, error) {
+	end, err := parseReportTime(c.Query("end"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("end: %w", err)
+	}
+	if end.IsZero() {
+		end = now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	}
+	start, err := parseReportTime(c.Query("start"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("start: %w", err)
+	}
+	if start.IsZero() {
+		start = end.AddDate(0, 0, -defaultSpendReportDays)
+	}
+	start, end = start.Truncate(24*time.Hour), end.Truncate(24*time.Hour)
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be a day before end")
+	}
+	if end.Sub(start) > maxUsageDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("range must not exceed %d days", maxUsageDays)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/proxy"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+func TestGetKeyUsageReportsLimitsAndDailySpend(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousWeekly, previousDaily := loadWeeklySpend, loadDailySpend
+	t.Cleanup(func() { loadWeeklySpend, loadDailySpend = previousWeekly, previousDaily })
+	loadWeeklySpend = func(keyID int, since time.Time) (float64, error) { return 7.5, nil }
+	var dailyStart, dailyEnd time.Time
+	loadDailySpend = func(keyID int, start, end time.Time) ([]spend.ReportRow, error) {
+		dailyStart, dailyEnd = start, end
+		day := start
+		return []spend.ReportRow{{Period: &day, Spend: 1.25, RequestCount: 3}}, nil
+	}
+
+	p := proxy.NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{Name: "gpt-4o", Strategy: "round-robin"})
+	p.RegisterModelGroup(&models.ModelGroup{Name: "claude-3-sonnet", Strategy: "round-robin"})
+
+	key := auth.Key{
+		KeyId:             9201,
+		KeyName:           "usage-test",
+		KeyContent:        "sk-usage-test-0000",
+		ModelList:         auth.StringSlice{"gpt-4o"},
+		Balance:           40,
+		TotalSpend:        60,
+		RequestPerMinute:  5,
+		SpendLimitPerWeek: 10,
+	}
+	t.Cleanup(func() { proxy.RemoveRequestRing(key.KeyContent) })
+	ring := proxy.GetOrCreateRequestRing(key.KeyContent, key.RequestPerMinute)
+	ring.AllowAt(time.Now())
+	ring.AllowAt(time.Now())
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/usage?start=2026-10-01&end=2026-10-03", nil)
+	ctx.Set("key", key)
+	getKeyUsage(p)(ctx)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp keyUsageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Key != "sk-usag...0000" || resp.Balance != 40 || resp.TotalSpend != 60 {
+		t.Fatalf("unexpected key fields %+v", resp.keyInfoResponse)
+	}
+	if resp.WeeklySpend.Spend != 7.5 || resp.WeeklySpend.Limit == nil || *resp.WeeklySpend.Remaining != 2.5 {
+		t.Fatalf("unexpected weekly spend %+v", resp.WeeklySpend)
+	}
+	if resp.RateLimit.RequestsUsed != 2 || resp.RateLimit.RequestsRemaining != 3 || resp.RateLimit.ResetSeconds <= 0 {
+		t.Fatalf("unexpected rate limit %+v", resp.RateLimit)
+	}
+	if len(resp.Models) != 1 || resp.Models[0] != "gpt-4o" {
+		t.Fatalf("expected only the granted model, got %v", resp.Models)
+	}
+	if !dailyStart.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) || !dailyEnd.Equal(time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected daily range %v - %v", dailyStart, dailyEnd)
+	}
+	if len(resp.Daily) != 1 || resp.Daily[0].Spend != 1.25 {
+		t.Fatalf("unexpected daily breakdown %+v", resp.Daily)
+	}
+	if used, _ := proxy.RequestRingUsage(key.KeyContent, key.RequestPerMinute, time.Now()); used != 2 {
+		t.Fatalf("reading usage should not consume a request, got %d used", used)
+	}
+}
+
+func TestParseUsageRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2026, 10, 17, 15, 0, 0, 0, time.UTC)
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/usage", nil)
+	start, end, err := parseUsageRange(ctx, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !start.Equal(time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected default range %v - %v", start, end)
+	}
+
+	for _, rawQuery := range []string{"start=2026-10-05&end=2026-10-05", "start=2026-01-01&end=2026-10-01", "end=tomorrow"} {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/usage?"+rawQuery, nil)
+		if _, _, err := parseUsageRange(ctx, now); err == nil {
+			t.Errorf("%s: expected an error", rawQuery)
+		}
+	}
+}
@@ -25,6 +25,9 @@ type Key struct {
 	TotalSpend        float64 `gorm:"column:total_spend"`
 	RequestPerMinute  int     `gorm:"column:request_per_minute"`
 	SpendLimitPerWeek float64 `gorm:"column:spend_limit_per_week"`
+	TokensPerMinute   int     `gorm:"column:tokens_per_minute"`
+	// TeamTokensPerMinute is read from the key's team and never written back.
+	TeamTokensPerMinute int `gorm:"column:team_tokens_per_minute;->"`
 
 	CreateTime time.Time `gorm:"column:create_time"`
 	ExpireTime time.Time `gorm:"column:expire_time"`
@@ -280,6 +283,6 @@ func DeleteKeyRecord(keyContent string) {
 
 func keyQuery(db *gorm.DB) *gorm.DB {
 	return db.Table("janus_auth_key").
-		Select("janus_auth_key.*, janus_auth_team.model_list AS team_model_list").
+		Select("janus_auth_key.*, janus_auth_team.model_list AS team_model_list, janus_auth_team.tokens_per_minute AS team_tokens_per_minute").
 		Joins("JOIN janus_auth_team ON janus_auth_team.team_id = janus_auth_key.team_id")
 }
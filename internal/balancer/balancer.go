@@ -35,6 +35,18 @@ type Observer interface {
 	Observe(model *models.ModelConfig, latency time.Duration, success bool)
 }
 
+// InflightTracker is told when a request to an endpoint starts and when it finishes.
+type InflightTracker interface {
+	Acquire(model *models.ModelConfig)
+	Release(model *models.ModelConfig)
+}
+
+// StateInheritor carries per-endpoint state, such as latency stats or in-flight counts,
+// over from the balancer it replaces when a model group is reloaded.
+type StateInheritor interface {
+	InheritState(previous Balancer)
+}
+
 func New(strategy string) Balancer {
 	switch NormalizeStrategy(strategy) {
 	case "weighted":
@@ -43,6 +55,8 @@ func New(strategy string) Balancer {
 		return NewLatencyBalancer()
 	case "client-sticky":
 		return NewClientStickyBalancer()
+	case "least-inflight":
+		return NewLeastInflightBalancer()
 	default:
 		return NewRoundRobinBalancer()
 	}
@@ -61,6 +75,8 @@ func NormalizeStrategy(strategy string) string {
 		return "latency"
 	case "client-sticky", "sticky", "client-sticky-hash", "sticky-hash":
 		return "client-sticky"
+	case "least-inflight", "leastinflight", "least-connections", "least-conn":
+		return "least-inflight"
 	default:
 		return "round-robin"
 	}
@@ -307,6 +323,170 @@ func (lb *LatencyBalancer) Observe(model *models.ModelConfig, latency time.Durat
 	lb.stats[key] = stat
 }
 
+// InheritState copies latency stats for endpoints that the previous balancer also served.
+func (lb *LatencyBalancer) InheritState(previous Balancer) {
+	prev, ok := previous.(*LatencyBalancer)
+	if !ok || prev == lb {
+		return
+	}
+	prev.mu.RLock()
+	stats := make(map[string]latencyStat, len(prev.stats))
+	for key, stat := range prev.stats {
+		stats[key] = stat
+	}
+	prev.mu.RUnlock()
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	for _, model := range lb.models {
+		if stat, ok := stats[modelKey(model)]; ok {
+			lb.stats[modelKey(model)] = stat
+		}
+	}
+}
+
+// LeastInflightBalancer picks the endpoint with the fewest in-flight requests relative to
+// its weight, so long-running streams spread across endpoints by actual load.
+type LeastInflightBalancer struct {
+	models   []*models.ModelConfig
+	inflight *inflightCounts
+	index    uint64
+	mu       sync.RWMutex
+}
+
+// inflightCounts is shared with the balancer that replaces this one on reload, so requests
+// still running on the old balancer release the counts the new one sees.
+type inflightCounts struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewLeastInflightBalancer() *LeastInflightBalancer {
+	return &LeastInflightBalancer{
+		models:   make([]*models.ModelConfig, 0),
+		inflight: &inflightCounts{counts: make(map[string]int)},
+	}
+}
+
+func (lb *LeastInflightBalancer) Next(ctx SelectionContext) *models.ModelConfig {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	if len(lb.models) == 0 {
+		return nil
+	}
+
+	// Rotate the starting point so endpoints with equal load share traffic.
+	start := int(atomic.AddUint64(&lb.index, 1) % uint64(len(lb.models)))
+	lb.inflight.mu.Lock()
+	defer lb.inflight.mu.Unlock()
+	var selected *models.ModelConfig
+	selectedInflight, selectedWeight := 0, 1
+	for offset := 0; offset < len(lb.models); offset++ {
+		model := lb.models[(start+offset)%len(lb.models)]
+		inflight, weight := lb.inflight.counts[modelKey(model)], inflightWeight(model)
+		// inflight/weight < selectedInflight/selectedWeight, without division.
+		if selected == nil || inflight*selectedWeight < selectedInflight*weight {
+			selected = model
+			selectedInflight, selectedWeight = inflight, weight
+		}
+	}
+	return selected
+}
+
+func (lb *LeastInflightBalancer) Acquire(model *models.ModelConfig) {
+	if model == nil {
+		return
+	}
+	counts := lb.counts()
+	counts.mu.Lock()
+	defer counts.mu.Unlock()
+	counts.counts[modelKey(model)]++
+}
+
+func (lb *LeastInflightBalancer) Release(model *models.ModelConfig) {
+	if model == nil {
+		return
+	}
+	counts := lb.counts()
+	counts.mu.Lock()
+	defer counts.mu.Unlock()
+	key := modelKey(model)
+	if counts.counts[key] <= 1 {
+		delete(counts.counts, key)
+		return
+	}
+	counts.counts[key]--
+}
+
+func (lb *LeastInflightBalancer) Inflight(model *models.ModelConfig) int {
+	counts := lb.counts()
+	counts.mu.Lock()
+	defer counts.mu.Unlock()
+	return counts.counts[modelKey(model)]
+}
+
+// InheritState shares the previous balancer's in-flight counts.
+func (lb *LeastInflightBalancer) InheritState(previous Balancer) {
+	prev, ok := previous.(*LeastInflightBalancer)
+	if !ok || prev == lb {
+		return
+	}
+	counts := prev.counts()
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.inflight = counts
+}
+
+func (lb *LeastInflightBalancer) counts() *inflightCounts {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return lb.inflight
+}
+
+func (lb *LeastInflightBalancer) AddModel(model *models.ModelConfig) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.models = append(lb.models, model)
+}
+
+func (lb *LeastInflightBalancer) Models() []*models.ModelConfig {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	out := make([]*models.ModelConfig, len(lb.models))
+	copy(out, lb.models)
+	return out
+}
+
+func (lb *LeastInflightBalancer) RemoveModel(modelName string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	for i, model := range lb.models {
+		if model.Name == modelName {
+			lb.inflight.mu.Lock()
+			delete(lb.inflight.counts, modelKey(model))
+			lb.inflight.mu.Unlock()
+			lb.models = append(lb.models[:i], lb.models[i+1:]...)
+			break
+		}
+	}
+}
+
+func (lb *LeastInflightBalancer) Size() int {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return len(lb.models)
+}
+
+func inflightWeight(model *models.ModelConfig) int {
+	if model == nil || model.Weight <= 0 {
+		return 1
+	}
+	return model.Weight
+}
+
 type ClientStickyBalancer struct {
 	models []*models.ModelConfig
 	mu     sync.RWMutex
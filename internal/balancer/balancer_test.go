@@ -83,11 +83,13 @@ func TestLatencyBalancerFallsBackWithoutHistory(t *testing.T) {
 
 func TestNewBalancerSupportsConfiguredStrategies(t *testing.T) {
 	tests := map[string]string{
-		"":              "*balancer.RoundRobinBalancer",
-		"round_robin":   "*balancer.RoundRobinBalancer",
-		"weighted":      "*balancer.WeightedBalancer",
-		"latency-based": "*balancer.LatencyBalancer",
-		"client-sticky": "*balancer.ClientStickyBalancer",
+		"":               "*balancer.RoundRobinBalancer",
+		"round_robin":    "*balancer.RoundRobinBalancer",
+		"weighted":       "*balancer.WeightedBalancer",
+		"latency-based":  "*balancer.LatencyBalancer",
+		"client-sticky":  "*balancer.ClientStickyBalancer",
+		"least-inflight": "*balancer.LeastInflightBalancer",
+		"least_inflight": "*balancer.LeastInflightBalancer",
 	}
 
 	for strategy, want := range tests {
@@ -108,7 +110,60 @@ func typeName(value interface{}) string {
 		return "*balancer.LatencyBalancer"
 	case *ClientStickyBalancer:
 		return "*balancer.ClientStickyBalancer"
+	case *LeastInflightBalancer:
+		return "*balancer.LeastInflightBalancer"
 	default:
 		return "unknown"
 	}
 }
+
+func TestLeastInflightBalancerPrefersLeastLoadedByWeight(t *testing.T) {
+	small := &models.ModelConfig{Name: "small", BaseURL: "http://small", Weight: 1}
+	large := &models.ModelConfig{Name: "large", BaseURL: "http://large", Weight: 3}
+	blcr := NewLeastInflightBalancer()
+	blcr.AddModel(small)
+	blcr.AddModel(large)
+
+	picks := map[string]int{}
+	for i := 0; i < 8; i++ {
+		model := blcr.Next(SelectionContext{})
+		blcr.Acquire(model)
+		picks[model.Name]++
+	}
+	if picks["small"] != 2 || picks["large"] != 6 {
+		t.Fatalf("expected in-flight requests to follow weights 1:3, got %v", picks)
+	}
+
+	for i := 0; i < 6; i++ {
+		blcr.Release(large)
+	}
+	if got := blcr.Next(SelectionContext{}); got != large {
+		t.Fatalf("expected drained endpoint to be picked, got %q", got.Name)
+	}
+	if blcr.Inflight(large) != 0 || blcr.Inflight(small) != 2 {
+		t.Fatalf("unexpected in-flight counts: large=%d small=%d", blcr.Inflight(large), blcr.Inflight(small))
+	}
+}
+
+func TestLeastInflightInheritStateSharesCounts(t *testing.T) {
+	a := &models.ModelConfig{Name: "a", BaseURL: "http://a"}
+	b := &models.ModelConfig{Name: "b", BaseURL: "http://b"}
+	previous := NewLeastInflightBalancer()
+	previous.AddModel(a)
+	previous.AddModel(b)
+	previous.Acquire(a)
+
+	replacement := NewLeastInflightBalancer()
+	replacement.AddModel(a)
+	replacement.AddModel(b)
+	replacement.InheritState(previous)
+	if got := replacement.Inflight(a); got != 1 {
+		t.Fatalf("expected inherited in-flight count 1, got %d", got)
+	}
+
+	// The request that started before the reload finishes on the old balancer.
+	previous.Release(a)
+	if got := replacement.Inflight(a); got != 0 {
+		t.Fatalf("expected release on the old balancer to be visible, got %d", got)
+	}
+}
@@ -0,0 +1,292 @@
+package balancer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// AvailabilityChecker reports whether an endpoint may receive traffic right now.
+type AvailabilityChecker interface {
+	Available(model *models.ModelConfig) bool
+}
+
+// TrialGate admits attempts to endpoints whose breaker is half-open one at a time.
+type TrialGate interface {
+	// Admit reports whether an attempt may go to model now and whether it is the half-open
+	// trial. A trial lasts until an outcome is observed or EndTrial is called.
+	Admit(model *models.ModelConfig) (admitted bool, trial bool)
+	EndTrial(model *models.ModelConfig)
+}
+
+// CircuitBreaker opens after consecutive failures, lets a single trial request through once
+// the cooldown has passed (half-open), and closes on the first success after that. While the
+// trial is in flight the endpoint stays unavailable to everyone else; a trial that reports no
+// outcome within another cooldown is abandoned so the next request can try.
+type CircuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu             sync.Mutex
+	state          BreakerState
+	failures       int
+	openedAt       time.Time
+	trialInFlight  bool
+	trialStartedAt time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, cooldown time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = defaultBreakerFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+	}
+}
+
+// AvailableAt reports whether a request could be admitted at now, without claiming the
+// half-open trial.
+func (cb *CircuitBreaker) AvailableAt(now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.stateLocked(now) {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		return !cb.trialInFlight
+	default:
+		return false
+	}
+}
+
+// AdmitAt reports whether a request may be sent at now and whether it is the half-open
+// trial. The trial ends with the next RecordAt or EndTrial.
+func (cb *CircuitBreaker) AdmitAt(now time.Time) (admitted bool, trial bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.stateLocked(now) {
+	case BreakerClosed:
+		return true, false
+	case BreakerHalfOpen:
+		if cb.trialInFlight {
+			return false, false
+		}
+		cb.trialInFlight = true
+		cb.trialStartedAt = now
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// EndTrial frees the half-open trial when its request ended without an outcome that says
+// anything about the endpoint, such as a rejected request body.
+func (cb *CircuitBreaker) EndTrial() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trialInFlight = false
+}
+
+func (cb *CircuitBreaker) StateAt(now time.Time) BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.stateLocked(now)
+}
+
+func (cb *CircuitBreaker) stateLocked(now time.Time) BreakerState {
+	if cb.state == BreakerOpen && !now.Before(cb.openedAt.Add(cb.cooldown)) {
+		cb.state = BreakerHalfOpen
+		cb.trialInFlight = false
+	}
+	if cb.trialInFlight && !now.Before(cb.trialStartedAt.Add(cb.cooldown)) {
+		cb.trialInFlight = false
+	}
+	return cb.state
+}
+
+func (cb *CircuitBreaker) RecordAt(now time.Time, success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trialInFlight = false
+
+	if success {
+		cb.state = BreakerClosed
+		cb.failures = 0
+		return
+	}
+
+	switch cb.stateLocked(now) {
+	case BreakerHalfOpen:
+		cb.state = BreakerOpen
+		cb.openedAt = now
+	case BreakerClosed:
+		cb.failures++
+		if cb.failures >= cb.failureThreshold {
+			cb.state = BreakerOpen
+			cb.openedAt = now
+		}
+	}
+}
+
+// BreakerSet holds one circuit breaker per upstream endpoint, shared by every group using it.
+type BreakerSet struct {
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+func NewBreakerSet(failureThreshold int, cooldown time.Duration) *BreakerSet {
+	return &BreakerSet{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		breakers:         make(map[string]*CircuitBreaker),
+	}
+}
+
+func (s *BreakerSet) Breaker(model *models.ModelConfig) *CircuitBreaker {
+	key := modelKey(model)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	breaker, ok := s.breakers[key]
+	if !ok {
+		breaker = NewCircuitBreaker(s.failureThreshold, s.cooldown)
+		s.breakers[key] = breaker
+	}
+	return breaker
+}
+
+func (s *BreakerSet) Available(model *models.ModelConfig) bool {
+	if model == nil {
+		return false
+	}
+	return s.Breaker(model).AvailableAt(time.Now())
+}
+
+func (s *BreakerSet) Admit(model *models.ModelConfig) (admitted bool, trial bool) {
+	if model == nil {
+		return false, false
+	}
+	return s.Breaker(model).AdmitAt(time.Now())
+}
+
+func (s *BreakerSet) EndTrial(model *models.ModelConfig) {
+	if model == nil {
+		return
+	}
+	s.Breaker(model).EndTrial()
+}
+
+func (s *BreakerSet) Record(model *models.ModelConfig, success bool) {
+	if model == nil {
+		return
+	}
+	s.Breaker(model).RecordAt(time.Now(), success)
+}
+
+// CircuitBreakerBalancer skips endpoints whose breaker is open and feeds request outcomes
+// into the breakers before passing them on to the wrapped balancer.
+type CircuitBreakerBalancer struct {
+	Balancer
+	breakers *BreakerSet
+}
+
+func WithCircuitBreaker(inner Balancer, breakers *BreakerSet) *CircuitBreakerBalancer {
+	return &CircuitBreakerBalancer{Balancer: inner, breakers: breakers}
+}
+
+func (cb *CircuitBreakerBalancer) Next(ctx SelectionContext) *models.ModelConfig {
+	attempts := cb.Balancer.Size()
+	for i := 0; i < attempts; i++ {
+		model := cb.Balancer.Next(ctx)
+		if model == nil {
+			return nil
+		}
+		if cb.breakers.Available(model) {
+			return model
+		}
+	}
+	for _, model := range cb.Balancer.Models() {
+		if cb.breakers.Available(model) {
+			return model
+		}
+	}
+	return nil
+}
+
+// Unwrap returns the strategy balancer behind the breaker.
+func (cb *CircuitBreakerBalancer) Unwrap() Balancer {
+	return cb.Balancer
+}
+
+func (cb *CircuitBreakerBalancer) Available(model *models.ModelConfig) bool {
+	return cb.breakers.Available(model)
+}
+
+func (cb *CircuitBreakerBalancer) Admit(model *models.ModelConfig) (admitted bool, trial bool) {
+	return cb.breakers.Admit(model)
+}
+
+func (cb *CircuitBreakerBalancer) EndTrial(model *models.ModelConfig) {
+	cb.breakers.EndTrial(model)
+}
+
+func (cb *CircuitBreakerBalancer) Observe(model *models.ModelConfig, latency time.Duration, success bool) {
+	cb.breakers.Record(model, success)
+	if observer, ok := cb.Balancer.(Observer); ok {
+		observer.Observe(model, latency, success)
+	}
+}
+
+func (cb *CircuitBreakerBalancer) Acquire(model *models.ModelConfig) {
+	if tracker, ok := cb.Balancer.(InflightTracker); ok {
+		tracker.Acquire(model)
+	}
+}
+
+func (cb *CircuitBreakerBalancer) Release(model *models.ModelConfig) {
+	if tracker, ok := cb.Balancer.(InflightTracker); ok {
+		tracker.Release(model)
+	}
+}
+
+// InheritState passes the previous strategy balancer to the wrapped one. Breakers live in the
+// shared BreakerSet and need no carrying over.
+func (cb *CircuitBreakerBalancer) InheritState(previous Balancer) {
+	if wrapped, ok := previous.(*CircuitBreakerBalancer); ok {
+		previous = wrapped.Balancer
+	}
+	if inheritor, ok := cb.Balancer.(StateInheritor); ok {
+		inheritor.InheritState(previous)
+	}
+}
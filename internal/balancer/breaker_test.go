@@ -0,0 +1,111 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+func TestCircuitBreakerOpensHalfOpensAndCloses(t *testing.T) {
+	breaker := NewCircuitBreaker(2, 10*time.Second)
+	start := time.Unix(1700000000, 0)
+
+	breaker.RecordAt(start, false)
+	if !breaker.AvailableAt(start) {
+		t.Fatal("expected breaker to stay closed below the failure threshold")
+	}
+	breaker.RecordAt(start, false)
+	if breaker.StateAt(start) != BreakerOpen || breaker.AvailableAt(start.Add(5*time.Second)) {
+		t.Fatal("expected breaker to open at the failure threshold")
+	}
+
+	if state := breaker.StateAt(start.Add(10 * time.Second)); state != BreakerHalfOpen {
+		t.Fatalf("expected half-open after cooldown, got %s", state)
+	}
+	breaker.RecordAt(start.Add(11*time.Second), false)
+	if state := breaker.StateAt(start.Add(11 * time.Second)); state != BreakerOpen {
+		t.Fatalf("expected half-open failure to reopen, got %s", state)
+	}
+
+	breaker.RecordAt(start.Add(25*time.Second), true)
+	if state := breaker.StateAt(start.Add(25 * time.Second)); state != BreakerClosed {
+		t.Fatalf("expected success to close breaker, got %s", state)
+	}
+}
+
+func TestCircuitBreakerAdmitsOneHalfOpenTrial(t *testing.T) {
+	breaker := NewCircuitBreaker(1, 10*time.Second)
+	start := time.Unix(1700000000, 0)
+	breaker.RecordAt(start, false)
+
+	halfOpen := start.Add(10 * time.Second)
+	if !breaker.AvailableAt(halfOpen) {
+		t.Fatal("expected half-open endpoint to be available before the trial starts")
+	}
+	if admitted, trial := breaker.AdmitAt(halfOpen); !admitted || !trial {
+		t.Fatalf("expected first request to be the trial, got admitted=%v trial=%v", admitted, trial)
+	}
+	if breaker.AvailableAt(halfOpen) {
+		t.Fatal("expected endpoint to be unavailable while the trial is in flight")
+	}
+	if admitted, _ := breaker.AdmitAt(halfOpen.Add(time.Second)); admitted {
+		t.Fatal("expected concurrent request to be refused during the trial")
+	}
+
+	breaker.EndTrial()
+	if admitted, trial := breaker.AdmitAt(halfOpen.Add(2 * time.Second)); !admitted || !trial {
+		t.Fatal("expected a new trial after the previous one ended without an outcome")
+	}
+	// A trial that never reports back is abandoned after another cooldown.
+	if admitted, trial := breaker.AdmitAt(halfOpen.Add(12 * time.Second)); !admitted || !trial {
+		t.Fatal("expected abandoned trial to be replaced")
+	}
+
+	breaker.RecordAt(halfOpen.Add(13*time.Second), true)
+	if admitted, trial := breaker.AdmitAt(halfOpen.Add(13 * time.Second)); !admitted || trial {
+		t.Fatalf("expected closed breaker to admit without a trial, got admitted=%v trial=%v", admitted, trial)
+	}
+}
+
+func TestCircuitBreakerBalancerSkipsOpenEndpoints(t *testing.T) {
+	a := &models.ModelConfig{Name: "upstream-a", BaseURL: "http://a"}
+	b := &models.ModelConfig{Name: "upstream-b", BaseURL: "http://b"}
+	inner := NewRoundRobinBalancer()
+	inner.AddModel(a)
+	inner.AddModel(b)
+
+	breakers := NewBreakerSet(1, time.Minute)
+	blcr := WithCircuitBreaker(inner, breakers)
+	blcr.Observe(a, time.Millisecond, false)
+
+	for i := 0; i < 5; i++ {
+		if got := blcr.Next(SelectionContext{}); got != b {
+			t.Fatalf("expected open endpoint to be skipped, got %v", got)
+		}
+	}
+	if blcr.Available(a) {
+		t.Fatal("expected open endpoint to be unavailable")
+	}
+
+	blcr.Observe(b, time.Millisecond, false)
+	if got := blcr.Next(SelectionContext{}); got != nil {
+		t.Fatalf("expected no endpoint when all breakers are open, got %v", got)
+	}
+}
+
+func TestCircuitBreakerBalancerForwardsObservations(t *testing.T) {
+	fast := &models.ModelConfig{Name: "fast", BaseURL: "http://fast"}
+	slow := &models.ModelConfig{Name: "slow", BaseURL: "http://slow"}
+	inner := NewLatencyBalancer()
+	inner.AddModel(fast)
+	inner.AddModel(slow)
+
+	blcr := WithCircuitBreaker(inner, NewBreakerSet(0, 0))
+	blcr.Observe(fast, 10*time.Millisecond, true)
+	blcr.Observe(slow, 100*time.Millisecond, true)
+
+	if got := blcr.Next(SelectionContext{}); got != fast {
+		t.Fatalf("expected latency observations to reach the wrapped balancer, got %v", got)
+	}
+}
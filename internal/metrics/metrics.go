@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "janus"
+
+// Registry holds every Janus collector plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Gateway requests by model group, serving upstream, provider, status and stream flag.",
+	}, []string{"model_group", "upstream", "provider", "status", "stream"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "End-to-end gateway request latency.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"model_group", "upstream", "provider", "status", "stream"})
+
+	upstreamRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "Upstream attempts by model group, upstream, provider and status.",
+	}, []string{"model_group", "upstream", "provider", "status"})
+
+	upstreamRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of a single upstream attempt.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"model_group", "upstream", "provider", "status"})
+
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Failed attempts retried against the same upstream.",
+	}, []string{"model_group", "upstream"})
+
+	fallbacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_fallbacks_total",
+		Help:      "Requests moved to another upstream after exhausting retries on one.",
+	}, []string{"model_group", "upstream"})
+
+	groupFallbacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "model_group_fallbacks_total",
+		Help:      "Requests moved to a fallback model group, by reason (exhausted, context_window, content_policy).",
+	}, []string{"model_group", "fallback_group", "reason"})
+
+	rateLimitRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by a limit (rpm, tpm or weekly_spend).",
+	}, []string{"limit"})
+
+	rateLimitBackendErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_backend_errors_total",
+		Help:      "Shared rate limit counter queries that failed and were handled by the fallback mode.",
+	})
+
+	tokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_total",
+		Help:      "Billed tokens by model group, provider and type (prompt or completion).",
+	}, []string{"model_group", "provider", "type"})
+
+	spendTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spend_total",
+		Help:      "Billed spend by model group and provider.",
+	}, []string{"model_group", "provider"})
+
+	spendFlushesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spend_flushes_total",
+		Help:      "Spend batch writes to the database by result (ok or error).",
+	}, []string{"result"})
+
+	spendRecordsRejectedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spend_records_rejected_total",
+		Help:      "Spend records the database refused, set aside in the spool as .rejected files.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		upstreamRequestsTotal,
+		upstreamRequestDuration,
+		retriesTotal,
+		fallbacksTotal,
+		groupFallbacksTotal,
+		rateLimitRejectionsTotal,
+		rateLimitBackendErrorsTotal,
+		tokensTotal,
+		spendTotal,
+		spendFlushesTotal,
+		spendRecordsRejectedTotal,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+func ObserveRequest(modelGroup, upstream, provider string, status int, stream bool, latency time.Duration) {
+	labels := []string{modelGroup, upstream, provider, strconv.Itoa(status), strconv.FormatBool(stream)}
+	requestsTotal.WithLabelValues(labels...).Inc()
+	requestDuration.WithLabelValues(labels...).Observe(latency.Seconds())
+}
+
+func ObserveUpstream(modelGroup, upstream, provider string, status int, latency time.Duration) {
+	labels := []string{modelGroup, upstream, provider, strconv.Itoa(status)}
+	upstreamRequestsTotal.WithLabelValues(labels...).Inc()
+	upstreamRequestDuration.WithLabelValues(labels...).Observe(latency.Seconds())
+}
+
+func IncRetry(modelGroup, upstream string) {
+	retriesTotal.WithLabelValues(modelGroup, upstream).Inc()
+}
+
+func IncFallback(modelGroup, upstream string) {
+	fallbacksTotal.WithLabelValues(modelGroup, upstream).Inc()
+}
+
+func IncGroupFallback(modelGroup, fallbackGroup, reason string) {
+	groupFallbacksTotal.WithLabelValues(modelGroup, fallbackGroup, reason).Inc()
+}
+
+func IncRateLimitRejection(limit string) {
+	rateLimitRejectionsTotal.WithLabelValues(limit).Inc()
+}
+
+func IncRateLimitBackendError() {
+	rateLimitBackendErrorsTotal.Inc()
+}
+
+func AddUsage(modelGroup, provider string, promptTokens, completionTokens int, spend float64) {
+	if promptTokens > 0 {
+		tokensTotal.WithLabelValues(modelGroup, provider, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		tokensTotal.WithLabelValues(modelGroup, provider, "completion").Add(float64(completionTokens))
+	}
+	if spend > 0 {
+		spendTotal.WithLabelValues(modelGroup, provider).Add(spend)
+	}
+}
+
+func IncSpendFlush(result string) {
+	spendFlushesTotal.WithLabelValues(result).Inc()
+}
+
+func AddSpendRecordsRejected(count int) {
+	spendRecordsRejectedTotal.Add(float64(count))
+}
+
+// RegisterGauge exposes a value sampled at scrape time, such as a queue depth.
+func RegisterGauge(name string, help string, value func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, value))
+}
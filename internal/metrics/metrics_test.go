@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveRequestCountsByLabels(t *testing.T) {
+	before := testutil.ToFloat64(requestsTotal.WithLabelValues("gpt-test", "a", "openai", "200", "true"))
+	ObserveRequest("gpt-test", "a", "openai", 200, true, 150*time.Millisecond)
+	ObserveRequest("gpt-test", "a", "openai", 200, true, 50*time.Millisecond)
+
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues("gpt-test", "a", "openai", "200", "true")) - before; got != 2 {
+		t.Fatalf("expected 2 requests counted, got %v", got)
+	}
+}
+
+func TestAddUsageSkipsZeroValues(t *testing.T) {
+	AddUsage("usage-test", "openai", 10, 0, 0.5)
+
+	if got := testutil.ToFloat64(tokensTotal.WithLabelValues("usage-test", "openai", "prompt")); got != 10 {
+		t.Fatalf("expected 10 prompt tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(spendTotal.WithLabelValues("usage-test", "openai")); got != 0.5 {
+		t.Fatalf("expected spend 0.5, got %v", got)
+	}
+	if got := testutil.CollectAndCount(tokensTotal, "janus_tokens_total"); got == 0 {
+		t.Fatalf("expected token series to be collected")
+	}
+}
+
+func TestHandlerExposesRegisteredMetrics(t *testing.T) {
+	IncRateLimitRejection("rpm")
+	RegisterGauge("test_queue_depth", "Queue depth used in tests.", func() float64 { return 3 })
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+
+	for _, want := range []string{
+		`janus_rate_limit_rejections_total{limit="rpm"}`,
+		"janus_test_queue_depth 3",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected %q in metrics output", want)
+		}
+	}
+}
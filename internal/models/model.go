@@ -1,17 +1,146 @@
 package models
 
+import "fmt"
+
 type ModelConfig struct {
-	Name            string  `yaml:"name"`
-	Type            string  `yaml:"type"`
-	BaseURL         string  `yaml:"base_url"`
-	APIKey          string  `yaml:"api_key"`
-	APIKeySecretRef string  `yaml:"api_key_secret_ref"`
-	Weight          int     `yaml:"weight"`
-	MaxTokens       int     `yaml:"max_tokens"`
-	Temperature     float64 `yaml:"temperature"`
-	TimeoutSeconds  int     `yaml:"timeout_seconds"`
-	RetryTimes      int     `yaml:"retry_times"`
-	SkipTLSVerify   bool    `yaml:"skip_tls_verify"`
+	Name            string `yaml:"name"`
+	Type            string `yaml:"type"`
+	BaseURL         string `yaml:"base_url"`
+	APIKey          string `yaml:"api_key"`
+	APIKeySecretRef string `yaml:"api_key_secret_ref"`
+	// APIKeys and APIKeySecretRefs add provider credentials to rotate across alongside APIKey,
+	// each with its own provider rate limit.
+	APIKeys          []string `yaml:"api_keys"`
+	APIKeySecretRefs []string `yaml:"api_key_secret_refs"`
+	Weight           int      `yaml:"weight"`
+	MaxTokens        int      `yaml:"max_tokens"`
+	// ContextWindow is the endpoint's prompt plus completion token limit; requests estimated
+	// to exceed it skip the endpoint. 0 means unknown.
+	ContextWindow  int     `yaml:"context_window"`
+	Temperature    float64 `yaml:"temperature"`
+	TimeoutSeconds int     `yaml:"timeout_seconds"`
+	RetryTimes     int     `yaml:"retry_times"`
+	SkipTLSVerify  bool    `yaml:"skip_tls_verify"`
+	// DisableStreamUsage stops Janus from adding stream_options.include_usage to streaming
+	// OpenAI-compatible requests, for upstreams that reject the field.
+	DisableStreamUsage bool `yaml:"disable_stream_usage"`
+	// HealthCheck enables periodic active probes of this endpoint when set.
+	HealthCheck *HealthCheckConfig `yaml:"health_check"`
+	// Pricing bills requests this endpoint serves instead of the group's cost_per_input_token
+	// and cost_per_output_token.
+	Pricing *Pricing `yaml:"pricing"`
+}
+
+// Pricing is an endpoint's per-token price list. Tiers replace the base rates for requests
+// whose prompt exceeds their threshold; the tier with the highest such threshold applies.
+type Pricing struct {
+	PriceRates `yaml:",inline"`
+	Tiers      []PricingTier `yaml:"tiers" json:"tiers,omitempty"`
+}
+
+type PricingTier struct {
+	AbovePromptTokens int `yaml:"above_prompt_tokens" json:"above_prompt_tokens"`
+	PriceRates        `yaml:",inline"`
+}
+
+// PriceRates prices prompt and completion tokens. The optional rates apply to the parts of
+// the prompt or completion reported separately by the upstream; unset ones fall back to
+// InputPerToken or OutputPerToken.
+type PriceRates struct {
+	InputPerToken       float64  `yaml:"input_per_token" json:"input_per_token"`
+	OutputPerToken      float64  `yaml:"output_per_token" json:"output_per_token"`
+	CachedInputPerToken *float64 `yaml:"cached_input_per_token" json:"cached_input_per_token,omitempty"`
+	CacheWritePerToken  *float64 `yaml:"cache_write_per_token" json:"cache_write_per_token,omitempty"`
+	ReasoningPerToken   *float64 `yaml:"reasoning_per_token" json:"reasoning_per_token,omitempty"`
+	ImageInputPerToken  *float64 `yaml:"image_input_per_token" json:"image_input_per_token,omitempty"`
+	ImageOutputPerToken *float64 `yaml:"image_output_per_token" json:"image_output_per_token,omitempty"`
+	AudioInputPerToken  *float64 `yaml:"audio_input_per_token" json:"audio_input_per_token,omitempty"`
+	AudioOutputPerToken *float64 `yaml:"audio_output_per_token" json:"audio_output_per_token,omitempty"`
+}
+
+// RatesFor returns the rates for a prompt of promptTokens and the threshold of the tier that
+// supplied them, or 0 for the base rates.
+func (p Pricing) RatesFor(promptTokens int) (PriceRates, int) {
+	rates, threshold := p.PriceRates, 0
+	for _, tier := range p.Tiers {
+		if promptTokens > tier.AbovePromptTokens && tier.AbovePromptTokens > threshold {
+			rates, threshold = tier.PriceRates, tier.AbovePromptTokens
+		}
+	}
+	return rates, threshold
+}
+
+// Validate rejects negative rates and tiers without a distinct positive threshold.
+func (p Pricing) Validate() error {
+	if err := p.PriceRates.validate(); err != nil {
+		return err
+	}
+	seen := make(map[int]struct{}, len(p.Tiers))
+	for _, tier := range p.Tiers {
+		if tier.AbovePromptTokens <= 0 {
+			return fmt.Errorf("pricing tier above_prompt_tokens must be positive")
+		}
+		if _, ok := seen[tier.AbovePromptTokens]; ok {
+			return fmt.Errorf("duplicate pricing tier above_prompt_tokens %d", tier.AbovePromptTokens)
+		}
+		seen[tier.AbovePromptTokens] = struct{}{}
+		if err := tier.PriceRates.validate(); err != nil {
+			return fmt.Errorf("pricing tier above_prompt_tokens %d: %w", tier.AbovePromptTokens, err)
+		}
+	}
+	return nil
+}
+
+func (r PriceRates) validate() error {
+	for name, rate := range map[string]*float64{
+		"input_per_token":        &r.InputPerToken,
+		"output_per_token":       &r.OutputPerToken,
+		"cached_input_per_token": r.CachedInputPerToken,
+		"cache_write_per_token":  r.CacheWritePerToken,
+		"reasoning_per_token":    r.ReasoningPerToken,
+		"image_input_per_token":  r.ImageInputPerToken,
+		"image_output_per_token": r.ImageOutputPerToken,
+		"audio_input_per_token":  r.AudioInputPerToken,
+		"audio_output_per_token": r.AudioOutputPerToken,
+	} {
+		if rate != nil && *rate < 0 {
+			return fmt.Errorf("pricing %s must be non-negative", name)
+		}
+	}
+	return nil
+}
+
+// MaxRates returns the highest rate any prompt token and any completion token can be billed
+// at, across the base rates and every tier.
+func (p Pricing) MaxRates() (float64, float64) {
+	input, output := p.PriceRates.maxRates()
+	for _, tier := range p.Tiers {
+		tierInput, tierOutput := tier.PriceRates.maxRates()
+		input, output = max(input, tierInput), max(output, tierOutput)
+	}
+	return input, output
+}
+
+func (r PriceRates) maxRates() (float64, float64) {
+	input, output := r.InputPerToken, r.OutputPerToken
+	for _, rate := range []*float64{r.CachedInputPerToken, r.CacheWritePerToken, r.ImageInputPerToken, r.AudioInputPerToken} {
+		if rate != nil {
+			input = max(input, *rate)
+		}
+	}
+	for _, rate := range []*float64{r.ReasoningPerToken, r.ImageOutputPerToken, r.AudioOutputPerToken} {
+		if rate != nil {
+			output = max(output, *rate)
+		}
+	}
+	return input, output
+}
+
+type HealthCheckConfig struct {
+	Path            string `yaml:"path" json:"path,omitempty"`
+	IntervalSeconds int    `yaml:"interval_seconds" json:"interval_seconds,omitempty"`
+	TimeoutSeconds  int    `yaml:"timeout_seconds" json:"timeout_seconds,omitempty"`
+	ExpectedStatus  int    `yaml:"expected_status" json:"expected_status,omitempty"`
 }
 
 type ModelGroup struct {
@@ -22,4 +151,26 @@ type ModelGroup struct {
 	CostPerInputToken  float64                `yaml:"cost_per_input_token"`
 	CostPerOutputToken float64                `yaml:"cost_per_output_token"`
 	RequestDefaults    map[string]interface{} `yaml:"request_defaults"`
+	// TokensPerMinute caps prompt plus completion tokens across all keys; 0 means unlimited.
+	TokensPerMinute int `yaml:"tokens_per_minute"`
+	// CacheTTLSeconds enables the exact-match response cache for the group; 0 disables it.
+	CacheTTLSeconds int `yaml:"cache_ttl_seconds"`
+	// CacheHitCostRatio is the fraction of the normal price billed for a cache hit.
+	CacheHitCostRatio float64 `yaml:"cache_hit_cost_ratio"`
+	// Retry tunes which upstream statuses are retried and how long a request may keep trying.
+	Retry *RetryPolicy `yaml:"retry"`
+	// Fallbacks lists model groups tried in order when this group's endpoints are exhausted or
+	// reject the request for its context length or content policy.
+	Fallbacks []string `yaml:"fallbacks"`
+}
+
+type RetryPolicy struct {
+	// On lists retryable statuses as codes ("429") or classes ("5xx"); defaults to 5xx, 408, 429.
+	On []string `yaml:"on" json:"on,omitempty"`
+	// BackoffMS is the base delay before retrying the same endpoint. It doubles per attempt up
+	// to MaxBackoffMS with full jitter; an upstream Retry-After takes precedence when longer.
+	BackoffMS    int `yaml:"backoff_ms" json:"backoff_ms,omitempty"`
+	MaxBackoffMS int `yaml:"max_backoff_ms" json:"max_backoff_ms,omitempty"`
+	// TimeoutSeconds bounds all attempts and waits of one request; 0 means no overall deadline.
+	TimeoutSeconds int `yaml:"timeout_seconds" json:"timeout_seconds,omitempty"`
 }
@@ -18,6 +18,21 @@ type ProviderAdapter interface {
 	ParseSpendStreamLine(line []byte, requestID *string, usage **spend.TokenUsage)
 }
 
+// ResponseTranslator is implemented by adapters that rewrite upstream responses
+// into the wire format of the ingress endpoint. NewStreamTranslator is called once per
+// stream with the request context BuildRequest saw.
+type ResponseTranslator interface {
+	TranslateResponse(statusCode int, respBody []byte) ([]byte, error)
+	NewStreamTranslator(c *gin.Context) StreamTranslator
+}
+
+// StreamTranslator converts one upstream SSE line into zero or more client SSE lines.
+// Finish is called once the upstream stream ends and returns any trailing events.
+type StreamTranslator interface {
+	TranslateStreamLine(line []byte) []byte
+	Finish() []byte
+}
+
 type OpenAIAdapter struct{}
 
 type AnthropicAdapter struct{}
@@ -28,6 +43,28 @@ type usagePayload struct {
 	TotalTokens      *int `json:"total_tokens"`
 	InputTokens      *int `json:"input_tokens"`
 	OutputTokens     *int `json:"output_tokens"`
+	// Anthropic reports cache reads and writes beside input_tokens instead of inside it.
+	CacheCreationInputTokens *int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     *int `json:"cache_read_input_tokens"`
+	// PromptCacheHitTokens is DeepSeek's count of prompt tokens served from its cache.
+	PromptCacheHitTokens    *int                `json:"prompt_cache_hit_tokens"`
+	PromptTokensDetails     *inputTokenDetails  `json:"prompt_tokens_details"`
+	CompletionTokensDetails *outputTokenDetails `json:"completion_tokens_details"`
+	// The Responses API names the details after input and output tokens.
+	InputTokensDetails  *inputTokenDetails  `json:"input_tokens_details"`
+	OutputTokensDetails *outputTokenDetails `json:"output_tokens_details"`
+}
+
+type inputTokenDetails struct {
+	CachedTokens *int `json:"cached_tokens"`
+	AudioTokens  *int `json:"audio_tokens"`
+	ImageTokens  *int `json:"image_tokens"`
+}
+
+type outputTokenDetails struct {
+	ReasoningTokens *int `json:"reasoning_tokens"`
+	AudioTokens     *int `json:"audio_tokens"`
+	ImageTokens     *int `json:"image_tokens"`
 }
 
 type spendEnvelope struct {
@@ -38,6 +75,11 @@ type spendEnvelope struct {
 		ID    string        `json:"id"`
 		Usage *usagePayload `json:"usage"`
 	} `json:"message"`
+	// Response carries the response object embedded in OpenAI Responses API stream events.
+	Response *struct {
+		ID    string        `json:"id"`
+		Usage *usagePayload `json:"usage"`
+	} `json:"response"`
 }
 
 func (a *OpenAIAdapter) BuildRequest(c *gin.Context, endpointPath string, upstreamModel *models.ModelConfig, rawBody []byte) (*http.Request, error) {
@@ -112,16 +154,30 @@ func (a *AnthropicAdapter) ParseSpendStreamLine(line []byte, requestID *string,
 	parseSpendStreamLine(line, requestID, usage, true)
 }
 
-func SelectAdapter(upstreamModel *models.ModelConfig) ProviderAdapter {
+func SelectAdapter(endpointPath string, upstreamModel *models.ModelConfig) ProviderAdapter {
 	modelType := strings.ToLower(strings.TrimSpace(upstreamModel.Type))
 	switch modelType {
 	case "anthropic", "claude":
+		if isChatCompletionsPath(endpointPath) {
+			return &AnthropicChatAdapter{}
+		}
 		return &AnthropicAdapter{}
 	default:
+		if isMessagesPath(endpointPath) {
+			return &OpenAIMessagesAdapter{}
+		}
 		return &OpenAIAdapter{}
 	}
 }
 
+func isChatCompletionsPath(endpointPath string) bool {
+	return strings.TrimRight(endpointPath, "/") == "/v1/chat/completions"
+}
+
+func isMessagesPath(endpointPath string) bool {
+	return strings.TrimRight(endpointPath, "/") == "/v1/messages"
+}
+
 func copyHeaders(req *http.Request, c *gin.Context) {
 	for key, values := range c.Request.Header {
 		for _, value := range values {
@@ -200,6 +256,17 @@ func parseSpendStreamLine(line []byte, requestID *string, usage **spend.TokenUsa
 	if envelope.ID != "" {
 		*requestID = envelope.ID
 	}
+	if envelope.Response != nil && envelope.Response.ID != "" {
+		*requestID = envelope.Response.ID
+	}
+
+	switch envelope.Type {
+	case "response.completed", "response.incomplete", "response.failed":
+		if envelope.Response != nil {
+			mergeUsage(usage, envelope.Response.Usage)
+		}
+		return
+	}
 
 	if anthropic {
 		switch envelope.Type {
@@ -245,7 +312,9 @@ func normalizedTokenUsage(source *usagePayload, base *spend.TokenUsage) spend.To
 		out.PromptTokens = *source.PromptTokens
 	}
 	if source.InputTokens != nil {
-		out.PromptTokens = *source.InputTokens
+		// Anthropic sends its cache counts with input_tokens; folding them in keeps the
+		// prompt total comparable with OpenAI, whose prompt tokens include cached ones.
+		out.PromptTokens = *source.InputTokens + intValue(source.CacheReadInputTokens) + intValue(source.CacheCreationInputTokens)
 	}
 	if source.CompletionTokens != nil {
 		out.CompletionTokens = *source.CompletionTokens
@@ -259,5 +328,36 @@ func normalizedTokenUsage(source *usagePayload, base *spend.TokenUsage) spend.To
 	if source.TotalTokens == nil && (source.PromptTokens != nil || source.InputTokens != nil || source.CompletionTokens != nil || source.OutputTokens != nil) {
 		out.TotalTokens = out.PromptTokens + out.CompletionTokens
 	}
+
+	setCount(&out.CachedTokens, source.CacheReadInputTokens)
+	setCount(&out.CacheCreationTokens, source.CacheCreationInputTokens)
+	setCount(&out.CachedTokens, source.PromptCacheHitTokens)
+	for _, details := range []*inputTokenDetails{source.PromptTokensDetails, source.InputTokensDetails} {
+		if details != nil {
+			setCount(&out.CachedTokens, details.CachedTokens)
+			setCount(&out.AudioInputTokens, details.AudioTokens)
+			setCount(&out.ImageInputTokens, details.ImageTokens)
+		}
+	}
+	for _, details := range []*outputTokenDetails{source.CompletionTokensDetails, source.OutputTokensDetails} {
+		if details != nil {
+			setCount(&out.ReasoningTokens, details.ReasoningTokens)
+			setCount(&out.AudioOutputTokens, details.AudioTokens)
+			setCount(&out.ImageOutputTokens, details.ImageTokens)
+		}
+	}
 	return out
 }
+
+func setCount(target *int, value *int) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func intValue(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
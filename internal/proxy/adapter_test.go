@@ -65,3 +65,105 @@ func TestAnthropicParseSpendStreamLineMergesMessageUsage(t *testing.T) {
 		t.Fatalf("unexpected merged usage: %+v", *usage)
 	}
 }
+
+func TestOpenAIParseSpendStreamLineReadsResponsesCompletedUsage(t *testing.T) {
+	adapter := &OpenAIAdapter{}
+
+	var requestID string
+	var usage *spend.TokenUsage
+
+	adapter.ParseSpendStreamLine([]byte("event: response.created\n"), &requestID, &usage)
+	adapter.ParseSpendStreamLine([]byte("data: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_123\",\"usage\":null}}\n"), &requestID, &usage)
+	adapter.ParseSpendStreamLine([]byte("data: {\"type\":\"response.output_text.delta\",\"item_id\":\"msg_1\",\"delta\":\"hi\"}\n"), &requestID, &usage)
+	adapter.ParseSpendStreamLine([]byte("data: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_123\",\"usage\":{\"input_tokens\":9,\"output_tokens\":3,\"total_tokens\":12}}}\n"), &requestID, &usage)
+
+	if requestID != "resp_123" {
+		t.Fatalf("expected request id from response object, got %q", requestID)
+	}
+	if usage == nil {
+		t.Fatal("expected usage to be collected")
+	}
+	if usage.PromptTokens != 9 || usage.CompletionTokens != 3 || usage.TotalTokens != 12 {
+		t.Fatalf("unexpected responses usage: %+v", *usage)
+	}
+}
+
+func TestOpenAIBuildSpendPayloadReadsResponsesUsage(t *testing.T) {
+	adapter := &OpenAIAdapter{}
+
+	payload, err := adapter.BuildSpendPayload([]byte(`{
+		"id":"resp_456",
+		"object":"response",
+		"usage":{"input_tokens":20,"output_tokens":8,"total_tokens":28}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var resp spend.UpstreamResp
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if resp.Id != "resp_456" {
+		t.Fatalf("expected request id to be preserved, got %q", resp.Id)
+	}
+	if resp.Usage.PromptTokens != 20 || resp.Usage.CompletionTokens != 8 || resp.Usage.TotalTokens != 28 {
+		t.Fatalf("unexpected normalized usage: %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicParseSpendStreamLineFoldsCacheTokensIntoPrompt(t *testing.T) {
+	adapter := &AnthropicAdapter{}
+
+	var requestID string
+	var usage *spend.TokenUsage
+
+	adapter.ParseSpendStreamLine([]byte("data: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":10,\"cache_creation_input_tokens\":20,\"cache_read_input_tokens\":30,\"output_tokens\":1}}}\n"), &requestID, &usage)
+	adapter.ParseSpendStreamLine([]byte("data: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":4}}\n"), &requestID, &usage)
+
+	if usage == nil {
+		t.Fatal("expected usage to be collected")
+	}
+	want := spend.TokenUsage{PromptTokens: 60, CompletionTokens: 4, TotalTokens: 64, CachedTokens: 30, CacheCreationTokens: 20}
+	if *usage != want {
+		t.Fatalf("expected %+v, got %+v", want, *usage)
+	}
+}
+
+func TestBuildSpendPayloadReadsOpenAIUsageDetails(t *testing.T) {
+	adapter := &OpenAIAdapter{}
+	cases := map[string]struct {
+		body string
+		want spend.TokenUsage
+	}{
+		"chat completions": {
+			body: `{"id":"chatcmpl-1","usage":{"prompt_tokens":100,"completion_tokens":50,"total_tokens":150,
+				"prompt_tokens_details":{"cached_tokens":40,"audio_tokens":5},
+				"completion_tokens_details":{"reasoning_tokens":30,"audio_tokens":2}}}`,
+			want: spend.TokenUsage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150, CachedTokens: 40, AudioInputTokens: 5, ReasoningTokens: 30, AudioOutputTokens: 2},
+		},
+		"responses": {
+			body: `{"id":"resp_1","object":"response","usage":{"input_tokens":20,"output_tokens":8,"total_tokens":28,
+				"input_tokens_details":{"cached_tokens":16},"output_tokens_details":{"reasoning_tokens":6}}}`,
+			want: spend.TokenUsage{PromptTokens: 20, CompletionTokens: 8, TotalTokens: 28, CachedTokens: 16, ReasoningTokens: 6},
+		},
+		"deepseek cache hits": {
+			body: `{"id":"ds-1","usage":{"prompt_tokens":64,"completion_tokens":4,"total_tokens":68,"prompt_cache_hit_tokens":48,"prompt_cache_miss_tokens":16}}`,
+			want: spend.TokenUsage{PromptTokens: 64, CompletionTokens: 4, TotalTokens: 68, CachedTokens: 48},
+		},
+	}
+
+	for name, tc := range cases {
+		payload, err := adapter.BuildSpendPayload([]byte(tc.body))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		var resp spend.UpstreamResp
+		if err := json.Unmarshal(payload, &resp); err != nil {
+			t.Fatalf("%s: failed to decode payload: %v", name, err)
+		}
+		if resp.Usage != tc.want {
+			t.Errorf("%s: expected %+v, got %+v", name, tc.want, resp.Usage)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+const (
+	headerCacheHit     = "X-Cache-Hit"
+	headerJanusCache   = "X-Janus-Cache"
+	defaultCacheSize   = 1000
+	maxCachedBodyBytes = 1 << 20
+)
+
+// ResponseCache is the L1 exact-match cache: identical prepared bodies from the same tenant
+// to the same model group and endpoint replay the stored response.
+type ResponseCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type cachedResponse struct {
+	key          string
+	status       int
+	contentType  string
+	body         []byte
+	spendPayload []byte
+	provider     string
+	upstream     string
+	pricing      *models.Pricing
+	expiresAt    time.Time
+}
+
+func NewResponseCache(maxEntries int) *ResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheSize
+	}
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (rc *ResponseCache) Get(key string, now time.Time) (*cachedResponse, bool) {
+	if rc == nil {
+		return nil, false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	elem, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cachedResponse)
+	if !now.Before(entry.expiresAt) {
+		rc.order.Remove(elem)
+		delete(rc.entries, key)
+		return nil, false
+	}
+	rc.order.MoveToFront(elem)
+	return entry, true
+}
+
+func (rc *ResponseCache) Set(entry *cachedResponse) {
+	if rc == nil || entry == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if elem, ok := rc.entries[entry.key]; ok {
+		elem.Value = entry
+		rc.order.MoveToFront(elem)
+		return
+	}
+	rc.entries[entry.key] = rc.order.PushFront(entry)
+	for rc.order.Len() > rc.maxEntries {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// responseCacheKey hashes the endpoint, model group, tenant and the prepared body with keys
+// in canonical order, so formatting differences in the client body do not miss the cache.
+func responseCacheKey(c *gin.Context, endpointPath string, group models.ModelGroup, rawBody []byte) (string, bool) {
+	prepared, err := prepareUpstreamBody(rawBody, "", group.RequestDefaults)
+	if err != nil {
+		return "", false
+	}
+	tenant := ""
+	if keyValue, ok := c.Get("key"); ok {
+		if keyInfo, ok := keyValue.(auth.Key); ok {
+			tenant = spend.TenantFromKey(keyInfo)
+		}
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{endpointPath, group.Name, tenant} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(prepared)
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// cacheBypassed reports whether the client opted out with X-Janus-Cache or Cache-Control.
+func cacheBypassed(c *gin.Context) bool {
+	switch strings.ToLower(strings.TrimSpace(c.GetHeader(headerJanusCache))) {
+	case "off", "bypass", "no-cache", "no-store", "false", "0":
+		return true
+	}
+	cacheControl := strings.ToLower(c.GetHeader("Cache-Control"))
+	return strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store")
+}
+
+func cacheTTL(group models.ModelGroup) time.Duration {
+	if group.CacheTTLSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(group.CacheTTLSeconds) * time.Second
+}
+
+// serveCached replays a cached response and sets the spend context so the hit is logged.
+func serveCached(c *gin.Context, group models.ModelGroup, entry *cachedResponse) {
+	c.Header(headerCacheHit, "true")
+	c.Header("Content-Type", entry.contentType)
+	c.Status(entry.status)
+	_, _ = c.Writer.Write(entry.body)
+	c.Writer.Flush()
+
+	c.Set(spend.ContextProvider, entry.provider)
+	c.Set(spend.ContextUpstream, entry.upstream)
+	c.Set(spend.ContextUpstreamModel, entry.upstream)
+	c.Set(spend.ContextPricing, entry.pricing)
+	c.Set(spend.ContextLatencyMS, int64(0))
+	c.Set(spend.ContextCacheHit, true)
+	c.Set(spend.ContextCostMultiplier, group.CacheHitCostRatio)
+	if len(entry.spendPayload) > 0 {
+		c.Set(spend.ContextUpstreamResp, entry.spendPayload)
+	}
+}
+
+// contextResponseComplete marks that an upstream attempt delivered its whole response; a
+// stream cut off after its first bytes still has a 200 status and a body, but is not cached.
+const contextResponseComplete = "responseComplete"
+
+// captureWriter tees everything written to the client so a successful response can be cached.
+type captureWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(data string) (int, error) {
+	w.capture([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *captureWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(data) > maxCachedBodyBytes {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+func (w *captureWriter) cachedResponse(c *gin.Context, key string, ttl time.Duration, now time.Time) (*cachedResponse, bool) {
+	if w.overflow || w.Status() != http.StatusOK || w.body.Len() == 0 || !c.GetBool(contextResponseComplete) {
+		return nil, false
+	}
+	entry := &cachedResponse{
+		key:         key,
+		status:      w.Status(),
+		contentType: w.Header().Get("Content-Type"),
+		body:        append([]byte(nil), w.body.Bytes()...),
+		provider:    contextString(c, spend.ContextProvider),
+		upstream:    contextString(c, spend.ContextUpstream),
+		expiresAt:   now.Add(ttl),
+	}
+	if pricing, ok := c.Get(spend.ContextPricing); ok {
+		entry.pricing, _ = pricing.(*models.Pricing)
+	}
+	if payload, ok := c.Get(spend.ContextUpstreamResp); ok {
+		if data, ok := payload.([]byte); ok {
+			entry.spendPayload = append([]byte(nil), data...)
+		}
+	}
+	return entry, true
+}
+
+func contextString(c *gin.Context, key string) string {
+	value, ok := c.Get(key)
+	if !ok {
+		return ""
+	}
+	text, _ := value.(string)
+	return text
+}
@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+func newCachingProxy(t *testing.T, hits *int32, stream bool) (*Proxy, func()) {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("x-cache-hit", "true")
+		if stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "data: {\"id\":\"s1\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			_, _ = io.WriteString(w, "data: {\"id\":\"s1\",\"choices\":[],\"usage\":{\"prompt_tokens\":2,\"completion_tokens\":1,\"total_tokens\":3}}\n\n")
+			_, _ = io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"c1","choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":2,"completion_tokens":1,"total_tokens":3}}`)
+	}))
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:              "cached",
+		CacheTTLSeconds:   60,
+		CacheHitCostRatio: 0.1,
+		Models:            []models.ModelConfig{{Name: "m", Type: "openai", BaseURL: upstream.URL}},
+	})
+	return p, upstream.Close
+}
+
+func TestHandleRequestServesExactMatchFromCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var hits int32
+	p, closeUpstream := newCachingProxy(t, &hits, false)
+	defer closeUpstream()
+	key := auth.Key{KeyId: 1, TeamId: 2, OrganizationId: 3}
+
+	first, _ := serveBody(p, "cached", []byte(`{"model":"cached","messages":[{"role":"user","content":"hi"}]}`), withKey(key))
+	if first.Code != http.StatusOK || first.Header().Get("x-cache-hit") != "false" {
+		t.Fatalf("expected miss, got %d x-cache-hit=%q", first.Code, first.Header().Get("x-cache-hit"))
+	}
+
+	second, ctx := serveBody(p, "cached", []byte(`{"messages":[{"content":"hi","role":"user"}], "model":"cached"}`), withKey(key))
+	if second.Header().Get("x-cache-hit") != "true" {
+		t.Fatalf("expected hit for reordered body, got x-cache-hit=%q", second.Header().Get("x-cache-hit"))
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("expected cached body %q, got %q", first.Body.String(), second.Body.String())
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected one upstream call, got %d", got)
+	}
+	if hit, _ := ctx.Get(spend.ContextCacheHit); hit != true {
+		t.Fatal("expected cache hit to be recorded in spend context")
+	}
+	if ratio, _ := ctx.Get(spend.ContextCostMultiplier); ratio != 0.1 {
+		t.Fatalf("expected cache hit cost ratio 0.1, got %v", ratio)
+	}
+	if _, ok := ctx.Get(spend.ContextUpstreamResp); !ok {
+		t.Fatal("expected cached usage payload for spend logging")
+	}
+}
+
+func TestHandleRequestCacheIsolatesTenantsAndHonorsOptOut(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var hits int32
+	p, closeUpstream := newCachingProxy(t, &hits, false)
+	defer closeUpstream()
+	body := `{"model":"cached","messages":[{"role":"user","content":"hi"}]}`
+
+	serveBody(p, "cached", []byte(body), withKey(auth.Key{KeyId: 1, TeamId: 2, OrganizationId: 3}))
+	other, _ := serveBody(p, "cached", []byte(body), withKey(auth.Key{KeyId: 9, TeamId: 8, OrganizationId: 3}))
+	if other.Header().Get("x-cache-hit") != "false" {
+		t.Fatal("expected another tenant to miss the cache")
+	}
+	optOut, _ := serveBody(p, "cached", []byte(body), withKey(auth.Key{KeyId: 1, TeamId: 2, OrganizationId: 3}), withHeader("X-Janus-Cache", "bypass"))
+	if optOut.Header().Get("x-cache-hit") != "false" {
+		t.Fatalf("expected Janus to own x-cache-hit on opt-out, got %q", optOut.Header().Get("x-cache-hit"))
+	}
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Fatalf("expected three upstream calls, got %d", got)
+	}
+}
+
+func TestHandleRequestReplaysCachedStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var hits int32
+	p, closeUpstream := newCachingProxy(t, &hits, true)
+	defer closeUpstream()
+	key := auth.Key{KeyId: 1, TeamId: 2, OrganizationId: 3}
+	body := `{"model":"cached","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+
+	first, _ := serveBody(p, "cached", []byte(body), withKey(key), withStream())
+	second, _ := serveBody(p, "cached", []byte(body), withKey(key), withStream())
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("expected replayed stream to skip upstream, got %d calls", hits)
+	}
+	if second.Header().Get("Content-Type") != "text/event-stream" || second.Body.String() != first.Body.String() {
+		t.Fatalf("unexpected replayed stream: %q %q", second.Header().Get("Content-Type"), second.Body.String())
+	}
+}
+
+func TestHandleRequestDoesNotCacheTruncatedStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var hits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"id\":\"s1\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:            "cached",
+		CacheTTLSeconds: 60,
+		Models:          []models.ModelConfig{{Name: "m", Type: "openai", BaseURL: upstream.URL}},
+	})
+	key := auth.Key{KeyId: 1, TeamId: 2, OrganizationId: 3}
+	body := []byte(`{"model":"cached","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	serveBody(p, "cached", body, withKey(key), withStream())
+	second, _ := serveBody(p, "cached", body, withKey(key), withStream())
+	if second.Header().Get("x-cache-hit") != "false" || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("expected the truncated stream not to be cached, got x-cache-hit=%q after %d upstream calls",
+			second.Header().Get("x-cache-hit"), hits)
+	}
+	if !strings.Contains(second.Body.String(), "[DONE]") {
+		t.Fatalf("expected the retried stream to complete, got %q", second.Body.String())
+	}
+}
+
+func TestResponseCacheExpiresAndEvicts(t *testing.T) {
+	cache := NewResponseCache(1)
+	now := time.Unix(1700000000, 0)
+	cache.Set(&cachedResponse{key: "a", expiresAt: now.Add(time.Second)})
+	if _, ok := cache.Get("a", now.Add(2*time.Second)); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+	cache.Set(&cachedResponse{key: "a", expiresAt: now.Add(time.Minute)})
+	cache.Set(&cachedResponse{key: "b", expiresAt: now.Add(time.Minute)})
+	if _, ok := cache.Get("a", now); ok {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get("b", now); !ok {
+		t.Fatal("expected newest entry to be cached")
+	}
+}
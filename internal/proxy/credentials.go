@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+const (
+	// minCredentialBench keeps Retry-After: 0 from letting a rejected key be retried at once.
+	minCredentialBench       = time.Second
+	defaultRateLimitBench    = 30 * time.Second
+	defaultUnauthorizedBench = 5 * time.Minute
+	maxCredentialBench       = time.Hour
+)
+
+// errCredentialRejected marks an attempt the provider refused for its credential while the
+// endpoint still has other credentials to try.
+var errCredentialRejected = errors.New("provider credential rejected")
+
+// credentialPool rotates across each endpoint's provider keys and benches keys the provider
+// rejected. Benches are per key, so a key shared by several endpoints is benched everywhere.
+// It lives on the Proxy and survives config reloads.
+type credentialPool struct {
+	mu           sync.Mutex
+	next         map[string]int
+	benchedUntil map[string]time.Time
+}
+
+func newCredentialPool() *credentialPool {
+	return &credentialPool{
+		next:         make(map[string]int),
+		benchedUntil: make(map[string]time.Time),
+	}
+}
+
+// endpointCredentials returns api_key followed by api_keys, skipping placeholders and
+// duplicates.
+func endpointCredentials(model *models.ModelConfig) []string {
+	if model == nil {
+		return nil
+	}
+	keys := make([]string, 0, 1+len(model.APIKeys))
+	seen := make(map[string]struct{}, cap(keys))
+	for _, key := range append([]string{model.APIKey}, model.APIKeys...) {
+		key = strings.TrimSpace(key)
+		if !hasUpstreamAPIKey(key) {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// pick returns the next credential that is not benched. Endpoints with one credential or
+// none always get it, as before pools existed; ok is false only when every key in a pool
+// is benched.
+func (p *credentialPool) pick(model *models.ModelConfig, now time.Time) (string, bool) {
+	keys := endpointCredentials(model)
+	switch len(keys) {
+	case 0:
+		return "", true
+	case 1:
+		return keys[0], true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	endpoint := model.Name + "\x00" + model.BaseURL
+	start := p.next[endpoint]
+	for i := 0; i < len(keys); i++ {
+		key := keys[(start+i)%len(keys)]
+		if now.Before(p.benchedUntil[key]) {
+			continue
+		}
+		p.next[endpoint] = (start + i + 1) % len(keys)
+		return key, true
+	}
+	return "", false
+}
+
+// rotatable reports whether model has another credential that is not benched.
+func (p *credentialPool) rotatable(model *models.ModelConfig, now time.Time) bool {
+	keys := endpointCredentials(model)
+	if len(keys) < 2 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, key := range keys {
+		if !now.Before(p.benchedUntil[key]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *credentialPool) bench(key string, until time.Time) {
+	if key == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until.After(p.benchedUntil[key]) {
+		p.benchedUntil[key] = until
+	}
+	for k, t := range p.benchedUntil {
+		if !t.After(time.Now()) {
+			delete(p.benchedUntil, k)
+		}
+	}
+}
+
+func rejectsCredential(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusUnauthorized
+}
+
+// credentialBench returns how long to bench a credential after status, preferring the
+// provider's Retry-After.
+func credentialBench(status int, header http.Header, now time.Time) time.Duration {
+	if wait, ok := parseRetryAfter(header, now); ok {
+		if wait < minCredentialBench {
+			return minCredentialBench
+		}
+		return min(wait, maxCredentialBench)
+	}
+	if status == http.StatusUnauthorized {
+		return defaultUnauthorizedBench
+	}
+	return defaultRateLimitBench
+}
+
+// parseRetryAfter reads Retry-After as delay seconds or an HTTP date.
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// withCredential returns a copy of model that authenticates with key.
+func withCredential(model *models.ModelConfig, key string) *models.ModelConfig {
+	if model == nil || model.APIKey == key {
+		return model
+	}
+	target := *model
+	target.APIKey = key
+	return &target
+}
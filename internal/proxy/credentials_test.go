@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+func TestHandleRequestRotatesCredentialOnRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var seen []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		seen = append(seen, auth)
+		if auth == "Bearer sk-limited-000000000001" {
+			w.Header().Set("Retry-After", "120")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"1","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name: "pooled",
+		Models: []models.ModelConfig{{
+			Name:    "upstream",
+			Type:    "openai",
+			BaseURL: upstream.URL,
+			APIKey:  "sk-limited-000000000001",
+			APIKeys: []string{"sk-healthy-000000000002"},
+		}},
+	})
+
+	for i := 0; i < 2; i++ {
+		rec, ctx := serveBody(p, "pooled", nil)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected rotation to succeed, got %d %q", i, rec.Code, rec.Body.String())
+		}
+		if got := ctx.GetString(spend.ContextCredential); got != "sk-heal...0002" {
+			t.Fatalf("request %d: expected redacted serving credential, got %q", i, got)
+		}
+	}
+	// The limited key is benched after the first 429, so the second request skips it.
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 upstream calls, got %v", seen)
+	}
+}
+
+func TestCredentialPoolKeepsSingleKeyAndBenchesPools(t *testing.T) {
+	pool := newCredentialPool()
+	now := time.Now()
+	single := &models.ModelConfig{Name: "a", APIKey: "sk-only"}
+	pool.bench("sk-only", now.Add(time.Minute))
+	if key, ok := pool.pick(single, now); !ok || key != "sk-only" {
+		t.Fatalf("expected a single key to be used even when benched, got %q %v", key, ok)
+	}
+
+	pooled := &models.ModelConfig{Name: "b", APIKey: "sk-1", APIKeys: []string{"sk-2", "sk-1", "none"}}
+	if keys := endpointCredentials(pooled); len(keys) != 2 {
+		t.Fatalf("expected duplicates and placeholders to be dropped, got %v", keys)
+	}
+	pool.bench("sk-1", now.Add(time.Minute))
+	pool.bench("sk-2", now.Add(time.Minute))
+	if _, ok := pool.pick(pooled, now); ok {
+		t.Fatalf("expected no credential while the whole pool is benched")
+	}
+	if key, ok := pool.pick(pooled, now.Add(2*time.Minute)); !ok || key == "" {
+		t.Fatalf("expected benches to expire, got %q %v", key, ok)
+	}
+}
+
+func TestCredentialBenchHonorsRetryAfter(t *testing.T) {
+	now := time.Now()
+	header := http.Header{}
+	if got := credentialBench(http.StatusTooManyRequests, header, now); got != defaultRateLimitBench {
+		t.Fatalf("expected default 429 bench, got %v", got)
+	}
+	if got := credentialBench(http.StatusUnauthorized, header, now); got != defaultUnauthorizedBench {
+		t.Fatalf("expected default 401 bench, got %v", got)
+	}
+	header.Set("Retry-After", "0")
+	if got := credentialBench(http.StatusTooManyRequests, header, now); got != minCredentialBench {
+		t.Fatalf("expected minimum bench for Retry-After 0, got %v", got)
+	}
+	header.Set("Retry-After", now.Add(90*time.Second).UTC().Format(http.TimeFormat))
+	if got := credentialBench(http.StatusTooManyRequests, header, now); got < 88*time.Second || got > 90*time.Second {
+		t.Fatalf("expected HTTP-date Retry-After, got %v", got)
+	}
+}
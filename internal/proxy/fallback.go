@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+// headerModelGroup reports the model group that served the request, which is a fallback
+// group when the requested one could not.
+const headerModelGroup = "X-Janus-Model-Group"
+
+const (
+	fallbackExhausted     = "exhausted"
+	fallbackContextWindow = "context_window"
+	fallbackContentPolicy = "content_policy"
+)
+
+// Provider error markers for requests another model might still accept. OpenAI-compatible
+// APIs use error codes; Anthropic and Gemini only describe the problem in the message.
+var (
+	contextWindowMarkers = [][]byte{
+		[]byte("context_length_exceeded"),
+		[]byte("maximum context length"),
+		[]byte("context window"),
+		[]byte("prompt is too long"),
+		[]byte("input is too long"),
+		[]byte("exceeds the maximum number of tokens"),
+	}
+	contentPolicyMarkers = [][]byte{
+		[]byte("content_policy_violation"),
+		[]byte("content_filter"),
+		[]byte("content management policy"),
+		[]byte("safety system"),
+	}
+)
+
+// ValidateFallbacks reports fallback entries that do not name another configured group.
+func ValidateFallbacks(groups []models.ModelGroup) error {
+	names := make(map[string]struct{}, len(groups))
+	for _, group := range groups {
+		names[group.Name] = struct{}{}
+	}
+	var errs []error
+	for _, group := range groups {
+		for _, fallback := range group.Fallbacks {
+			if fallback == group.Name {
+				errs = append(errs, fmt.Errorf("model group %s lists itself as a fallback", group.Name))
+				continue
+			}
+			if _, ok := names[fallback]; !ok {
+				errs = append(errs, fmt.Errorf("model group %s falls back to unknown group %s", group.Name, fallback))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// classifyFallback returns why a client error should move the request to a fallback group,
+// or "" when the error is the caller's to fix.
+func classifyFallback(status int, body []byte) string {
+	if status < http.StatusBadRequest || status >= http.StatusInternalServerError {
+		return ""
+	}
+	lower := bytes.ToLower(body)
+	for _, marker := range contextWindowMarkers {
+		if bytes.Contains(lower, marker) {
+			return fallbackContextWindow
+		}
+	}
+	for _, marker := range contentPolicyMarkers {
+		if bytes.Contains(lower, marker) {
+			return fallbackContentPolicy
+		}
+	}
+	return ""
+}
+
+// fallbackReason returns why a group's failure with err moves the request on.
+func fallbackReason(err error) string {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) && statusErr.fallback != "" {
+		return statusErr.fallback
+	}
+	var windowErr *contextWindowError
+	if errors.As(err, &windowErr) {
+		return fallbackContextWindow
+	}
+	return fallbackExhausted
+}
+
+// fallbackChain returns the requested group followed by its fallbacks the caller's key may
+// use. Only the requested group's list is followed, so chains cannot loop.
+func fallbackChain(c *gin.Context, group models.ModelGroup) []string {
+	chain := []string{group.Name}
+	seen := map[string]struct{}{group.Name: {}}
+	for _, fallback := range group.Fallbacks {
+		if _, ok := seen[fallback]; ok {
+			continue
+		}
+		seen[fallback] = struct{}{}
+		if keyAllowsModelGroup(c, fallback) {
+			chain = append(chain, fallback)
+		}
+	}
+	return chain
+}
+
+// chainGroups returns the configs of the groups in fallbackChain that are still configured.
+func (p *Proxy) chainGroups(c *gin.Context, group models.ModelGroup) []models.ModelGroup {
+	chain := []models.ModelGroup{group}
+	for _, name := range fallbackChain(c, group)[1:] {
+		if _, fallback, ok := p.modelGroup(name); ok {
+			chain = append(chain, fallback)
+		}
+	}
+	return chain
+}
+
+func keyAllowsModelGroup(c *gin.Context, name string) bool {
+	value, ok := c.Get("key")
+	if !ok {
+		return false
+	}
+	key, ok := value.(auth.Key)
+	if !ok || len(key.ModelList) == 0 {
+		return false
+	}
+	if key.ModelList[0] == "*" {
+		return true
+	}
+	for _, model := range key.ModelList {
+		if model == name {
+			return true
+		}
+	}
+	return false
+}
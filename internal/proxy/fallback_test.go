@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+func newFallbackProxy(t *testing.T, primary http.HandlerFunc) (*Proxy, *atomic.Int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	primaryServer := httptest.NewServer(primary)
+	t.Cleanup(primaryServer.Close)
+	var fallbackCalls atomic.Int32
+	fallbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"1","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	t.Cleanup(fallbackServer.Close)
+
+	p := NewProxy()
+	p.ReplaceModelGroups([]models.ModelGroup{
+		{
+			Name:      "primary",
+			Models:    []models.ModelConfig{{Name: "primary-upstream", Type: "openai", BaseURL: primaryServer.URL}},
+			Retry:     &models.RetryPolicy{BackoffMS: 1},
+			Fallbacks: []string{"secondary"},
+		},
+		{
+			Name:   "secondary",
+			Models: []models.ModelConfig{{Name: "secondary-upstream", Type: "openai", BaseURL: fallbackServer.URL}},
+		},
+	})
+	return p, &fallbackCalls
+}
+
+func TestHandleRequestFallsBackWhenGroupExhausted(t *testing.T) {
+	p, fallbackCalls := newFallbackProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	rec, ctx := serveBody(p, "primary", nil, withKey(auth.Key{KeyId: 1, ModelList: auth.StringSlice{"*"}}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the fallback group to serve, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(headerModelGroup); got != "secondary" {
+		t.Fatalf("expected served group header, got %q", got)
+	}
+	if got := spend.ServedModelGroup(ctx); got != "secondary" {
+		t.Fatalf("expected spend to use the served group, got %q", got)
+	}
+	if fallbackCalls.Load() != 1 {
+		t.Fatalf("expected one fallback call, got %d", fallbackCalls.Load())
+	}
+}
+
+func TestHandleRequestFallsBackOnContextWindowError(t *testing.T) {
+	p, _ := newFallbackProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"code":"context_length_exceeded","message":"This model's maximum context length is 8192 tokens"}}`)
+	})
+
+	rec, _ := serveBody(p, "primary", nil, withKey(auth.Key{KeyId: 1, ModelList: auth.StringSlice{"primary", "secondary"}}))
+	if rec.Code != http.StatusOK || rec.Header().Get(headerModelGroup) != "secondary" {
+		t.Fatalf("expected context window error to fall back, got %d %q", rec.Code, rec.Header().Get(headerModelGroup))
+	}
+}
+
+func TestHandleRequestSkipsFallbackGroupsTheKeyCannotUse(t *testing.T) {
+	p, fallbackCalls := newFallbackProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"code":"content_filter","message":"blocked"}}`)
+	})
+
+	rec, ctx := serveBody(p, "primary", nil, withKey(auth.Key{KeyId: 1, ModelList: auth.StringSlice{"primary"}}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the upstream rejection, got %d %q", rec.Code, rec.Body.String())
+	}
+	if fallbackCalls.Load() != 0 {
+		t.Fatalf("expected no call to a group the key cannot use")
+	}
+	if got := spend.ServedModelGroup(ctx); got != "primary" {
+		t.Fatalf("expected the requested group, got %q", got)
+	}
+}
+
+func TestClassifyFallback(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusBadRequest, `{"error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`, fallbackContextWindow},
+		{http.StatusBadRequest, `{"error":{"code":"content_policy_violation"}}`, fallbackContentPolicy},
+		{http.StatusBadRequest, `{"error":{"message":"temperature must be between 0 and 2"}}`, ""},
+		{http.StatusInternalServerError, `context_length_exceeded`, ""},
+	}
+	for _, tc := range cases {
+		if got := classifyFallback(tc.status, []byte(tc.body)); got != tc.want {
+			t.Fatalf("classifyFallback(%d, %s) = %q, want %q", tc.status, tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestValidateFallbacks(t *testing.T) {
+	groups := []models.ModelGroup{{Name: "a", Fallbacks: []string{"b"}}, {Name: "b"}}
+	if err := ValidateFallbacks(groups); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	groups[1].Fallbacks = []string{"b"}
+	groups[0].Fallbacks = []string{"missing"}
+	if err := ValidateFallbacks(groups); err == nil {
+		t.Fatalf("expected self and unknown fallbacks to be rejected")
+	}
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+const (
+	defaultHealthCheckPath     = "/v1/models"
+	defaultHealthCheckInterval = 30 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+)
+
+// StartHealthChecks probes every endpoint that configures health_check and feeds the
+// results into its circuit breaker. The returned function stops all probes.
+func (p *Proxy) StartHealthChecks(logger *zap.Logger) func() {
+	done := make(chan struct{})
+	seen := make(map[string]struct{})
+	for _, group := range p.snapshotGroups() {
+		for i := range group.Models {
+			model := group.Models[i]
+			if model.HealthCheck == nil {
+				continue
+			}
+			key := upstreamModelKey(&model)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			go p.runHealthCheck(&model, logger, done)
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (p *Proxy) runHealthCheck(model *models.ModelConfig, logger *zap.Logger, done <-chan struct{}) {
+	interval := defaultHealthCheckInterval
+	if model.HealthCheck.IntervalSeconds > 0 {
+		interval = time.Duration(model.HealthCheck.IntervalSeconds) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p.checkEndpointHealth(model, logger)
+		}
+	}
+}
+
+func (p *Proxy) checkEndpointHealth(model *models.ModelConfig, logger *zap.Logger) {
+	breaker := p.breakers.Breaker(model)
+	before := breaker.StateAt(time.Now())
+	err := probeEndpoint(model)
+	breaker.RecordAt(time.Now(), err == nil)
+	after := breaker.StateAt(time.Now())
+	if before == after {
+		return
+	}
+	logger.Info("upstream circuit breaker state changed",
+		zap.String("upstream", model.Name),
+		zap.String("base_url", model.BaseURL),
+		zap.String("from", before.String()),
+		zap.String("to", after.String()),
+		zap.Error(err),
+	)
+}
+
+func probeEndpoint(model *models.ModelConfig) error {
+	check := model.HealthCheck
+	path := strings.TrimSpace(check.Path)
+	if path == "" {
+		path = defaultHealthCheckPath
+	}
+	timeout := defaultHealthCheckTimeout
+	if check.TimeoutSeconds > 0 {
+		timeout = time.Duration(check.TimeoutSeconds) * time.Second
+	}
+	expected := check.ExpectedStatus
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+
+	req, err := http.NewRequest(http.MethodGet, buildUpstreamURL(model.BaseURL, path), nil)
+	if err != nil {
+		return err
+	}
+	if keys := endpointCredentials(model); len(keys) > 0 {
+		if providerName(model) == "anthropic" {
+			req.Header.Set("x-api-key", keys[0])
+			req.Header.Set("anthropic-version", "2023-06-01")
+		} else {
+			req.Header.Set("Authorization", "Bearer "+keys[0])
+		}
+	}
+
+	resp, err := buildHTTPClient(model, false, timeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode != expected {
+		return fmt.Errorf("health check status %d, expected %d", resp.StatusCode, expected)
+	}
+	return nil
+}
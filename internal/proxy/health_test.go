@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/Uuq114/JanusLLM/internal/balancer"
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+func TestHandleRequestSkipsEndpointWithOpenBreaker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var deadHits int32
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&deadHits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer dead.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"ok","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer healthy.Close()
+
+	p := NewProxy()
+	p.breakers = balancer.NewBreakerSet(1, 0)
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name: "chat",
+		Models: []models.ModelConfig{
+			{Name: "dead", Type: "openai", BaseURL: dead.URL},
+			{Name: "healthy", Type: "openai", BaseURL: healthy.URL},
+		},
+	})
+
+	for i := 0; i < 4; i++ {
+		rec, _ := serveBody(p, "chat", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d %q", i, rec.Code, rec.Body.String())
+		}
+	}
+	if hits := atomic.LoadInt32(&deadHits); hits != 1 {
+		t.Fatalf("expected dead endpoint to be tried once before its breaker opened, got %d", hits)
+	}
+}
+
+func TestCheckEndpointHealthRecoversBreaker(t *testing.T) {
+	var status int32 = http.StatusServiceUnavailable
+	var gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.breakers = balancer.NewBreakerSet(1, 0)
+	model := &models.ModelConfig{
+		Name:        "probe",
+		Type:        "openai",
+		BaseURL:     upstream.URL,
+		APIKey:      "sk-upstream",
+		HealthCheck: &models.HealthCheckConfig{Path: "/healthz", ExpectedStatus: http.StatusNoContent},
+	}
+
+	p.checkEndpointHealth(model, zap.NewNop())
+	if p.breakers.Available(model) {
+		t.Fatal("expected failed probe to open the breaker")
+	}
+	if gotAuth != "Bearer sk-upstream" {
+		t.Fatalf("expected probe to authenticate, got %q", gotAuth)
+	}
+
+	atomic.StoreInt32(&status, http.StatusNoContent)
+	p.checkEndpointHealth(model, zap.NewNop())
+	if !p.breakers.Available(model) {
+		t.Fatal("expected successful probe to close the breaker")
+	}
+}
@@ -2,12 +2,17 @@ package proxy
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,44 +23,99 @@ import (
 
 	"github.com/Uuq114/JanusLLM/internal/auth"
 	"github.com/Uuq114/JanusLLM/internal/balancer"
+	"github.com/Uuq114/JanusLLM/internal/metrics"
 	"github.com/Uuq114/JanusLLM/internal/models"
 	"github.com/Uuq114/JanusLLM/internal/spend"
-)
-
-var (
-	// SpendLogQueue stores request-level spend records before batch insert.
-	SpendLogQueue = make(chan spend.SpendRecord, 100)
-
-	keyRequestRingMu sync.RWMutex
-	keyRequestRing   = make(map[string]*RequestRing)
-
-	keySpendQueueMu sync.RWMutex
-	keySpendQueue   = make(map[int]chan float64)
+	"github.com/Uuq114/JanusLLM/internal/tracing"
 )
 
 const maxPerUpstreamRetryTimes = 3
 
+var errNoAvailableModels = errors.New("no available models")
+
 type Proxy struct {
-	balancers map[string]balancer.Balancer
-	groups    map[string]models.ModelGroup
+	// mu guards the routing table; reloads swap balancers and groups together.
+	mu          sync.RWMutex
+	balancers   map[string]balancer.Balancer
+	groups      map[string]models.ModelGroup
+	breakers    *balancer.BreakerSet
+	cache       *ResponseCache
+	credentials *credentialPool
 }
 
 func NewProxy() *Proxy {
 	return &Proxy{
-		balancers: make(map[string]balancer.Balancer),
-		groups:    make(map[string]models.ModelGroup),
+		balancers:   make(map[string]balancer.Balancer),
+		groups:      make(map[string]models.ModelGroup),
+		breakers:    balancer.NewBreakerSet(0, 0),
+		cache:       NewResponseCache(defaultCacheSize),
+		credentials: newCredentialPool(),
 	}
 }
 
 func (p *Proxy) RegisterModelGroup(group *models.ModelGroup) {
-	b := balancer.New(group.Strategy)
+	b := p.newGroupBalancer(group)
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.balancers[group.Name] = b
+	p.groups[group.Name] = *group
+}
+
+// ReplaceModelGroups swaps the whole routing table for groups. Unchanged groups keep their
+// balancer; changed ones get a new balancer that inherits per-endpoint state from the old
+// one. Requests already in flight finish on the balancer they started with.
+func (p *Proxy) ReplaceModelGroups(groups []models.ModelGroup) {
+	p.mu.RLock()
+	previousBalancers, previousGroups := p.balancers, p.groups
+	p.mu.RUnlock()
+
+	balancers := make(map[string]balancer.Balancer, len(groups))
+	configs := make(map[string]models.ModelGroup, len(groups))
+	for i := range groups {
+		group := groups[i]
+		previous, existed := previousBalancers[group.Name]
+		if existed && reflect.DeepEqual(previousGroups[group.Name], group) {
+			balancers[group.Name] = previous
+		} else {
+			b := p.newGroupBalancer(&group)
+			if existed {
+				b.InheritState(previous)
+			}
+			balancers[group.Name] = b
+		}
+		configs[group.Name] = group
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.balancers = balancers
+	p.groups = configs
+}
+
+func (p *Proxy) newGroupBalancer(group *models.ModelGroup) *balancer.CircuitBreakerBalancer {
+	b := balancer.WithCircuitBreaker(balancer.New(group.Strategy), p.breakers)
 	for _, model := range group.Models {
 		b.AddModel(&model)
 	}
+	return b
+}
 
-	p.balancers[group.Name] = b
-	p.groups[group.Name] = *group
+func (p *Proxy) modelGroup(name string) (balancer.Balancer, models.ModelGroup, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	b, ok := p.balancers[name]
+	return b, p.groups[name], ok
+}
+
+func (p *Proxy) snapshotGroups() []models.ModelGroup {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]models.ModelGroup, 0, len(p.groups))
+	for _, group := range p.groups {
+		out = append(out, group)
+	}
+	return out
 }
 
 func (p *Proxy) HandleListModels(c *gin.Context) {
@@ -66,7 +126,7 @@ func (p *Proxy) HandleListModels(c *gin.Context) {
 	}
 	keyInfo := keyValue.(auth.Key)
 
-	allowed := p.accessibleModelGroups(keyInfo.ModelList)
+	allowed := p.AccessibleModelGroups(keyInfo.ModelList)
 	data := make([]gin.H, 0, len(allowed))
 	for _, model := range allowed {
 		data = append(data, gin.H{
@@ -82,10 +142,12 @@ func (p *Proxy) HandleListModels(c *gin.Context) {
 	})
 }
 
-func (p *Proxy) accessibleModelGroups(modelList auth.StringSlice) []string {
-	all := make([]string, 0, len(p.groups))
-	for name := range p.groups {
-		all = append(all, name)
+// AccessibleModelGroups lists, sorted, the configured model groups a model list grants.
+func (p *Proxy) AccessibleModelGroups(modelList auth.StringSlice) []string {
+	groups := p.snapshotGroups()
+	all := make([]string, 0, len(groups))
+	for _, group := range groups {
+		all = append(all, group.Name)
 	}
 	sort.Strings(all)
 
@@ -110,124 +172,228 @@ func (p *Proxy) accessibleModelGroups(modelList auth.StringSlice) []string {
 	return out
 }
 
-func GetOrCreateRequestRing(key string, rpm int) *RequestRing {
-	if rpm <= 0 {
-		return nil
-	}
+func (p *Proxy) HandleRequest(c *gin.Context) {
+	modelGroup := c.MustGet("modelGroup").(string)
+	rawBody := c.MustGet("rawBody").([]byte)
+	logger := c.MustGet("logger").(*zap.Logger)
+	endpointPath := c.Request.URL.Path
 
-	keyRequestRingMu.RLock()
-	ring, ok := keyRequestRing[key]
-	keyRequestRingMu.RUnlock()
-	if ok && ring != nil && ring.maxRequests == rpm {
-		return ring
+	_, groupCfg, exists := p.modelGroup(modelGroup)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model group not found"})
+		return
 	}
 
-	keyRequestRingMu.Lock()
-	defer keyRequestRingMu.Unlock()
-	if existing, exists := keyRequestRing[key]; exists {
-		if existing != nil && existing.maxRequests == rpm {
-			return existing
+	if cacheTTL(groupCfg) > 0 {
+		c.Header(headerCacheHit, "false")
+	}
+	cacheKey, ttl := p.cacheLookupKey(c, endpointPath, groupCfg, rawBody)
+	if cacheKey != "" {
+		if entry, hit := p.cache.Get(cacheKey, time.Now()); hit {
+			c.Header(headerModelGroup, modelGroup)
+			serveCached(c, groupCfg, entry)
+			return
 		}
+		capture := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = capture
+		defer func() {
+			c.Writer = capture.ResponseWriter
+			// A fallback group's answer is not cached under the requested group's key.
+			if _, fellBack := c.Get(spend.ContextServedModelGroup); fellBack {
+				return
+			}
+			if entry, ok := capture.cachedResponse(c, cacheKey, ttl, time.Now()); ok {
+				p.cache.Set(entry)
+			}
+		}()
 	}
-	created := NewRequestRing(1*time.Minute, rpm)
-	keyRequestRing[key] = created
-	return created
-}
-
-func RemoveRequestRing(key string) {
-	keyRequestRingMu.Lock()
-	defer keyRequestRingMu.Unlock()
-	delete(keyRequestRing, key)
-}
 
-func GetOrCreateKeySpendQueue(keyID int) chan float64 {
-	keySpendQueueMu.RLock()
-	ch, ok := keySpendQueue[keyID]
-	keySpendQueueMu.RUnlock()
-	if ok {
-		return ch
+	if !checkWeeklySpendHeadroom(c, groupCfg) {
+		return
+	}
+	if !holdBalance(c, p.chainGroups(c, groupCfg)) {
+		return
+	}
+	reservation, allowed := reserveTokens(c, groupCfg)
+	if !allowed {
+		return
 	}
+	defer reservation.settleFromContext(c)
 
-	keySpendQueueMu.Lock()
-	defer keySpendQueueMu.Unlock()
-	if existing, exists := keySpendQueue[keyID]; exists {
-		return existing
+	// requestCtx ends at the group's retry deadline or when the client goes away; no new
+	// attempt or backoff starts after that, including in fallback groups.
+	policy := newRetryPolicy(groupCfg.Retry)
+	requestCtx := c.Request.Context()
+	if policy.timeout > 0 {
+		var cancel context.CancelFunc
+		requestCtx, cancel = context.WithTimeout(requestCtx, policy.timeout)
+		defer cancel()
 	}
-	created := make(chan float64, 100)
-	keySpendQueue[keyID] = created
-	return created
-}
 
-func SnapshotKeySpendQueue() map[int]chan float64 {
-	keySpendQueueMu.RLock()
-	defer keySpendQueueMu.RUnlock()
+	var lastErr error
+	for i, served := range fallbackChain(c, groupCfg) {
+		if i > 0 {
+			if requestCtx.Err() != nil || deadlineReached(requestCtx) {
+				break
+			}
+			reason := fallbackReason(lastErr)
+			logger.Warn("model group failed; trying fallback group",
+				zap.Error(lastErr),
+				zap.String("model", modelGroup),
+				zap.String("fallback", served),
+				zap.String("reason", reason),
+			)
+			metrics.IncGroupFallback(modelGroup, served, reason)
+			c.Set(spend.ContextServedModelGroup, served)
+		}
+		c.Header(headerModelGroup, served)
 
-	out := make(map[int]chan float64, len(keySpendQueue))
-	for k, v := range keySpendQueue {
-		out[k] = v
+		handled, err := p.serveGroup(requestCtx, c, served, endpointPath, rawBody, logger)
+		if handled {
+			return
+		}
+		lastErr = err
 	}
-	return out
-}
-
-func (p *Proxy) HandleRequest(c *gin.Context) {
-	modelGroup := c.MustGet("modelGroup").(string)
-	rawBody := c.MustGet("rawBody").([]byte)
-	logger := c.MustGet("logger").(*zap.Logger)
-	endpointPath := c.Request.URL.Path
 
-	blcr, exists := p.balancers[modelGroup]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "model group not found"})
+	if c.Writer.Written() {
 		return
 	}
-	if blcr.Size() == 0 {
+	if deadlineReached(requestCtx) {
+		logger.Warn("request deadline exceeded", zap.Error(lastErr), zap.Duration("timeout", policy.timeout))
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "upstream request deadline exceeded"})
+		return
+	}
+	var windowErr *contextWindowError
+	if errors.As(lastErr, &windowErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "context_length_exceeded", "error": windowErr.Error()})
+		return
+	}
+	if errors.Is(lastErr, errNoAvailableModels) {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no available models"})
 		return
 	}
+	var statusErr *upstreamStatusError
+	if errors.As(lastErr, &statusErr) && statusErr.status < http.StatusInternalServerError {
+		// Rate limits and timeouts keep their status so clients can back off themselves. The
+		// provider's body and x-ratelimit-* headers are relayed when there was a response.
+		if statusErr.header != nil {
+			copyResponseHeaders(c, statusErr.header)
+			c.Data(statusErr.status, contentType(statusErr.header), statusErr.clientBody)
+			return
+		}
+		if statusErr.retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(statusErr.retryAfter.Round(time.Second)/time.Second)))
+		}
+		c.JSON(statusErr.status, gin.H{"error": statusErr.Error()})
+		return
+	}
+	if lastErr != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": lastErr.Error()})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": "all upstream models failed"})
+}
 
+// serveGroup tries the endpoints of one model group with retries. It reports handled once a
+// response has been written; otherwise err says why the group could not serve the request.
+func (p *Proxy) serveGroup(requestCtx context.Context, c *gin.Context, modelGroup string, endpointPath string, rawBody []byte, logger *zap.Logger) (bool, error) {
+	blcr, groupCfg, exists := p.modelGroup(modelGroup)
+	if !exists {
+		return false, fmt.Errorf("model group not found: %s", modelGroup)
+	}
+	if blcr.Size() == 0 {
+		return false, errNoAvailableModels
+	}
 	selectionCtx := buildSelectionContext(c, modelGroup, endpointPath)
 	candidates := distinctRetryCandidates(blcr, selectionCtx)
 	if len(candidates) == 0 {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no available models"})
-		return
+		return false, errNoAvailableModels
+	}
+	candidates, err := fitContextWindow(modelGroup, candidates, requiredContextTokens(c, groupCfg))
+	if err != nil {
+		return false, err
 	}
+
+	policy := newRetryPolicy(groupCfg.Retry)
 	var lastErr error
 
+candidates:
 	for candidateIndex, upstreamModel := range candidates {
 		maxAttempts := perUpstreamAttempts(upstreamModel)
 		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			if requestCtx.Err() != nil || deadlineReached(requestCtx) {
+				break candidates
+			}
+			admitted, trial := admitAttempt(blcr, upstreamModel)
+			if !admitted {
+				// Another request holds the half-open trial of this endpoint.
+				if lastErr == nil {
+					lastErr = errNoAvailableModels
+				}
+				continue candidates
+			}
 			start := time.Now()
-			status, shouldRetry, err := p.forwardOnce(c, endpointPath, modelGroup, upstreamModel, rawBody, logger)
-			observeBalancer(blcr, upstreamModel, time.Since(start), err == nil, shouldRetry)
+			attemptCtx, span := startAttemptSpan(requestCtx, modelGroup, upstreamModel, candidateIndex+1, attempt)
+			status, shouldRetry, err := p.forwardTracked(attemptCtx, c, blcr, endpointPath, modelGroup, upstreamModel, rawBody, logger)
+			endAttemptSpan(span, status, shouldRetry, err)
+			credentialRejected := errors.Is(err, errCredentialRejected)
+			// A client that hung up says nothing about the endpoint.
+			clientGone := errors.Is(requestCtx.Err(), context.Canceled)
+			observed := observeBalancer(blcr, upstreamModel, time.Since(start), err == nil, shouldRetry && !credentialRejected && !clientGone)
+			if trial && !observed {
+				blcr.(balancer.TrialGate).EndTrial(upstreamModel)
+			}
+			metrics.ObserveUpstream(modelGroup, upstreamModel.Name, providerName(upstreamModel), status, time.Since(start))
 			if err == nil {
-				return
+				return true, nil
 			}
 
 			lastErr = err
+			if credentialRejected {
+				// The rejected key is benched, so this terminates; rotating keys does not use
+				// up the endpoint's retries.
+				logger.Warn("provider credential rejected; rotating to another key",
+					zap.Int("status", status),
+					zap.String("upstream", upstreamModel.Name),
+				)
+				attempt--
+				continue
+			}
 			if !shouldRetry {
+				if fallbackReason(err) != fallbackExhausted {
+					// Another endpoint of the same group would reject it too.
+					return false, err
+				}
 				logger.Warn("request failed without retry",
 					zap.Error(err),
 					zap.Int("status", status),
 					zap.Int("candidate", candidateIndex+1),
 					zap.Int("attempt", attempt),
 				)
-				if c.Writer.Written() {
-					return
+				if !c.Writer.Written() {
+					c.JSON(status, gin.H{"error": err.Error()})
 				}
-				c.JSON(status, gin.H{"error": err.Error()})
-				return
+				return true, err
 			}
 
 			if attempt < maxAttempts {
-				logger.Warn("request failed and retrying same upstream",
-					zap.Error(err),
-					zap.Int("status", status),
-					zap.Int("candidate", candidateIndex+1),
-					zap.Int("attempt", attempt),
-					zap.Int("max_attempts", maxAttempts),
-					zap.String("upstream", upstreamModel.Name),
-				)
-				continue
+				wait := policy.wait(attempt, err)
+				if canWait(requestCtx, wait) {
+					logger.Warn("request failed and retrying same upstream",
+						zap.Error(err),
+						zap.Int("status", status),
+						zap.Int("candidate", candidateIndex+1),
+						zap.Int("attempt", attempt),
+						zap.Int("max_attempts", maxAttempts),
+						zap.String("upstream", upstreamModel.Name),
+						zap.Duration("backoff", wait),
+					)
+					metrics.IncRetry(modelGroup, upstreamModel.Name)
+					if !sleepContext(requestCtx, wait) {
+						break candidates
+					}
+					continue
+				}
 			}
 
 			logger.Warn("request failed and retrying another upstream",
@@ -237,24 +403,43 @@ func (p *Proxy) HandleRequest(c *gin.Context) {
 				zap.Int("attempt", attempt),
 				zap.String("upstream", upstreamModel.Name),
 			)
+			if candidateIndex < len(candidates)-1 {
+				metrics.IncFallback(modelGroup, upstreamModel.Name)
+			}
+			break
 		}
 	}
 
-	if lastErr != nil {
-		if c.Writer.Written() {
-			return
-		}
-		c.JSON(http.StatusBadGateway, gin.H{"error": lastErr.Error()})
-		return
+	// A stream that failed after its first bytes cannot move elsewhere.
+	return c.Writer.Written(), lastErr
+}
+
+// cacheLookupKey returns the L1 cache key and TTL, or an empty key when the group has no
+// cache TTL or the client opted out.
+func (p *Proxy) cacheLookupKey(c *gin.Context, endpointPath string, group models.ModelGroup, rawBody []byte) (string, time.Duration) {
+	ttl := cacheTTL(group)
+	if p.cache == nil || ttl <= 0 || cacheBypassed(c) {
+		return "", 0
 	}
-	if c.Writer.Written() {
-		return
+	key, ok := responseCacheKey(c, endpointPath, group, rawBody)
+	if !ok {
+		return "", 0
 	}
-	c.JSON(http.StatusBadGateway, gin.H{"error": "all upstream models failed"})
+	return key, ttl
 }
 
-func (p *Proxy) forwardOnce(c *gin.Context, endpointPath string, modelGroup string, upstreamModel *models.ModelConfig, rawBody []byte, logger *zap.Logger) (int, bool, error) {
-	groupCfg, ok := p.groups[modelGroup]
+// forwardTracked counts the attempt as in flight on balancers that track load. forwardOnce
+// returns only after the response body or stream has been fully relayed.
+func (p *Proxy) forwardTracked(ctx context.Context, c *gin.Context, blcr balancer.Balancer, endpointPath string, modelGroup string, upstreamModel *models.ModelConfig, rawBody []byte, logger *zap.Logger) (int, bool, error) {
+	if tracker, ok := blcr.(balancer.InflightTracker); ok {
+		tracker.Acquire(upstreamModel)
+		defer tracker.Release(upstreamModel)
+	}
+	return p.forwardOnce(ctx, c, endpointPath, modelGroup, upstreamModel, rawBody, logger)
+}
+
+func (p *Proxy) forwardOnce(ctx context.Context, c *gin.Context, endpointPath string, modelGroup string, upstreamModel *models.ModelConfig, rawBody []byte, logger *zap.Logger) (int, bool, error) {
+	_, groupCfg, ok := p.modelGroup(modelGroup)
 	if !ok {
 		return http.StatusNotFound, false, fmt.Errorf("model group not found: %s", modelGroup)
 	}
@@ -263,18 +448,44 @@ func (p *Proxy) forwardOnce(c *gin.Context, endpointPath string, modelGroup stri
 	if err != nil {
 		return http.StatusBadRequest, false, err
 	}
+	adapter := SelectAdapter(endpointPath, upstreamModel)
+	var usageTracker *streamUsageTracker
+	if isStreamRequest(c) && tracksStreamUsage(adapter, endpointPath) {
+		usageTracker = &streamUsageTracker{}
+		if !upstreamModel.DisableStreamUsage {
+			preparedBody, usageTracker.stripUsageChunk, err = requestStreamUsage(preparedBody)
+			if err != nil {
+				return http.StatusBadRequest, false, err
+			}
+		}
+	}
 
-	adapter := SelectAdapter(upstreamModel)
-	req, err := adapter.BuildRequest(c, endpointPath, upstreamModel, preparedBody)
+	credential, ok := p.credentials.pick(upstreamModel, time.Now())
+	if !ok {
+		body := []byte("all provider credentials for " + upstreamModel.Name + " are benched")
+		return http.StatusTooManyRequests, true, &upstreamStatusError{status: http.StatusTooManyRequests, body: body}
+	}
+	target := withCredential(upstreamModel, credential)
+
+	req, err := adapter.BuildRequest(c, endpointPath, target, preparedBody)
 	if err != nil {
+		if errors.Is(err, errRequestTranslation) {
+			return http.StatusBadRequest, false, err
+		}
 		return http.StatusInternalServerError, false, err
 	}
+	// ctx carries the request deadline and the client's disconnect, which must also end an
+	// attempt in flight; streams have no client timeout of their own.
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
+	translator, translating := adapter.(ResponseTranslator)
 
 	timeoutSeconds := upstreamModel.TimeoutSeconds
 	if timeoutSeconds <= 0 {
 		timeoutSeconds = 60
 	}
-	client := buildHTTPClient(upstreamModel, isStreamRequest(c), time.Duration(timeoutSeconds)*time.Second)
+	timeout := attemptTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	client := buildHTTPClient(upstreamModel, isStreamRequest(c), timeout)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -282,22 +493,72 @@ func (p *Proxy) forwardOnce(c *gin.Context, endpointPath string, modelGroup stri
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= http.StatusInternalServerError {
+	if rejectsCredential(resp.StatusCode) && len(endpointCredentials(upstreamModel)) > 1 {
+		now := time.Now()
+		p.credentials.bench(credential, now.Add(credentialBench(resp.StatusCode, resp.Header, now)))
+		if p.credentials.rotatable(upstreamModel, now) {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			return resp.StatusCode, true, fmt.Errorf("%w: upstream status %d", errCredentialRejected, resp.StatusCode)
+		}
+	}
+
+	if newRetryPolicy(groupCfg.Retry).retryable(resp.StatusCode) {
+		respBody, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return http.StatusBadGateway, true, readErr
+		}
+		retryAfter, _ := parseRetryAfter(resp.Header, time.Now())
+		statusErr := &upstreamStatusError{status: resp.StatusCode, retryAfter: retryAfter, body: respBody, header: resp.Header.Clone(), clientBody: respBody}
+		statusErr.header.Del("Content-Length")
+		if translating {
+			if translated, translateErr := translator.TranslateResponse(resp.StatusCode, respBody); translateErr == nil {
+				statusErr.clientBody = translated
+				statusErr.header.Set("Content-Type", "application/json")
+			}
+		}
+		return resp.StatusCode, true, statusErr
+	}
+
+	if len(groupCfg.Fallbacks) > 0 && resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError {
 		respBody, readErr := io.ReadAll(resp.Body)
 		if readErr != nil {
 			return http.StatusBadGateway, true, readErr
 		}
-		return resp.StatusCode, true, fmt.Errorf("upstream status %d: %s", resp.StatusCode, truncateBody(respBody))
+		if reason := classifyFallback(resp.StatusCode, respBody); reason != "" {
+			return resp.StatusCode, false, &upstreamStatusError{status: resp.StatusCode, body: respBody, fallback: reason}
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
 	}
 
 	if shouldStream(c, resp) {
 		copyResponseHeaders(c, resp.Header)
+		if translating {
+			c.Writer.Header().Del("Content-Length")
+		}
 		c.Status(resp.StatusCode)
-		streamUsage, streamErr := streamToClient(c, resp.Body, adapter)
+		var streamTranslator StreamTranslator
+		if translating {
+			streamTranslator = translator.NewStreamTranslator(c)
+		} else if usageTracker != nil {
+			streamTranslator = usageTracker
+		}
+		streamUsage, streamErr := streamToClient(c, resp.Body, adapter, streamTranslator, func() {
+			recordFirstToken(ctx, time.Since(upstreamStart))
+		})
 		if streamErr != nil {
 			return http.StatusBadGateway, false, streamErr
 		}
-		setSpendContext(c, upstreamModel, resp.Header, time.Since(upstreamStart))
+		c.Set(contextResponseComplete, true)
+		setSpendContext(c, target, time.Since(upstreamStart))
+		if len(streamUsage) == 0 && usageTracker != nil {
+			logger.Warn("stream completed without token usage; billing locally counted tokens",
+				zap.String("model", modelGroup),
+				zap.String("upstream", upstreamModel.Name),
+			)
+			if streamUsage, streamErr = usageTracker.estimatedUsage(PromptTokenEstimate(c)); streamErr != nil {
+				return http.StatusBadGateway, false, streamErr
+			}
+		}
 		if len(streamUsage) > 0 {
 			c.Set(spend.ContextUpstreamResp, streamUsage)
 		} else {
@@ -311,7 +572,6 @@ func (p *Proxy) forwardOnce(c *gin.Context, endpointPath string, modelGroup stri
 			zap.String("model", modelGroup),
 			zap.String("upstream", upstreamModel.Name),
 			zap.Int64("latency_ms", latencyMilliseconds(time.Since(upstreamStart))),
-			zap.Bool("cache_hit", cacheHitFromHeaders(resp.Header)),
 		)
 		return resp.StatusCode, false, nil
 	}
@@ -321,9 +581,29 @@ func (p *Proxy) forwardOnce(c *gin.Context, endpointPath string, modelGroup stri
 		return http.StatusBadGateway, true, err
 	}
 
+	clientBody := respBody
+	clientContentType := contentType(resp.Header)
+	if translating {
+		translated, translateErr := translator.TranslateResponse(resp.StatusCode, respBody)
+		if translateErr != nil {
+			logger.Warn("upstream response translation failed; forwarding raw body",
+				zap.Error(translateErr),
+				zap.String("model", modelGroup),
+				zap.String("upstream", upstreamModel.Name),
+			)
+		} else {
+			clientBody = translated
+			clientContentType = "application/json"
+		}
+	}
+
 	copyResponseHeaders(c, resp.Header)
-	c.Data(resp.StatusCode, contentType(resp.Header), respBody)
-	setSpendContext(c, upstreamModel, resp.Header, time.Since(upstreamStart))
+	if translating {
+		c.Writer.Header().Del("Content-Length")
+	}
+	c.Data(resp.StatusCode, clientContentType, clientBody)
+	c.Set(contextResponseComplete, true)
+	setSpendContext(c, target, time.Since(upstreamStart))
 
 	if spendPayload, payloadErr := adapter.BuildSpendPayload(respBody); payloadErr == nil && len(spendPayload) > 0 {
 		c.Set(spend.ContextUpstreamResp, spendPayload)
@@ -334,7 +614,6 @@ func (p *Proxy) forwardOnce(c *gin.Context, endpointPath string, modelGroup stri
 		zap.String("model", modelGroup),
 		zap.String("upstream", upstreamModel.Name),
 		zap.Int64("latency_ms", latencyMilliseconds(time.Since(upstreamStart))),
-		zap.Bool("cache_hit", cacheHitFromHeaders(resp.Header)),
 	)
 
 	return resp.StatusCode, false, nil
@@ -432,15 +711,16 @@ func buildSelectionContext(c *gin.Context, modelGroup string, endpointPath strin
 	return ctx
 }
 
-func setSpendContext(c *gin.Context, upstreamModel *models.ModelConfig, headers http.Header, latency time.Duration) {
+func setSpendContext(c *gin.Context, upstreamModel *models.ModelConfig, latency time.Duration) {
 	if upstreamModel == nil {
 		return
 	}
 	c.Set(spend.ContextProvider, providerName(upstreamModel))
 	c.Set(spend.ContextUpstream, upstreamModel.Name)
 	c.Set(spend.ContextUpstreamModel, upstreamModel.Name)
+	c.Set(spend.ContextCredential, auth.RedactKeyContent(upstreamModel.APIKey))
+	c.Set(spend.ContextPricing, upstreamModel.Pricing)
 	c.Set(spend.ContextLatencyMS, latencyMilliseconds(latency))
-	c.Set(spend.ContextCacheHit, cacheHitFromHeaders(headers))
 }
 
 func providerName(upstreamModel *models.ModelConfig) string {
@@ -468,21 +748,6 @@ func latencyMilliseconds(latency time.Duration) int64 {
 	return ms
 }
 
-func cacheHitFromHeaders(headers http.Header) bool {
-	values := []string{
-		headers.Get("x-cache-hit"),
-		headers.Get("x-cache"),
-		headers.Get("cf-cache-status"),
-	}
-	for _, value := range values {
-		switch strings.ToLower(strings.TrimSpace(value)) {
-		case "1", "true", "yes", "hit":
-			return true
-		}
-	}
-	return false
-}
-
 func firstStableHeader(headers map[string]string) string {
 	for _, name := range []string{"X-Janus-Client", "X-Client-ID", "X-User-ID", "X-Team-ID", "X-Forwarded-User"} {
 		if value := strings.TrimSpace(headers[name]); value != "" {
@@ -492,18 +757,32 @@ func firstStableHeader(headers map[string]string) string {
 	return ""
 }
 
-func observeBalancer(blcr balancer.Balancer, upstreamModel *models.ModelConfig, latency time.Duration, success bool, shouldRetry bool) {
+// observeBalancer feeds the attempt's outcome to the balancer and reports whether it did;
+// failures that are not worth retrying say nothing about the endpoint and are not observed.
+func observeBalancer(blcr balancer.Balancer, upstreamModel *models.ModelConfig, latency time.Duration, success bool, shouldRetry bool) bool {
 	observer, ok := blcr.(balancer.Observer)
 	if !ok {
-		return
+		return false
 	}
 	if success {
 		observer.Observe(upstreamModel, latency, true)
-		return
+		return true
 	}
 	if shouldRetry {
 		observer.Observe(upstreamModel, latency, false)
+		return true
 	}
+	return false
+}
+
+// admitAttempt asks the balancer's breaker whether an attempt may go to upstreamModel and
+// whether it is the endpoint's half-open trial.
+func admitAttempt(blcr balancer.Balancer, upstreamModel *models.ModelConfig) (admitted bool, trial bool) {
+	gate, ok := blcr.(balancer.TrialGate)
+	if !ok {
+		return true, false
+	}
+	return gate.Admit(upstreamModel)
 }
 
 func distinctRetryCandidates(blcr balancer.Balancer, ctx balancer.SelectionContext) []*models.ModelConfig {
@@ -512,6 +791,15 @@ func distinctRetryCandidates(blcr balancer.Balancer, ctx balancer.SelectionConte
 	}
 
 	all := blcr.Models()
+	if checker, ok := blcr.(balancer.AvailabilityChecker); ok {
+		available := all[:0]
+		for _, model := range all {
+			if checker.Available(model) {
+				available = append(available, model)
+			}
+		}
+		all = available
+	}
 	if len(all) == 0 {
 		return nil
 	}
@@ -560,7 +848,9 @@ func upstreamModelKey(model *models.ModelConfig) string {
 	return model.Name + "\x00" + model.BaseURL
 }
 
-func streamToClient(c *gin.Context, body io.Reader, adapter ProviderAdapter) ([]byte, error) {
+// streamToClient relays the stream through translator, when set, and calls onFirstChunk once,
+// when the first chunk reaches the client. Usage is parsed from the upstream lines.
+func streamToClient(c *gin.Context, body io.Reader, adapter ProviderAdapter, translator StreamTranslator, onFirstChunk func()) ([]byte, error) {
 	flusher, _ := c.Writer.(http.Flusher)
 	reader := bufio.NewReader(body)
 	var requestID string
@@ -569,11 +859,21 @@ func streamToClient(c *gin.Context, body io.Reader, adapter ProviderAdapter) ([]
 	for {
 		line, err := reader.ReadBytes('\n')
 		if len(line) > 0 {
-			if _, writeErr := c.Writer.Write(line); writeErr != nil {
-				return nil, writeErr
+			out := line
+			if translator != nil {
+				out = translator.TranslateStreamLine(line)
 			}
-			if flusher != nil {
-				flusher.Flush()
+			if len(out) > 0 {
+				if _, writeErr := c.Writer.Write(out); writeErr != nil {
+					return nil, writeErr
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+				if onFirstChunk != nil {
+					onFirstChunk()
+					onFirstChunk = nil
+				}
 			}
 			if adapter != nil {
 				adapter.ParseSpendStreamLine(line, &requestID, &usage)
@@ -586,6 +886,16 @@ func streamToClient(c *gin.Context, body io.Reader, adapter ProviderAdapter) ([]
 			return nil, err
 		}
 	}
+	if translator != nil {
+		if out := translator.Finish(); len(out) > 0 {
+			if _, writeErr := c.Writer.Write(out); writeErr != nil {
+				return nil, writeErr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
 
 	if usage == nil {
 		return nil, nil
@@ -603,12 +913,25 @@ func streamToClient(c *gin.Context, body io.Reader, adapter ProviderAdapter) ([]
 
 func copyResponseHeaders(c *gin.Context, headers http.Header) {
 	for key, values := range headers {
+		// Headers Janus sets itself take precedence over the provider's.
+		if isGatewayOwnedHeader(key) && c.Writer.Header().Get(key) != "" {
+			continue
+		}
 		for _, value := range values {
 			c.Header(key, value)
 		}
 	}
 }
 
+func isGatewayOwnedHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case headerRateLimitRemainingTokens, headerRateLimitLimitTokens, headerCacheHit:
+		return true
+	default:
+		return false
+	}
+}
+
 func contentType(headers http.Header) string {
 	ct := headers.Get("Content-Type")
 	if ct == "" {
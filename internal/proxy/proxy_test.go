@@ -3,6 +3,7 @@ package proxy
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net"
@@ -17,6 +18,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/Uuq114/JanusLLM/internal/auth"
 	"github.com/Uuq114/JanusLLM/internal/balancer"
 	"github.com/Uuq114/JanusLLM/internal/models"
 	"github.com/Uuq114/JanusLLM/internal/spend"
@@ -207,14 +209,22 @@ func TestRegisterModelGroupSupportsNewBalancerStrategies(t *testing.T) {
 	p.RegisterModelGroup(&models.ModelGroup{Name: "sticky-group", Strategy: "client-sticky"})
 	p.RegisterModelGroup(&models.ModelGroup{Name: "rr-group", Strategy: "round_robin"})
 
-	if _, ok := p.balancers["latency-group"].(*balancer.LatencyBalancer); !ok {
-		t.Fatalf("expected latency strategy to create LatencyBalancer, got %T", p.balancers["latency-group"])
+	strategy := func(name string) balancer.Balancer {
+		wrapped, ok := p.balancers[name].(*balancer.CircuitBreakerBalancer)
+		if !ok {
+			t.Fatalf("expected %s to be wrapped in a circuit breaker, got %T", name, p.balancers[name])
+		}
+		return wrapped.Unwrap()
+	}
+
+	if got, ok := strategy("latency-group").(*balancer.LatencyBalancer); !ok {
+		t.Fatalf("expected latency strategy to create LatencyBalancer, got %T", got)
 	}
-	if _, ok := p.balancers["sticky-group"].(*balancer.ClientStickyBalancer); !ok {
-		t.Fatalf("expected client-sticky strategy to create ClientStickyBalancer, got %T", p.balancers["sticky-group"])
+	if got, ok := strategy("sticky-group").(*balancer.ClientStickyBalancer); !ok {
+		t.Fatalf("expected client-sticky strategy to create ClientStickyBalancer, got %T", got)
 	}
-	if _, ok := p.balancers["rr-group"].(*balancer.RoundRobinBalancer); !ok {
-		t.Fatalf("expected round_robin strategy to create RoundRobinBalancer, got %T", p.balancers["rr-group"])
+	if got, ok := strategy("rr-group").(*balancer.RoundRobinBalancer); !ok {
+		t.Fatalf("expected round_robin strategy to create RoundRobinBalancer, got %T", got)
 	}
 }
 
@@ -222,7 +232,7 @@ func TestHandleRequestRecordsSpendForJSONWithoutContentType(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	var upstreamHits int32
-	respBody := []byte(`{"id":"chatcmpl-123","usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`)
+	respBody := []byte(`{"id":"chatcmpl-123","usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7,"prompt_tokens_details":{"cached_tokens":2}}}`)
 	upstreamURL, closeUpstream := startRawJSONServer(t, &upstreamHits, http.StatusOK, respBody, "X-Cache-Hit: true")
 	defer closeUpstream()
 
@@ -278,7 +288,7 @@ func TestHandleRequestRecordsSpendForJSONWithoutContentType(t *testing.T) {
 	if upstreamResp.Id != "chatcmpl-123" {
 		t.Fatalf("expected request id to be preserved, got %q", upstreamResp.Id)
 	}
-	if upstreamResp.Usage.PromptTokens != 3 || upstreamResp.Usage.CompletionTokens != 4 || upstreamResp.Usage.TotalTokens != 7 {
+	if upstreamResp.Usage.PromptTokens != 3 || upstreamResp.Usage.CompletionTokens != 4 || upstreamResp.Usage.TotalTokens != 7 || upstreamResp.Usage.CachedTokens != 2 {
 		t.Fatalf("unexpected usage recorded: %+v", upstreamResp.Usage)
 	}
 	if got := stringContextValue(t, ctx, spend.ContextProvider); got != "anthropic" {
@@ -290,8 +300,8 @@ func TestHandleRequestRecordsSpendForJSONWithoutContentType(t *testing.T) {
 	if got := int64ContextValue(t, ctx, spend.ContextLatencyMS); got <= 0 {
 		t.Fatalf("expected positive latency_ms, got %d", got)
 	}
-	if got := boolContextValue(t, ctx, spend.ContextCacheHit); !got {
-		t.Fatalf("expected cache hit context to be true")
+	if _, exists := ctx.Get(spend.ContextCacheHit); exists {
+		t.Fatal("expected upstream cache headers not to mark a gateway cache hit")
 	}
 }
 
@@ -302,7 +312,7 @@ func TestHandleRequestRecordsSpendContextAfterStreamCompletes(t *testing.T) {
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("X-Cache-Hit", "1")
 		w.WriteHeader(http.StatusOK)
-		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-stream\",\"usage\":{\"prompt_tokens\":2,\"completion_tokens\":5,\"total_tokens\":7}}\n\n")
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-stream\",\"usage\":{\"prompt_tokens\":2,\"completion_tokens\":5,\"total_tokens\":7,\"completion_tokens_details\":{\"reasoning_tokens\":3}}}\n\n")
 		_, _ = io.WriteString(w, "data: [DONE]\n\n")
 	}))
 	defer upstream.Close()
@@ -323,7 +333,7 @@ func TestHandleRequestRecordsSpendContextAfterStreamCompletes(t *testing.T) {
 
 	rec := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(rec)
-	body := []byte(`{"model":"stream-spend-group","stream":true}`)
+	body := []byte(`{"model":"stream-spend-group","stream":true,"stream_options":{"include_usage":true}}`)
 	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
 	ctx.Request.Header.Set("Accept", "text/event-stream")
 	ctx.Request.Header.Set("Content-Type", "application/json")
@@ -355,7 +365,7 @@ func TestHandleRequestRecordsSpendContextAfterStreamCompletes(t *testing.T) {
 	if upstreamResp.Id != "chatcmpl-stream" {
 		t.Fatalf("expected stream request id to be preserved, got %q", upstreamResp.Id)
 	}
-	if upstreamResp.Usage.PromptTokens != 2 || upstreamResp.Usage.CompletionTokens != 5 || upstreamResp.Usage.TotalTokens != 7 {
+	if upstreamResp.Usage.PromptTokens != 2 || upstreamResp.Usage.CompletionTokens != 5 || upstreamResp.Usage.TotalTokens != 7 || upstreamResp.Usage.ReasoningTokens != 3 {
 		t.Fatalf("unexpected stream usage recorded: %+v", upstreamResp.Usage)
 	}
 	if got := stringContextValue(t, ctx, spend.ContextProvider); got != "openai" {
@@ -364,8 +374,8 @@ func TestHandleRequestRecordsSpendContextAfterStreamCompletes(t *testing.T) {
 	if got := int64ContextValue(t, ctx, spend.ContextLatencyMS); got <= 0 {
 		t.Fatalf("expected positive latency_ms, got %d", got)
 	}
-	if got := boolContextValue(t, ctx, spend.ContextCacheHit); !got {
-		t.Fatalf("expected cache hit context to be true")
+	if _, exists := ctx.Get(spend.ContextCacheHit); exists {
+		t.Fatal("expected upstream cache headers not to mark a gateway cache hit")
 	}
 }
 
@@ -396,8 +406,9 @@ func TestHandleRequestSkipsStreamSpendWhenUsageMissing(t *testing.T) {
 
 	rec := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(rec)
+	// Chat completions streams fall back to counted tokens; other streams are not billed.
 	body := []byte(`{"model":"stream-no-usage-group","stream":true}`)
-	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", bytes.NewReader(body))
 	ctx.Request.Header.Set("Accept", "text/event-stream")
 	ctx.Request.Header.Set("Content-Type", "application/json")
 	ctx.Set("modelGroup", groupName)
@@ -421,6 +432,65 @@ func TestHandleRequestSkipsStreamSpendWhenUsageMissing(t *testing.T) {
 	}
 }
 
+func TestHandleRequestRecordsSpendForResponsesStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var upstreamPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath = r.URL.Path
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "event: response.created\n")
+		_, _ = io.WriteString(w, "data: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_stream\"}}\n\n")
+		_, _ = io.WriteString(w, "event: response.completed\n")
+		_, _ = io.WriteString(w, "data: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_stream\",\"usage\":{\"input_tokens\":6,\"output_tokens\":2,\"total_tokens\":8}}}\n\n")
+	}))
+	defer upstream.Close()
+
+	groupName := "responses-group"
+	p := &Proxy{
+		balancers: map[string]balancer.Balancer{
+			groupName: &sequenceBalancer{
+				models: []*models.ModelConfig{
+					{Name: "responses-model", BaseURL: upstream.URL + "/v1"},
+				},
+			},
+		},
+		groups: map[string]models.ModelGroup{
+			groupName: {Name: groupName},
+		},
+	}
+
+	body := []byte(`{"model":"responses-group","stream":true,"input":"hi"}`)
+	rec, ctx := serveBody(p, groupName, body,
+		withPath("/v1/responses"),
+		withStream(),
+		withHeader("Accept", "text/event-stream"),
+		withHeader("Content-Type", "application/json"),
+	)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected responses stream to succeed, got status %d body %q", rec.Code, rec.Body.String())
+	}
+	if upstreamPath != "/v1/responses" {
+		t.Fatalf("expected upstream path /v1/responses, got %q", upstreamPath)
+	}
+	spendValue, exists := ctx.Get(spend.ContextUpstreamResp)
+	if !exists {
+		t.Fatal("expected upstreamResp to be recorded after response.completed")
+	}
+	var upstreamResp spend.UpstreamResp
+	if err := json.Unmarshal(spendValue.([]byte), &upstreamResp); err != nil {
+		t.Fatalf("failed to decode upstreamResp: %v", err)
+	}
+	if upstreamResp.Id != "resp_stream" {
+		t.Fatalf("expected responses id to be preserved, got %q", upstreamResp.Id)
+	}
+	if upstreamResp.Usage.PromptTokens != 6 || upstreamResp.Usage.CompletionTokens != 2 || upstreamResp.Usage.TotalTokens != 8 {
+		t.Fatalf("unexpected responses usage recorded: %+v", upstreamResp.Usage)
+	}
+}
+
 func TestHandleRequestHonorsRetryTimesForSameUpstream(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -569,6 +639,48 @@ func startRawJSONServer(t *testing.T, hits *int32, status int, body []byte, extr
 	return "http://" + listener.Addr().String(), closeFn
 }
 
+// testRequestOption adjusts the request context serveBody hands to HandleRequest.
+type testRequestOption func(ctx *gin.Context)
+
+func withKey(key auth.Key) testRequestOption {
+	return func(ctx *gin.Context) { ctx.Set("key", key) }
+}
+
+func withHeader(name string, value string) testRequestOption {
+	return func(ctx *gin.Context) { ctx.Request.Header.Set(name, value) }
+}
+
+func withStream() testRequestOption {
+	return func(ctx *gin.Context) { ctx.Set("isStreamRequest", true) }
+}
+
+func withPath(path string) testRequestOption {
+	return func(ctx *gin.Context) { ctx.Request.URL.Path = path }
+}
+
+func withRequestContext(requestCtx context.Context) testRequestOption {
+	return func(ctx *gin.Context) { ctx.Request = ctx.Request.WithContext(requestCtx) }
+}
+
+// serveBody runs HandleRequest for a /v1/chat/completions request to group. A nil body sends
+// an empty message list.
+func serveBody(p *Proxy, group string, body []byte, options ...testRequestOption) (*httptest.ResponseRecorder, *gin.Context) {
+	if body == nil {
+		body = []byte(`{"model":"` + group + `","messages":[]}`)
+	}
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	ctx.Set("modelGroup", group)
+	ctx.Set("rawBody", body)
+	ctx.Set("logger", zap.NewNop())
+	for _, option := range options {
+		option(ctx)
+	}
+	p.HandleRequest(ctx)
+	return rec, ctx
+}
+
 func stringContextValue(t *testing.T, ctx *gin.Context, key string) string {
 	t.Helper()
 	value, exists := ctx.Get(key)
@@ -595,15 +707,72 @@ func int64ContextValue(t *testing.T, ctx *gin.Context, key string) int64 {
 	return number
 }
 
-func boolContextValue(t *testing.T, ctx *gin.Context, key string) bool {
-	t.Helper()
-	value, exists := ctx.Get(key)
-	if !exists {
-		t.Fatalf("expected context key %q to be set", key)
+func TestHandleRequestTracksInflightForLeastInflightStrategy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var p *Proxy
+	var inflightDuringStream int
+	model := &models.ModelConfig{Name: "vllm", Type: "openai"}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflightDuringStream = p.balancers["vllm"].(*balancer.CircuitBreakerBalancer).Unwrap().(*balancer.LeastInflightBalancer).Inflight(model)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"id\":\"1\",\"choices\":[],\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":1,\"total_tokens\":2}}\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+	model.BaseURL = upstream.URL
+
+	p = NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{Name: "vllm", Strategy: "least-inflight", Models: []models.ModelConfig{*model}})
+
+	body := []byte(`{"model":"vllm","stream":true,"messages":[]}`)
+	rec, _ := serveBody(p, "vllm", body, withStream())
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected stream to succeed, got %d %q", rec.Code, rec.Body.String())
 	}
-	flag, ok := value.(bool)
-	if !ok {
-		t.Fatalf("expected context key %q to be bool, got %T", key, value)
+	if inflightDuringStream != 1 {
+		t.Fatalf("expected one in-flight request while streaming, got %d", inflightDuringStream)
+	}
+	tracker := p.balancers["vllm"].(*balancer.CircuitBreakerBalancer).Unwrap().(*balancer.LeastInflightBalancer)
+	if got := tracker.Inflight(model); got != 0 {
+		t.Fatalf("expected in-flight count to drop after the stream, got %d", got)
+	}
+}
+
+func TestReplaceModelGroupsKeepsStateForUnchangedEndpoints(t *testing.T) {
+	p := NewProxy()
+	fast := models.ModelConfig{Name: "fast", BaseURL: "http://fast"}
+	slow := models.ModelConfig{Name: "slow", BaseURL: "http://slow"}
+	p.ReplaceModelGroups([]models.ModelGroup{
+		{Name: "stable", Models: []models.ModelConfig{fast}},
+		{Name: "latency", Strategy: "latency", Models: []models.ModelConfig{fast, slow}},
+	})
+	stableBefore, _, _ := p.modelGroup("stable")
+	latencyBefore, _, _ := p.modelGroup("latency")
+	latencyBefore.(balancer.Observer).Observe(&slow, 10*time.Millisecond, true)
+	latencyBefore.(balancer.Observer).Observe(&fast, 500*time.Millisecond, true)
+
+	added := models.ModelConfig{Name: "added", BaseURL: "http://added"}
+	p.ReplaceModelGroups([]models.ModelGroup{
+		{Name: "stable", Models: []models.ModelConfig{fast}},
+		{Name: "latency", Strategy: "latency", Models: []models.ModelConfig{fast, slow, added}},
+	})
+
+	stableAfter, _, _ := p.modelGroup("stable")
+	if stableAfter != stableBefore {
+		t.Fatalf("expected unchanged group to keep its balancer")
+	}
+	latencyAfter, group, ok := p.modelGroup("latency")
+	if !ok || latencyAfter == latencyBefore || len(group.Models) != 3 {
+		t.Fatalf("expected changed group to get a new balancer with three endpoints")
+	}
+	if got := latencyAfter.Next(balancer.SelectionContext{}); got == nil || got.Name != "slow" {
+		t.Fatalf("expected latency stats to survive the reload, got %+v", got)
+	}
+
+	p.ReplaceModelGroups([]models.ModelGroup{{Name: "latency", Strategy: "latency", Models: []models.ModelConfig{fast}}})
+	if _, _, ok := p.modelGroup("stable"); ok {
+		t.Fatalf("expected removed group to be dropped")
 	}
-	return flag
 }
@@ -55,6 +55,27 @@ func (r *RequestRing) AllowAt(now time.Time) (bool, time.Duration) {
 	return true, 0
 }
 
+// UsageAt returns the requests counted in the window ending at now and how long until the
+// oldest of them leaves it.
+func (r *RequestRing) UsageAt(now time.Time) (int, time.Duration) {
+	if r.unlimited {
+		return 0, 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pruneLocked(now)
+	if len(r.requests) == 0 {
+		return 0, 0
+	}
+	resetAfter := r.requests[0].Add(r.window).Sub(now)
+	if resetAfter < 0 {
+		resetAfter = 0
+	}
+	return len(r.requests), resetAfter
+}
+
 func (r *RequestRing) pruneLocked(now time.Time) {
 	cutoff := now.Add(-r.window)
 	firstValid := 0
@@ -69,3 +90,95 @@ func (r *RequestRing) pruneLocked(now time.Time) {
 	}
 	r.requests = append(r.requests[:0], r.requests[firstValid:]...)
 }
+
+// RateLimiter admits requests against a per-key request budget.
+type RateLimiter interface {
+	AllowAt(now time.Time) (bool, time.Duration)
+	// UsageAt reports the requests counted against the budget without admitting one.
+	UsageAt(now time.Time) (int, time.Duration)
+}
+
+// LimiterBackend owns the per-key request limiters. Replicas sharing a backend share limits.
+type LimiterBackend interface {
+	Limiter(key string, rpm int) RateLimiter
+	Remove(key string)
+}
+
+var (
+	limiterBackendMu sync.RWMutex
+	limiterBackend   LimiterBackend = NewMemoryLimiterBackend()
+)
+
+func SetLimiterBackend(backend LimiterBackend) {
+	if backend == nil {
+		backend = NewMemoryLimiterBackend()
+	}
+	limiterBackendMu.Lock()
+	defer limiterBackendMu.Unlock()
+	limiterBackend = backend
+}
+
+func currentLimiterBackend() LimiterBackend {
+	limiterBackendMu.RLock()
+	defer limiterBackendMu.RUnlock()
+	return limiterBackend
+}
+
+func GetOrCreateRequestRing(key string, rpm int) RateLimiter {
+	if rpm <= 0 {
+		return nil
+	}
+	return currentLimiterBackend().Limiter(key, rpm)
+}
+
+// RequestRingUsage reports a key's current request window without admitting a request.
+func RequestRingUsage(key string, rpm int, now time.Time) (int, time.Duration) {
+	if rpm <= 0 {
+		return 0, 0
+	}
+	return currentLimiterBackend().Limiter(key, rpm).UsageAt(now)
+}
+
+func RemoveRequestRing(key string) {
+	currentLimiterBackend().Remove(key)
+}
+
+// MemoryLimiterBackend keeps one RequestRing per key in process memory.
+type MemoryLimiterBackend struct {
+	mu    sync.RWMutex
+	rings map[string]*RequestRing
+}
+
+func NewMemoryLimiterBackend() *MemoryLimiterBackend {
+	return &MemoryLimiterBackend{rings: make(map[string]*RequestRing)}
+}
+
+func (b *MemoryLimiterBackend) Limiter(key string, rpm int) RateLimiter {
+	return b.ring(key, rpm)
+}
+
+func (b *MemoryLimiterBackend) ring(key string, rpm int) *RequestRing {
+	b.mu.RLock()
+	ring, ok := b.rings[key]
+	b.mu.RUnlock()
+	if ok && ring != nil && ring.maxRequests == rpm {
+		return ring
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if existing, exists := b.rings[key]; exists {
+		if existing != nil && existing.maxRequests == rpm {
+			return existing
+		}
+	}
+	created := NewRequestRing(1*time.Minute, rpm)
+	b.rings[key] = created
+	return created
+}
+
+func (b *MemoryLimiterBackend) Remove(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.rings, key)
+}
@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+	"github.com/Uuq114/JanusLLM/internal/metrics"
+)
+
+// Counter windows are computed from the database clock so that replicas with skewed clocks
+// still count into the same row.
+const allowRateLimitCounterSQL = `
+WITH rate_window AS (
+	SELECT date_trunc('minute', now()) AS window_start
+), counted AS (
+	INSERT INTO janus_rate_limit_counter (limit_key, window_start, request_count)
+	SELECT ?, window_start, 1 FROM rate_window
+	ON CONFLICT (limit_key, window_start)
+	DO UPDATE SET request_count = janus_rate_limit_counter.request_count + 1
+	WHERE janus_rate_limit_counter.request_count < ?
+	RETURNING request_count
+)
+SELECT (SELECT request_count FROM counted) AS request_count,
+	EXTRACT(EPOCH FROM window_start + interval '1 minute' - now()) AS reset_seconds
+FROM rate_window`
+
+const usageRateLimitCounterSQL = `
+SELECT c.request_count,
+	EXTRACT(EPOCH FROM c.window_start + interval '1 minute' - now()) AS reset_seconds
+FROM janus_rate_limit_counter c
+WHERE c.limit_key = ? AND c.window_start = date_trunc('minute', now())`
+
+const pruneRateLimitCounterSQL = `
+DELETE FROM janus_rate_limit_counter WHERE window_start < date_trunc('minute', now()) - interval '1 minute'`
+
+// What a PostgresLimiterBackend does while the database cannot be reached.
+const (
+	// LimiterFallbackLocal enforces the limit per process, so N replicas together allow up to
+	// N times the limit until the database is back.
+	LimiterFallbackLocal = "local"
+	// LimiterFallbackAllow fails open and lets every request through.
+	LimiterFallbackAllow = "allow"
+	// LimiterFallbackDeny fails closed and rejects every request.
+	LimiterFallbackDeny = "deny"
+)
+
+// limiterErrorLogInterval spaces out database error logs, which would otherwise repeat on
+// every request during an outage.
+const limiterErrorLogInterval = time.Minute
+
+// PostgresLimiterBackend counts requests per key in janus_rate_limit_counter so that every
+// replica pointing at the same database enforces one shared limit. Counters use fixed
+// one-minute windows on the database clock; when the database is unreachable, requests are
+// handled according to the fallback mode.
+type PostgresLimiterBackend struct {
+	connect        func() (*gorm.DB, error)
+	window         time.Duration
+	fallbackMode   string
+	fallback       *MemoryLimiterBackend
+	denyRetryAfter time.Duration
+
+	mu            sync.Mutex
+	db            *gorm.DB
+	lastPrune     time.Time
+	lastErrorLog  time.Time
+	skippedErrors int
+}
+
+// NewPostgresLimiterBackend returns a shared limiter backend; fallbackMode is one of the
+// LimiterFallback modes and defaults to LimiterFallbackLocal.
+func NewPostgresLimiterBackend(fallbackMode string) (*PostgresLimiterBackend, error) {
+	switch fallbackMode {
+	case "":
+		fallbackMode = LimiterFallbackLocal
+	case LimiterFallbackLocal, LimiterFallbackAllow, LimiterFallbackDeny:
+	default:
+		return nil, fmt.Errorf("unsupported rate limit fallback %q", fallbackMode)
+	}
+	return &PostgresLimiterBackend{
+		connect:        janusDb.ConnectDatabase,
+		window:         1 * time.Minute,
+		fallbackMode:   fallbackMode,
+		fallback:       NewMemoryLimiterBackend(),
+		denyRetryAfter: time.Second,
+	}, nil
+}
+
+func (b *PostgresLimiterBackend) Limiter(key string, rpm int) RateLimiter {
+	return &postgresLimiter{
+		backend:     b,
+		key:         key,
+		limitKey:    hashLimitKey(key),
+		maxRequests: rpm,
+	}
+}
+
+// Remove drops the local fallback ring; shared counters expire with their window.
+func (b *PostgresLimiterBackend) Remove(key string) {
+	b.fallback.Remove(key)
+}
+
+func (b *PostgresLimiterBackend) database() (*gorm.DB, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.db != nil {
+		return b.db, nil
+	}
+	db, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	b.db = db
+	return db, nil
+}
+
+func (b *PostgresLimiterBackend) maybePrune(db *gorm.DB, now time.Time) {
+	b.mu.Lock()
+	if now.Sub(b.lastPrune) < b.window {
+		b.mu.Unlock()
+		return
+	}
+	b.lastPrune = now
+	b.mu.Unlock()
+
+	go func() {
+		if err := db.Exec(pruneRateLimitCounterSQL).Error; err != nil {
+			log.Printf("PostgresLimiterBackend: prune counters failed: %v", err)
+		}
+	}()
+}
+
+// databaseFailed counts a failed counter query and logs it at most once per
+// limiterErrorLogInterval, with the number of failures not logged since.
+func (b *PostgresLimiterBackend) databaseFailed(action string, err error, now time.Time) {
+	metrics.IncRateLimitBackendError()
+
+	b.mu.Lock()
+	if now.Sub(b.lastErrorLog) < limiterErrorLogInterval {
+		b.skippedErrors++
+		b.mu.Unlock()
+		return
+	}
+	skipped := b.skippedErrors
+	b.lastErrorLog = now
+	b.skippedErrors = 0
+	b.mu.Unlock()
+
+	log.Printf("PostgresLimiterBackend: %s failed, fallback %s (%d more failures since last log): %v",
+		action, b.fallbackMode, skipped, err)
+}
+
+type postgresLimiter struct {
+	backend     *PostgresLimiterBackend
+	key         string
+	limitKey    string
+	maxRequests int
+}
+
+type rateLimitCounterRow struct {
+	RequestCount *int
+	ResetSeconds float64
+}
+
+func (r rateLimitCounterRow) resetAfter() time.Duration {
+	reset := time.Duration(r.ResetSeconds * float64(time.Second))
+	if reset < 0 {
+		return 0
+	}
+	return reset
+}
+
+func (l *postgresLimiter) AllowAt(now time.Time) (bool, time.Duration) {
+	db, err := l.backend.database()
+	if err != nil {
+		l.backend.databaseFailed("connect database", err, now)
+		return l.allowWithoutDatabase(now)
+	}
+
+	var row rateLimitCounterRow
+	if err := db.Raw(allowRateLimitCounterSQL, l.limitKey, l.maxRequests).Scan(&row).Error; err != nil {
+		l.backend.databaseFailed("update counter", err, now)
+		return l.allowWithoutDatabase(now)
+	}
+	l.backend.maybePrune(db, now)
+
+	if row.RequestCount == nil {
+		return false, row.resetAfter()
+	}
+	return true, 0
+}
+
+func (l *postgresLimiter) allowWithoutDatabase(now time.Time) (bool, time.Duration) {
+	switch l.backend.fallbackMode {
+	case LimiterFallbackAllow:
+		return true, 0
+	case LimiterFallbackDeny:
+		return false, l.backend.denyRetryAfter
+	default:
+		return l.backend.fallback.ring(l.key, l.maxRequests).AllowAt(now)
+	}
+}
+
+func (l *postgresLimiter) UsageAt(now time.Time) (int, time.Duration) {
+	db, err := l.backend.database()
+	if err != nil {
+		l.backend.databaseFailed("connect database", err, now)
+		return l.backend.fallback.ring(l.key, l.maxRequests).UsageAt(now)
+	}
+
+	var rows []rateLimitCounterRow
+	if err := db.Raw(usageRateLimitCounterSQL, l.limitKey).Scan(&rows).Error; err != nil {
+		l.backend.databaseFailed("read counter", err, now)
+		return l.backend.fallback.ring(l.key, l.maxRequests).UsageAt(now)
+	}
+	if len(rows) == 0 || rows[0].RequestCount == nil {
+		return 0, 0
+	}
+	return *rows[0].RequestCount, rows[0].resetAfter()
+}
+
+// hashLimitKey keeps raw API keys out of the counter table.
+func hashLimitKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
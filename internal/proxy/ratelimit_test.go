@@ -1,8 +1,11 @@
 package proxy
 
 import (
+	"errors"
 	"testing"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 func TestRequestRingUnlimited(t *testing.T) {
@@ -49,3 +52,111 @@ func TestRequestRingRecoversAfterWindow(t *testing.T) {
 		t.Fatalf("request after window should be allowed, got allowed=%v retry_after=%v", allowed, retryAfter)
 	}
 }
+
+func TestRequestRingUsageDoesNotConsume(t *testing.T) {
+	ring := NewRequestRing(time.Minute, 2)
+	start := time.Unix(1700000000, 0)
+
+	if used, resetAfter := ring.UsageAt(start); used != 0 || resetAfter != 0 {
+		t.Fatalf("expected an empty window, got used=%d reset_after=%v", used, resetAfter)
+	}
+	ring.AllowAt(start)
+	ring.AllowAt(start.Add(10 * time.Second))
+
+	used, resetAfter := ring.UsageAt(start.Add(20 * time.Second))
+	if used != 2 || resetAfter != 40*time.Second {
+		t.Fatalf("expected used=2 reset_after=40s, got used=%d reset_after=%v", used, resetAfter)
+	}
+	if used, _ := ring.UsageAt(start.Add(65 * time.Second)); used != 1 {
+		t.Fatalf("expected the first request to leave the window, got used=%d", used)
+	}
+	if allowed, _ := ring.AllowAt(start.Add(65 * time.Second)); !allowed {
+		t.Fatal("reading usage should not consume the budget")
+	}
+}
+
+func TestMemoryLimiterBackendReusesRingUntilLimitChanges(t *testing.T) {
+	backend := NewMemoryLimiterBackend()
+	first := backend.Limiter("sk-a", 2)
+	if again := backend.Limiter("sk-a", 2); again != first {
+		t.Fatal("expected the same limiter for an unchanged limit")
+	}
+	if changed := backend.Limiter("sk-a", 3); changed == first {
+		t.Fatal("expected a new limiter after the limit changed")
+	}
+	backend.Remove("sk-a")
+	if _, ok := backend.rings["sk-a"]; ok {
+		t.Fatal("expected limiter to be removed")
+	}
+}
+
+func newUnreachablePostgresLimiterBackend(t *testing.T, fallbackMode string) *PostgresLimiterBackend {
+	t.Helper()
+	backend, err := NewPostgresLimiterBackend(fallbackMode)
+	if err != nil {
+		t.Fatalf("new postgres limiter backend: %v", err)
+	}
+	backend.connect = func() (*gorm.DB, error) {
+		return nil, errors.New("database unavailable")
+	}
+	return backend
+}
+
+func TestPostgresLimiterBackendFallsBackToLocalRing(t *testing.T) {
+	backend := newUnreachablePostgresLimiterBackend(t, "")
+	start := time.Unix(1700000000, 0)
+
+	limiter := backend.Limiter("sk-b", 1)
+	if allowed, _ := limiter.AllowAt(start); !allowed {
+		t.Fatal("expected first request to be allowed by the fallback ring")
+	}
+	if allowed, retryAfter := backend.Limiter("sk-b", 1).AllowAt(start.Add(time.Second)); allowed || retryAfter <= 0 {
+		t.Fatalf("expected fallback ring to enforce the limit, got allowed=%v retry_after=%v", allowed, retryAfter)
+	}
+}
+
+func TestPostgresLimiterBackendFallbackModes(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	allow := newUnreachablePostgresLimiterBackend(t, LimiterFallbackAllow)
+	for i := 0; i < 3; i++ {
+		if allowed, _ := allow.Limiter("sk-c", 1).AllowAt(start); !allowed {
+			t.Fatalf("expected fail-open fallback to allow request %d", i+1)
+		}
+	}
+
+	deny := newUnreachablePostgresLimiterBackend(t, LimiterFallbackDeny)
+	if allowed, retryAfter := deny.Limiter("sk-c", 1).AllowAt(start); allowed || retryAfter <= 0 {
+		t.Fatalf("expected fail-closed fallback to reject, got allowed=%v retry_after=%v", allowed, retryAfter)
+	}
+
+	if _, err := NewPostgresLimiterBackend("sometimes"); err == nil {
+		t.Fatal("expected unknown fallback mode to be rejected")
+	}
+}
+
+func TestPostgresLimiterBackendRateLimitsErrorLogs(t *testing.T) {
+	backend := newUnreachablePostgresLimiterBackend(t, "")
+	start := time.Unix(1700000000, 0)
+
+	for i := 0; i < 5; i++ {
+		backend.Limiter("sk-d", 100).AllowAt(start.Add(time.Duration(i) * time.Second))
+	}
+	if backend.skippedErrors != 4 {
+		t.Fatalf("expected 4 failures held back from the log, got %d", backend.skippedErrors)
+	}
+	backend.Limiter("sk-d", 100).AllowAt(start.Add(limiterErrorLogInterval))
+	if backend.skippedErrors != 0 {
+		t.Fatalf("expected skipped failures to be reported with the next log, got %d", backend.skippedErrors)
+	}
+}
+
+func TestHashLimitKeyDoesNotStoreRawKey(t *testing.T) {
+	hashed := hashLimitKey("sk-secret")
+	if len(hashed) != 64 || hashed == "sk-secret" {
+		t.Fatalf("unexpected limit key: %q", hashed)
+	}
+	if hashLimitKey("sk-secret") != hashed {
+		t.Fatal("expected limit key hashing to be stable")
+	}
+}
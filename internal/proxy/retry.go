@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+const (
+	defaultRetryBackoff    = 200 * time.Millisecond
+	defaultRetryMaxBackoff = 2 * time.Second
+	// maxRetryAfterWait caps how long a request waits to retry the same endpoint; a longer
+	// Retry-After moves the request on to the next endpoint instead.
+	maxRetryAfterWait = 10 * time.Second
+	// minAttemptTime is the least time left before a request deadline worth another attempt.
+	minAttemptTime = 10 * time.Millisecond
+)
+
+var defaultRetryOn = []string{"5xx", "408", "429"}
+
+// upstreamStatusError is an upstream response the request moves on from: a retryable status
+// with the delay the upstream asked for, or a rejection a fallback group may accept.
+type upstreamStatusError struct {
+	status     int
+	retryAfter time.Duration
+	body       []byte
+	// fallback is the fallbackContextWindow or fallbackContentPolicy reason, if any.
+	fallback string
+	// header and clientBody are the upstream response as the client would have received it;
+	// they are relayed as-is when retries run out.
+	header     http.Header
+	clientBody []byte
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream status %d: %s", e.status, truncateBody(e.body))
+}
+
+type retryPolicy struct {
+	statuses   map[int]struct{}
+	classes    map[int]struct{}
+	backoff    time.Duration
+	maxBackoff time.Duration
+	timeout    time.Duration
+}
+
+// ValidateRetryPolicy reports retry settings a model group cannot be routed with.
+func ValidateRetryPolicy(policy *models.RetryPolicy) error {
+	_, err := parseRetryPolicy(policy)
+	return err
+}
+
+// newRetryPolicy returns the effective policy for a group. Config is validated when it is
+// loaded, so invalid entries are ignored here.
+func newRetryPolicy(policy *models.RetryPolicy) retryPolicy {
+	parsed, _ := parseRetryPolicy(policy)
+	return parsed
+}
+
+func parseRetryPolicy(policy *models.RetryPolicy) (retryPolicy, error) {
+	parsed := retryPolicy{
+		statuses:   make(map[int]struct{}),
+		classes:    make(map[int]struct{}),
+		backoff:    defaultRetryBackoff,
+		maxBackoff: defaultRetryMaxBackoff,
+	}
+	on := defaultRetryOn
+	var errs []error
+	if policy != nil {
+		if len(policy.On) > 0 {
+			on = policy.On
+		}
+		if policy.BackoffMS > 0 {
+			parsed.backoff = time.Duration(policy.BackoffMS) * time.Millisecond
+		}
+		if policy.MaxBackoffMS > 0 {
+			parsed.maxBackoff = time.Duration(policy.MaxBackoffMS) * time.Millisecond
+		}
+		if policy.BackoffMS < 0 || policy.MaxBackoffMS < 0 || policy.TimeoutSeconds < 0 {
+			errs = append(errs, errors.New("retry backoff and timeout must be non-negative"))
+		}
+		parsed.timeout = time.Duration(policy.TimeoutSeconds) * time.Second
+	}
+	if parsed.maxBackoff < parsed.backoff {
+		parsed.maxBackoff = parsed.backoff
+	}
+
+	for _, entry := range on {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if len(entry) == 3 && strings.HasSuffix(entry, "xx") && entry[0] >= '4' && entry[0] <= '5' {
+			parsed.classes[int(entry[0]-'0')] = struct{}{}
+			continue
+		}
+		code, err := strconv.Atoi(entry)
+		if err != nil || code < 400 || code > 599 {
+			errs = append(errs, fmt.Errorf("invalid retry status %q; use a 4xx/5xx code or class", entry))
+			continue
+		}
+		parsed.statuses[code] = struct{}{}
+	}
+	return parsed, errors.Join(errs...)
+}
+
+func (r retryPolicy) retryable(status int) bool {
+	if _, ok := r.statuses[status]; ok {
+		return true
+	}
+	_, ok := r.classes[status/100]
+	return ok
+}
+
+// wait returns the delay before retrying the same endpoint after attempt failed with err:
+// exponential backoff with full jitter, or the upstream's Retry-After when that is longer.
+func (r retryPolicy) wait(attempt int, err error) time.Duration {
+	ceiling := r.backoff << min(attempt-1, 16)
+	if ceiling <= 0 || ceiling > r.maxBackoff {
+		ceiling = r.maxBackoff
+	}
+	delay := time.Duration(rand.Int64N(int64(ceiling) + 1))
+
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) && statusErr.retryAfter > delay {
+		return statusErr.retryAfter
+	}
+	return delay
+}
+
+// canWait reports whether waiting d still leaves the request within its deadline.
+func canWait(ctx context.Context, d time.Duration) bool {
+	if d > maxRetryAfterWait {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > d
+}
+
+// sleepContext waits for d and reports false if ctx ended first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// deadlineReached reports whether ctx hit its deadline or has too little time left for
+// another attempt. Attempt timeouts are cut to the deadline, so the last attempt usually
+// fails just before ctx itself expires.
+func deadlineReached(ctx context.Context) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < minAttemptTime
+}
+
+// attemptTimeout shortens an endpoint timeout to what is left of the request deadline.
+func attemptTimeout(ctx context.Context, timeout time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining < minAttemptTime {
+			return minAttemptTime
+		}
+		if remaining < timeout {
+			return remaining
+		}
+	}
+	return timeout
+}
@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+func TestHandleRequestRetriesRateLimitOnSameUpstream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"1","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:   "limited",
+		Models: []models.ModelConfig{{Name: "upstream", Type: "openai", BaseURL: upstream.URL, RetryTimes: 1}},
+		Retry:  &models.RetryPolicy{BackoffMS: 1, MaxBackoffMS: 5},
+	})
+
+	rec, _ := serveBody(p, "limited", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the retry to succeed, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", got)
+	}
+}
+
+func TestHandleRequestReturnsRateLimitWhenRetriesExhausted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const providerError = `{"error":{"message":"Rate limit reached for requests","type":"requests","code":"rate_limit_exceeded"}}`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.Header().Set("X-Ratelimit-Remaining-Requests", "0")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, providerError)
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:   "limited",
+		Models: []models.ModelConfig{{Name: "upstream", Type: "openai", BaseURL: upstream.URL, RetryTimes: 1}},
+	})
+
+	rec, _ := serveBody(p, "limited", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 to be passed through, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("expected upstream Retry-After to be forwarded, got %q", got)
+	}
+	if got := rec.Header().Get("X-Ratelimit-Remaining-Requests"); got != "0" {
+		t.Fatalf("expected upstream rate limit headers to be forwarded, got %q", got)
+	}
+	if rec.Body.String() != providerError || rec.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("expected the provider's error to be relayed verbatim, got %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+func TestHandleRequestTranslatesRateLimitWhenRetriesExhausted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Anthropic-Ratelimit-Requests-Remaining", "0")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`)
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:   "limited",
+		Models: []models.ModelConfig{{Name: "upstream", Type: "anthropic", BaseURL: upstream.URL}},
+	})
+
+	rec, _ := serveBody(p, "limited", []byte(`{"model":"limited","messages":[{"role":"user","content":"hi"}]}`))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Anthropic-Ratelimit-Requests-Remaining") != "0" {
+		t.Fatalf("expected the provider's 429 and headers, got %d %v", rec.Code, rec.Header())
+	}
+	var body openAIErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Type != "rate_limit_error" {
+		t.Fatalf("expected an OpenAI-shaped rate limit error, got %q", rec.Body.String())
+	}
+}
+
+func TestHandleRequestEnforcesRequestDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		http.Error(w, "too slow", http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:   "slow",
+		Models: []models.ModelConfig{{Name: "upstream", Type: "openai", BaseURL: upstream.URL, TimeoutSeconds: 30, RetryTimes: 3}},
+		Retry:  &models.RetryPolicy{TimeoutSeconds: 1},
+	})
+
+	start := time.Now()
+	rec, _ := serveBody(p, "slow", nil)
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504 after the request deadline, got %d %q", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 1800*time.Millisecond {
+		t.Fatalf("expected the deadline to cut the attempt short, took %v", elapsed)
+	}
+}
+
+func TestHandleRequestEnforcesRequestDeadlineOnStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:   "stalled",
+		Models: []models.ModelConfig{{Name: "upstream", Type: "openai", BaseURL: upstream.URL, TimeoutSeconds: 30}},
+		Retry:  &models.RetryPolicy{TimeoutSeconds: 1},
+	})
+
+	start := time.Now()
+	body := []byte(`{"model":"stalled","stream":true,"messages":[]}`)
+	serveBody(p, "stalled", body, withStream())
+	if elapsed := time.Since(start); elapsed > 1800*time.Millisecond {
+		t.Fatalf("expected the request deadline to end the stream, took %v", elapsed)
+	}
+}
+
+func TestHandleRequestCancelsUpstreamWhenClientDisconnects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	canceled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices a closed connection once the body has been read.
+		_, _ = io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			close(canceled)
+		case <-time.After(3 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:   "hangup",
+		Models: []models.ModelConfig{{Name: "upstream", Type: "openai", BaseURL: upstream.URL, TimeoutSeconds: 30}},
+	})
+
+	clientCtx, hangUp := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, hangUp)
+	start := time.Now()
+	serveBody(p, "hangup", nil, withRequestContext(clientCtx))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the hang-up to end the attempt, took %v", elapsed)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the client's hang-up to cancel the upstream request")
+	}
+}
+
+func TestParseRetryPolicy(t *testing.T) {
+	policy, err := parseRetryPolicy(nil)
+	if err != nil {
+		t.Fatalf("unexpected error for default policy: %v", err)
+	}
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusRequestTimeout, http.StatusBadGateway} {
+		if !policy.retryable(status) {
+			t.Fatalf("expected %d to be retryable by default", status)
+		}
+	}
+	if policy.retryable(http.StatusBadRequest) || policy.retryable(http.StatusUnauthorized) {
+		t.Fatalf("expected other 4xx statuses not to be retried by default")
+	}
+
+	policy, err = parseRetryPolicy(&models.RetryPolicy{On: []string{"503"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !policy.retryable(http.StatusServiceUnavailable) || policy.retryable(http.StatusTooManyRequests) {
+		t.Fatalf("expected only 503 to be retryable")
+	}
+
+	if err := ValidateRetryPolicy(&models.RetryPolicy{On: []string{"oops", "200"}}); err == nil {
+		t.Fatalf("expected invalid statuses to be rejected")
+	}
+	if err := ValidateRetryPolicy(&models.RetryPolicy{BackoffMS: -1}); err == nil {
+		t.Fatalf("expected negative backoff to be rejected")
+	}
+}
+
+func TestRetryWaitHonorsRetryAfter(t *testing.T) {
+	policy := newRetryPolicy(&models.RetryPolicy{BackoffMS: 10, MaxBackoffMS: 40})
+	for attempt := 1; attempt <= 5; attempt++ {
+		if wait := policy.wait(attempt, nil); wait < 0 || wait > 40*time.Millisecond {
+			t.Fatalf("attempt %d: expected jittered backoff within the cap, got %v", attempt, wait)
+		}
+	}
+	err := &upstreamStatusError{status: http.StatusTooManyRequests, retryAfter: 3 * time.Second}
+	if wait := policy.wait(1, err); wait != 3*time.Second {
+		t.Fatalf("expected Retry-After to take precedence, got %v", wait)
+	}
+}
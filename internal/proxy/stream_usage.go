@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Uuq114/JanusLLM/internal/request"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+const openAICompletionsPath = "/v1/completions"
+
+// tracksStreamUsage reports streams relayed unchanged from an OpenAI-compatible chat or text
+// completions endpoint, which only report usage when the request sets
+// stream_options.include_usage.
+func tracksStreamUsage(adapter ProviderAdapter, endpointPath string) bool {
+	if _, ok := adapter.(*OpenAIAdapter); !ok {
+		return false
+	}
+	return isChatCompletionsPath(endpointPath) || strings.TrimRight(endpointPath, "/") == openAICompletionsPath
+}
+
+// requestStreamUsage sets stream_options.include_usage on a streaming request body. It
+// reports whether the option was added, in which case the client did not ask for the extra
+// usage chunk and should not receive it.
+func requestStreamUsage(body []byte) ([]byte, bool, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, false, fmt.Errorf("invalid request body: %w", err)
+	}
+	if stream, _ := fields["stream"].(bool); !stream {
+		return body, false, nil
+	}
+	options, _ := fields["stream_options"].(map[string]interface{})
+	if options == nil {
+		options = map[string]interface{}{}
+	}
+	if include, _ := options["include_usage"].(bool); include {
+		return body, false, nil
+	}
+	options["include_usage"] = true
+	fields["stream_options"] = options
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// streamUsageTracker relays an OpenAI-compatible stream unchanged apart from the usage chunk
+// Janus requested on the client's behalf, and counts the streamed text so the request can
+// still be billed when the upstream reports no usage.
+type streamUsageTracker struct {
+	stripUsageChunk  bool
+	requestID        string
+	completionTokens int
+}
+
+type streamUsageChunk struct {
+	ID      string `json:"id"`
+	Choices []struct {
+		Text  string `json:"text"`
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			ToolCalls        []struct {
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage json.RawMessage `json:"usage"`
+}
+
+func (t *streamUsageTracker) TranslateStreamLine(line []byte) []byte {
+	data, ok := sseData(line)
+	if !ok {
+		return line
+	}
+	var chunk streamUsageChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return line
+	}
+	if t.requestID == "" {
+		t.requestID = chunk.ID
+	}
+	for _, choice := range chunk.Choices {
+		t.completionTokens += request.EstimateTextTokens(choice.Text)
+		t.completionTokens += request.EstimateTextTokens(choice.Delta.Content)
+		t.completionTokens += request.EstimateTextTokens(choice.Delta.ReasoningContent)
+		for _, call := range choice.Delta.ToolCalls {
+			t.completionTokens += request.EstimateTextTokens(call.Function.Name + call.Function.Arguments)
+		}
+	}
+	hasUsage := len(chunk.Usage) > 0 && string(chunk.Usage) != "null"
+	if t.stripUsageChunk && hasUsage && len(chunk.Choices) == 0 {
+		return nil
+	}
+	return line
+}
+
+func (t *streamUsageTracker) Finish() []byte {
+	return nil
+}
+
+// estimatedUsage builds a spend payload from the estimated prompt and the counted completion.
+func (t *streamUsageTracker) estimatedUsage(promptTokens int) ([]byte, error) {
+	return json.Marshal(spend.UpstreamResp{
+		Id: t.requestID,
+		Usage: spend.TokenUsage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: t.completionTokens,
+			TotalTokens:      promptTokens + t.completionTokens,
+		},
+	})
+}
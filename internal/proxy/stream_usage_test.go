@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/balancer"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/request"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+func serveStream(t *testing.T, endpoint models.ModelConfig, body []byte, upstream http.HandlerFunc) (*httptest.ResponseRecorder, *gin.Context) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+	endpoint.BaseURL = server.URL
+
+	groupName := "stream-usage-group"
+	p := &Proxy{
+		balancers: map[string]balancer.Balancer{
+			groupName: &sequenceBalancer{models: []*models.ModelConfig{&endpoint}},
+		},
+		groups: map[string]models.ModelGroup{groupName: {Name: groupName}},
+	}
+
+	rec, ctx := serveBody(p, groupName, body,
+		withStream(),
+		withHeader("Accept", "text/event-stream"),
+		withHeader("Content-Type", "application/json"),
+	)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected stream response to succeed, got status %d body %q", rec.Code, rec.Body.String())
+	}
+	return rec, ctx
+}
+
+func recordedUsage(t *testing.T, ctx *gin.Context) spend.UpstreamResp {
+	t.Helper()
+	value, exists := ctx.Get(spend.ContextUpstreamResp)
+	if !exists {
+		t.Fatal("expected upstreamResp to be recorded")
+	}
+	var resp spend.UpstreamResp
+	if err := json.Unmarshal(value.([]byte), &resp); err != nil {
+		t.Fatalf("failed to decode upstreamResp: %v", err)
+	}
+	return resp
+}
+
+func TestHandleRequestInjectsStreamUsageAndHidesUsageChunk(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	body := []byte(`{"model":"stream-usage-group","stream":true,"stream_options":{"continuous_usage_stats":false},"messages":[{"role":"user","content":"hi"}]}`)
+	rec, ctx := serveStream(t, models.ModelConfig{Name: "gpt"}, body, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello\"}}],\"usage\":null}\n\n")
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-1\",\"choices\":[],\"usage\":{\"prompt_tokens\":8,\"completion_tokens\":2,\"total_tokens\":10}}\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	})
+
+	options, _ := upstreamBody["stream_options"].(map[string]interface{})
+	if options["include_usage"] != true || options["continuous_usage_stats"] != false {
+		t.Fatalf("expected include_usage added beside the client's stream options, got %v", upstreamBody["stream_options"])
+	}
+	if got := rec.Body.String(); !strings.Contains(got, "hello") || strings.Contains(got, "prompt_tokens") || !strings.Contains(got, "[DONE]") {
+		t.Fatalf("expected the usage-only chunk to be hidden from the client, got %q", got)
+	}
+	if usage := recordedUsage(t, ctx).Usage; usage.PromptTokens != 8 || usage.CompletionTokens != 2 {
+		t.Fatalf("expected upstream usage to be billed, got %+v", usage)
+	}
+}
+
+func TestHandleRequestEstimatesStreamUsageWhenUpstreamOmitsIt(t *testing.T) {
+	body := []byte(`{"model":"stream-usage-group","stream":true,"messages":[{"role":"user","content":"count these words"}]}`)
+	rec, ctx := serveStream(t, models.ModelConfig{Name: "local", DisableStreamUsage: true}, body, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if bytes.Contains(raw, []byte("stream_options")) {
+			t.Errorf("expected no stream_options for an endpoint with stream usage disabled, got %s", raw)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{\"reasoning_content\":\"thinking it over\"}}]}\n\n")
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"three words here\"}}]}\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	})
+
+	if !strings.Contains(rec.Body.String(), "three words here") {
+		t.Fatalf("expected the stream to be relayed, got %q", rec.Body.String())
+	}
+	resp := recordedUsage(t, ctx)
+	wantCompletion := request.EstimateTextTokens("thinking it over") + request.EstimateTextTokens("three words here")
+	wantPrompt := request.EstimatePromptTokens(body)
+	if resp.Id != "chatcmpl-2" || resp.Usage.PromptTokens != wantPrompt || resp.Usage.CompletionTokens != wantCompletion || resp.Usage.TotalTokens != wantPrompt+wantCompletion {
+		t.Fatalf("expected locally counted usage %d+%d, got %+v", wantPrompt, wantCompletion, resp)
+	}
+}
+
+func TestRequestStreamUsage(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body     string
+		injected bool
+	}{
+		"streaming":           {body: `{"stream":true}`, injected: true},
+		"include_usage false": {body: `{"stream":true,"stream_options":{"include_usage":false}}`, injected: true},
+		"client asked":        {body: `{"stream":true,"stream_options":{"include_usage":true}}`},
+		"not streaming":       {body: `{"stream":false}`},
+	} {
+		out, injected, err := requestStreamUsage([]byte(tc.body))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if injected != tc.injected {
+			t.Errorf("%s: expected injected=%v, got %v", name, tc.injected, injected)
+		}
+		if !injected && string(out) != tc.body {
+			t.Errorf("%s: expected the body unchanged, got %s", name, out)
+		}
+		if injected && !bytes.Contains(out, []byte(`"include_usage":true`)) {
+			t.Errorf("%s: expected include_usage in %s", name, out)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/metrics"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/request"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+// contextPromptTokens caches the prompt token estimate on the request context.
+const contextPromptTokens = "promptTokens"
+
+// PromptTokenEstimate returns the request's estimated prompt tokens. It is computed once from
+// the raw body and shared by context-window routing, TPM reservations and spend pre-checks.
+func PromptTokenEstimate(c *gin.Context) int {
+	if value, ok := c.Get(contextPromptTokens); ok {
+		if tokens, ok := value.(int); ok {
+			return tokens
+		}
+	}
+	rawBody, _ := c.Get("rawBody")
+	body, _ := rawBody.([]byte)
+	tokens := request.EstimatePromptTokens(body)
+	c.Set(contextPromptTokens, tokens)
+	return tokens
+}
+
+// defaultHoldCompletionTokens sizes balance holds for requests that set no output limit.
+const defaultHoldCompletionTokens = 1024
+
+// completionTokenLimit returns the completion the request may produce in group, taking the
+// group's max_tokens default when the caller sets none; 0 means unbounded.
+func completionTokenLimit(c *gin.Context, group models.ModelGroup) int {
+	rawBody, _ := c.Get("rawBody")
+	body, _ := rawBody.([]byte)
+	if limit := request.RequestedCompletionTokens(body); limit > 0 {
+		return limit
+	}
+	return request.CompletionLimit(group.RequestDefaults)
+}
+
+// requiredContextTokens returns the estimated prompt plus the completion the request may
+// produce in group.
+func requiredContextTokens(c *gin.Context, group models.ModelGroup) int {
+	return PromptTokenEstimate(c) + completionTokenLimit(c, group)
+}
+
+// contextWindowError reports a request larger than every endpoint's context window.
+type contextWindowError struct {
+	group   string
+	needed  int
+	largest int
+}
+
+func (e *contextWindowError) Error() string {
+	return fmt.Sprintf("request needs about %d tokens but the largest context window in model group %s is %d tokens", e.needed, e.group, e.largest)
+}
+
+// fitContextWindow drops candidates whose context window is known to be too small for
+// needed tokens. Endpoints without a context_window are assumed to fit.
+func fitContextWindow(group string, candidates []*models.ModelConfig, needed int) ([]*models.ModelConfig, error) {
+	fitting := make([]*models.ModelConfig, 0, len(candidates))
+	largest := 0
+	for _, candidate := range candidates {
+		if candidate.ContextWindow <= 0 || needed <= candidate.ContextWindow {
+			fitting = append(fitting, candidate)
+			continue
+		}
+		largest = max(largest, candidate.ContextWindow)
+	}
+	if len(fitting) == 0 {
+		return nil, &contextWindowError{group: group, needed: needed, largest: largest}
+	}
+	return fitting, nil
+}
+
+// checkWeeklySpendHeadroom rejects a request whose estimated prompt cost alone would take
+// the key past its weekly spend limit. Admission only checks spend already recorded.
+func checkWeeklySpendHeadroom(c *gin.Context, group models.ModelGroup) bool {
+	value, ok := c.Get("key")
+	if !ok {
+		return true
+	}
+	key, ok := value.(auth.Key)
+	if !ok || key.SpendLimitPerWeek <= 0 {
+		return true
+	}
+	minInput, _, _ := groupTokenRates(group)
+	if minInput <= 0 {
+		return true
+	}
+	now := time.Now()
+	weekly, _ := spend.WeeklySpend.Spend(key.KeyId, now)
+	estimated := float64(PromptTokenEstimate(c)) * minInput
+	if weekly+estimated <= key.SpendLimitPerWeek {
+		return true
+	}
+
+	metrics.IncRateLimitRejection("weekly_spend")
+	resetAt := spend.NextWeekStart(now)
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(resetAt)/time.Second)+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"code":     "weekly_spend_limit_exceeded",
+		"error":    "estimated request cost exceeds the remaining weekly spend limit",
+		"reset_at": resetAt.UTC().Format(time.RFC3339),
+	})
+	return false
+}
+
+// groupTokenRates returns the lowest plain input rate and the highest input and output rates
+// any endpoint of group can bill, from endpoint pricing or the group's own prices.
+func groupTokenRates(group models.ModelGroup) (minInput float64, maxInput float64, maxOutput float64) {
+	minInput = -1
+	include := func(plainInput, input, output float64) {
+		if minInput < 0 || plainInput < minInput {
+			minInput = plainInput
+		}
+		if input > maxInput {
+			maxInput = input
+		}
+		if output > maxOutput {
+			maxOutput = output
+		}
+	}
+	for _, endpoint := range group.Models {
+		if endpoint.Pricing == nil {
+			include(group.CostPerInputToken, group.CostPerInputToken, group.CostPerOutputToken)
+			continue
+		}
+		input, output := endpoint.Pricing.MaxRates()
+		include(endpoint.Pricing.InputPerToken, input, output)
+	}
+	if minInput < 0 {
+		return group.CostPerInputToken, group.CostPerInputToken, group.CostPerOutputToken
+	}
+	return minInput, maxInput, maxOutput
+}
+
+// holdBalance sets aside the most the request can cost in any group of chain, the requested
+// group and the fallbacks that may serve it, from the key's available balance, so concurrent
+// requests cannot spend more than the key has. The hold is settled to the actual spend once
+// it is recorded.
+func holdBalance(c *gin.Context, chain []models.ModelGroup) bool {
+	value, ok := c.Get("key")
+	if !ok {
+		return true
+	}
+	key, ok := value.(auth.Key)
+	if !ok {
+		return true
+	}
+	maxCost := 0.0
+	for _, group := range chain {
+		if cost := maxRequestCost(c, group); cost > maxCost {
+			maxCost = cost
+		}
+	}
+	if maxCost <= 0 {
+		return true
+	}
+
+	hold, ok := spend.HoldBalance(key.KeyId, key.Balance, maxCost)
+	if !ok {
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"code":  "insufficient_balance",
+			"error": "authorization key balance cannot cover the estimated request cost",
+		})
+		return false
+	}
+	c.Set(spend.ContextBalanceHold, hold)
+	return true
+}
+
+// maxRequestCost returns the most the request can cost when served by group.
+func maxRequestCost(c *gin.Context, group models.ModelGroup) float64 {
+	_, maxInput, maxOutput := groupTokenRates(group)
+	if maxInput <= 0 && maxOutput <= 0 {
+		return 0
+	}
+	completion := completionTokenLimit(c, group)
+	if completion <= 0 {
+		completion = defaultHoldCompletionTokens
+	}
+	return float64(PromptTokenEstimate(c))*maxInput + float64(completion)*maxOutput
+}
@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+func longPrompt(group string, tokens int) []byte {
+	return []byte(`{"model":"` + group + `","messages":[{"role":"user","content":"` + strings.Repeat("abcd", tokens) + `"}]}`)
+}
+
+func TestHandleRequestSkipsEndpointsWithSmallContextWindow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var smallCalls, largeCalls atomic.Int32
+	small := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		smallCalls.Add(1)
+		http.Error(w, "too long", http.StatusBadRequest)
+	}))
+	defer small.Close()
+	large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		largeCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"1","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer large.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name: "windowed",
+		Models: []models.ModelConfig{
+			{Name: "small", Type: "openai", BaseURL: small.URL, ContextWindow: 1000},
+			{Name: "large", Type: "openai", BaseURL: large.URL, ContextWindow: 8000},
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		rec, _ := serveBody(p, "windowed", longPrompt("windowed", 2000), withKey(auth.Key{ModelList: auth.StringSlice{"*"}}))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected the large endpoint to serve, got %d %q", i, rec.Code, rec.Body.String())
+		}
+	}
+	if smallCalls.Load() != 0 || largeCalls.Load() != 3 {
+		t.Fatalf("expected only the large endpoint to be used, got small=%d large=%d", smallCalls.Load(), largeCalls.Load())
+	}
+}
+
+func TestHandleRequestFailsFastWhenNoContextWindowFits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:            "small",
+		Models:          []models.ModelConfig{{Name: "small", Type: "openai", BaseURL: upstream.URL, ContextWindow: 4096}},
+		RequestDefaults: map[string]interface{}{"max_tokens": 4000},
+	})
+
+	// The prompt fits on its own, but not with the group's default max_tokens.
+	rec, _ := serveBody(p, "small", longPrompt("small", 500), withKey(auth.Key{ModelList: auth.StringSlice{"*"}}))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "context_length_exceeded") {
+		t.Fatalf("expected a context window 400, got %d %q", rec.Code, rec.Body.String())
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("expected no upstream call")
+	}
+}
+
+func TestHandleRequestRejectsPromptBeyondWeeklySpendHeadroom(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:              "priced",
+		Models:            []models.ModelConfig{{Name: "m", Type: "openai", BaseURL: "http://127.0.0.1:1"}},
+		CostPerInputToken: 0.001,
+	})
+	key := auth.Key{KeyId: 7301, ModelList: auth.StringSlice{"*"}, SpendLimitPerWeek: 1}
+	spend.WeeklySpend.Seed(key.KeyId, 0.5, time.Now())
+
+	// About 1000 prompt tokens cost 1.0, more than the 0.5 left this week.
+	rec, _ := serveBody(p, "priced", longPrompt("priced", 1000), withKey(key))
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "weekly_spend_limit_exceeded") {
+		t.Fatalf("expected weekly spend pre-check to reject, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After on weekly spend rejection")
+	}
+}
+
+func TestHandleRequestRejectsWhenBalanceHoldFails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"1","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:               "held",
+		Models:             []models.ModelConfig{{Name: "m", Type: "openai", BaseURL: upstream.URL}},
+		CostPerOutputToken: 0.001,
+	})
+	// Each request holds 1024 default completion tokens, 1.024 of the 1.5 balance.
+	key := auth.Key{KeyId: 7302, ModelList: auth.StringSlice{"*"}, Balance: 1.5}
+	spend.Balances.Sync(key.KeyId, key.Balance)
+	defer spend.Balances.Remove(key.KeyId)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec, _ := serveBody(p, "held", nil, withKey(key))
+		done <- rec
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if available, _ := spend.Balances.Available(key.KeyId); available < 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the first request to hold balance")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec, _ := serveBody(p, "held", nil, withKey(key))
+	if rec.Code != http.StatusPaymentRequired || !strings.Contains(rec.Body.String(), "insufficient_balance") {
+		t.Fatalf("expected the concurrent request to be rejected, got %d %q", rec.Code, rec.Body.String())
+	}
+	close(release)
+	if first := <-done; first.Code != http.StatusOK {
+		t.Fatalf("expected the first request to succeed, got %d %q", first.Code, first.Body.String())
+	}
+}
+
+func TestHandleRequestHoldsBalanceForCostliestFallbackGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"1","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.ReplaceModelGroups([]models.ModelGroup{
+		{
+			Name:               "cheap",
+			Models:             []models.ModelConfig{{Name: "m", Type: "openai", BaseURL: upstream.URL}},
+			CostPerOutputToken: 0.0001,
+			Fallbacks:          []string{"premium"},
+		},
+		{
+			Name:               "premium",
+			Models:             []models.ModelConfig{{Name: "m", Type: "openai", BaseURL: upstream.URL}},
+			CostPerOutputToken: 0.01,
+		},
+	})
+	// 1024 default completion tokens cost 0.1024 in cheap but 10.24 in premium.
+	key := auth.Key{KeyId: 7303, Balance: 1}
+	spend.Balances.Sync(key.KeyId, key.Balance)
+	defer spend.Balances.Remove(key.KeyId)
+
+	key.ModelList = auth.StringSlice{"cheap"}
+	if rec, _ := serveBody(p, "cheap", nil, withKey(key)); rec.Code != http.StatusOK {
+		t.Fatalf("expected a key without the fallback to be held at the cheap price, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	key.ModelList = auth.StringSlice{"*"}
+	rec, _ := serveBody(p, "cheap", nil, withKey(key))
+	if rec.Code != http.StatusPaymentRequired || !strings.Contains(rec.Body.String(), "insufficient_balance") {
+		t.Fatalf("expected the hold to cover the premium fallback, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected only the first request to reach the upstream, got %d", got)
+	}
+}
+
+func TestGroupTokenRatesUseEndpointPricing(t *testing.T) {
+	reasoning := 0.00004
+	group := models.ModelGroup{
+		CostPerInputToken:  0.000003,
+		CostPerOutputToken: 0.000015,
+		Models: []models.ModelConfig{
+			{Name: "provider"},
+			{Name: "self-hosted", Pricing: &models.Pricing{
+				PriceRates: models.PriceRates{InputPerToken: 0.0000001, OutputPerToken: 0.0000002, ReasoningPerToken: &reasoning},
+				Tiers:      []models.PricingTier{{AbovePromptTokens: 1000, PriceRates: models.PriceRates{InputPerToken: 0.00001}}},
+			}},
+		},
+	}
+
+	minInput, maxInput, maxOutput := groupTokenRates(group)
+	if minInput != 0.0000001 || maxInput != 0.00001 || maxOutput != reasoning {
+		t.Fatalf("unexpected rates: min input %v, max input %v, max output %v", minInput, maxInput, maxOutput)
+	}
+
+	group.Models = group.Models[:1]
+	if minInput, maxInput, maxOutput = groupTokenRates(group); minInput != 0.000003 || maxInput != 0.000003 || maxOutput != 0.000015 {
+		t.Fatalf("expected group prices without endpoint pricing, got %v %v %v", minInput, maxInput, maxOutput)
+	}
+}
@@ -0,0 +1,261 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/metrics"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+const (
+	headerRateLimitRemainingTokens = "X-Ratelimit-Remaining-Tokens"
+	headerRateLimitLimitTokens     = "X-Ratelimit-Limit-Tokens"
+)
+
+var (
+	tokenWindowsMu sync.RWMutex
+	tokenWindows   = make(map[string]*TokenWindow)
+)
+
+// TokenWindow limits the number of tokens consumed within a sliding window.
+type TokenWindow struct {
+	window    time.Duration
+	maxTokens int
+	entries   []*tokenEntry
+	used      int
+	mu        sync.Mutex
+}
+
+type tokenEntry struct {
+	at      time.Time
+	tokens  int
+	expired bool
+}
+
+func NewTokenWindow(window time.Duration, maxTokens int) *TokenWindow {
+	return &TokenWindow{
+		window:    window,
+		maxTokens: maxTokens,
+	}
+}
+
+// ReserveAt records tokens against the window when they fit and returns the reservation,
+// the remaining budget, and how long to wait when the reservation was rejected.
+// A request larger than the whole budget is admitted only when the window is empty.
+func (w *TokenWindow) ReserveAt(now time.Time, tokens int) (*tokenEntry, int, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pruneLocked(now)
+	if w.used > 0 && w.used+tokens > w.maxTokens {
+		return nil, w.remainingLocked(), w.retryAfterLocked(now, tokens)
+	}
+
+	entry := &tokenEntry{at: now, tokens: tokens}
+	w.entries = append(w.entries, entry)
+	w.used += tokens
+	return entry, w.remainingLocked(), 0
+}
+
+// Reconcile replaces the reserved token count with the actual one.
+func (w *TokenWindow) Reconcile(entry *tokenEntry, tokens int) {
+	if entry == nil {
+		return
+	}
+	if tokens < 0 {
+		tokens = 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if entry.expired {
+		return
+	}
+	w.used += tokens - entry.tokens
+	entry.tokens = tokens
+}
+
+func (w *TokenWindow) remainingLocked() int {
+	if w.used >= w.maxTokens {
+		return 0
+	}
+	return w.maxTokens - w.used
+}
+
+func (w *TokenWindow) retryAfterLocked(now time.Time, tokens int) time.Duration {
+	need := w.used + tokens - w.maxTokens
+	freed := 0
+	for _, entry := range w.entries {
+		freed += entry.tokens
+		if freed >= need || freed >= w.used {
+			retryAfter := entry.at.Add(w.window).Sub(now)
+			if retryAfter < 0 {
+				return 0
+			}
+			return retryAfter
+		}
+	}
+	return w.window
+}
+
+func (w *TokenWindow) pruneLocked(now time.Time) {
+	cutoff := now.Add(-w.window)
+	firstValid := 0
+	for firstValid < len(w.entries) {
+		entry := w.entries[firstValid]
+		if entry.at.After(cutoff) {
+			break
+		}
+		entry.expired = true
+		w.used -= entry.tokens
+		firstValid++
+	}
+	if firstValid == 0 {
+		return
+	}
+	w.entries = append(w.entries[:0], w.entries[firstValid:]...)
+}
+
+func GetOrCreateTokenWindow(scope string, tpm int) *TokenWindow {
+	if tpm <= 0 {
+		return nil
+	}
+
+	tokenWindowsMu.RLock()
+	window, ok := tokenWindows[scope]
+	tokenWindowsMu.RUnlock()
+	if ok && window.maxTokens == tpm {
+		return window
+	}
+
+	tokenWindowsMu.Lock()
+	defer tokenWindowsMu.Unlock()
+	if existing, exists := tokenWindows[scope]; exists && existing.maxTokens == tpm {
+		return existing
+	}
+	created := NewTokenWindow(1*time.Minute, tpm)
+	tokenWindows[scope] = created
+	return created
+}
+
+type tokenLimit struct {
+	scope string
+	tpm   int
+}
+
+// tokenLimits lists the key, team and model group TPM limits that apply to the request.
+func tokenLimits(c *gin.Context, group models.ModelGroup) []tokenLimit {
+	var limits []tokenLimit
+	if keyValue, ok := c.Get("key"); ok {
+		if keyInfo, ok := keyValue.(auth.Key); ok {
+			if keyInfo.TokensPerMinute > 0 {
+				limits = append(limits, tokenLimit{scope: fmt.Sprintf("key:%d", keyInfo.KeyId), tpm: keyInfo.TokensPerMinute})
+			}
+			if keyInfo.TeamTokensPerMinute > 0 {
+				limits = append(limits, tokenLimit{scope: fmt.Sprintf("team:%d", keyInfo.TeamId), tpm: keyInfo.TeamTokensPerMinute})
+			}
+		}
+	}
+	if group.TokensPerMinute > 0 {
+		limits = append(limits, tokenLimit{scope: "group:" + group.Name, tpm: group.TokensPerMinute})
+	}
+	return limits
+}
+
+type tokenReservation struct {
+	windows  []*TokenWindow
+	entries  []*tokenEntry
+	estimate int
+}
+
+// reserveTokens reserves the estimated prompt tokens in every applicable window. When a
+// window is full, reservations already taken are released and a 429 is written.
+func reserveTokens(c *gin.Context, group models.ModelGroup) (*tokenReservation, bool) {
+	limits := tokenLimits(c, group)
+	if len(limits) == 0 {
+		return nil, true
+	}
+
+	now := time.Now()
+	reservation := &tokenReservation{estimate: PromptTokenEstimate(c)}
+	remaining, limit := -1, 0
+	for _, l := range limits {
+		window := GetOrCreateTokenWindow(l.scope, l.tpm)
+		entry, left, retryAfter := window.ReserveAt(now, reservation.estimate)
+		if entry == nil {
+			reservation.settle(0)
+			metrics.IncRateLimitRejection("tpm")
+			c.Header(headerRateLimitLimitTokens, strconv.Itoa(l.tpm))
+			c.Header(headerRateLimitRemainingTokens, strconv.Itoa(left))
+			if retryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+			}
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code":  "token_rate_limit_exceeded",
+				"error": "reach token rate limit",
+			})
+			return nil, false
+		}
+		reservation.windows = append(reservation.windows, window)
+		reservation.entries = append(reservation.entries, entry)
+		if remaining < 0 || left < remaining {
+			remaining, limit = left, l.tpm
+		}
+	}
+
+	c.Header(headerRateLimitLimitTokens, strconv.Itoa(limit))
+	c.Header(headerRateLimitRemainingTokens, strconv.Itoa(remaining))
+	return reservation, true
+}
+
+func (r *tokenReservation) settle(tokens int) {
+	if r == nil {
+		return
+	}
+	for i, window := range r.windows {
+		window.Reconcile(r.entries[i], tokens)
+	}
+}
+
+// settleFromContext reconciles the reservation with the usage reported by the upstream.
+// Successful responses without usage keep the estimate; failed ones release it.
+func (r *tokenReservation) settleFromContext(c *gin.Context) {
+	if r == nil {
+		return
+	}
+	if tokens, ok := usedTokensFromContext(c); ok {
+		r.settle(tokens)
+		return
+	}
+	if c.Writer.Status() >= http.StatusBadRequest {
+		r.settle(0)
+	}
+}
+
+func usedTokensFromContext(c *gin.Context) (int, bool) {
+	value, ok := c.Get(spend.ContextUpstreamResp)
+	if !ok {
+		return 0, false
+	}
+	payload, ok := value.([]byte)
+	if !ok {
+		return 0, false
+	}
+	var upstreamResp spend.UpstreamResp
+	if err := json.Unmarshal(payload, &upstreamResp); err != nil {
+		return 0, false
+	}
+	usage := upstreamResp.Usage
+	if usage.TotalTokens > 0 {
+		return usage.TotalTokens, true
+	}
+	return usage.PromptTokens + usage.CompletionTokens, true
+}
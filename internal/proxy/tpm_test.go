@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/balancer"
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+func TestTokenWindowRejectsAndReportsRetryAfter(t *testing.T) {
+	window := NewTokenWindow(time.Minute, 100)
+	start := time.Unix(1700000000, 0)
+
+	entry, remaining, _ := window.ReserveAt(start, 60)
+	if entry == nil || remaining != 40 {
+		t.Fatalf("expected first reservation to fit, got entry=%v remaining=%d", entry, remaining)
+	}
+
+	rejected, remaining, retryAfter := window.ReserveAt(start.Add(20*time.Second), 50)
+	if rejected != nil {
+		t.Fatal("expected reservation over budget to be rejected")
+	}
+	if remaining != 40 || retryAfter != 40*time.Second {
+		t.Fatalf("unexpected remaining=%d retry_after=%v", remaining, retryAfter)
+	}
+
+	window.Reconcile(entry, 30)
+	if next, remaining, _ := window.ReserveAt(start.Add(20*time.Second), 50); next == nil || remaining != 20 {
+		t.Fatalf("expected reconciled window to admit reservation, got entry=%v remaining=%d", next, remaining)
+	}
+}
+
+func TestTokenWindowAdmitsOversizedRequestWhenEmpty(t *testing.T) {
+	window := NewTokenWindow(time.Minute, 10)
+	start := time.Unix(1700000000, 0)
+
+	if entry, remaining, _ := window.ReserveAt(start, 50); entry == nil || remaining != 0 {
+		t.Fatalf("expected oversized request to pass on an empty window, got entry=%v remaining=%d", entry, remaining)
+	}
+	if entry, _, _ := window.ReserveAt(start.Add(time.Second), 1); entry != nil {
+		t.Fatal("expected window to be exhausted")
+	}
+	if entry, _, _ := window.ReserveAt(start.Add(61*time.Second), 1); entry == nil {
+		t.Fatal("expected window to recover after expiry")
+	}
+}
+
+func TestHandleRequestEnforcesKeyTokensPerMinute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("x-ratelimit-remaining-tokens", "999999")
+		_, _ = io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","usage":{"prompt_tokens":60,"completion_tokens":30,"total_tokens":90}}`)
+	}))
+	defer upstream.Close()
+
+	groupName := "tpm-group"
+	p := &Proxy{
+		balancers: map[string]balancer.Balancer{
+			groupName: &sequenceBalancer{models: []*models.ModelConfig{{Name: "m", Type: "openai", BaseURL: upstream.URL}}},
+		},
+		groups: map[string]models.ModelGroup{groupName: {Name: groupName}},
+	}
+	key := auth.Key{KeyId: 9001, TokensPerMinute: 100}
+
+	send := func() *httptest.ResponseRecorder {
+		body := []byte(`{"model":"tpm-group","messages":[{"role":"user","content":"summarize the quarterly report in three bullet points"}]}`)
+		rec, _ := serveBody(p, groupName, body, withKey(key), withHeader("Content-Type", "application/json"))
+		return rec
+	}
+
+	first := send()
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got %d %q", first.Code, first.Body.String())
+	}
+	if got := first.Header().Get("x-ratelimit-remaining-tokens"); got == "" || got == "999999" {
+		t.Fatalf("expected gateway remaining tokens header, got %q", got)
+	}
+
+	second := send()
+	if second.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected second request to be token limited, got %d %q", second.Code, second.Body.String())
+	}
+	if second.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header on token limited response")
+	}
+	if got := second.Header().Get("x-ratelimit-remaining-tokens"); got != "10" {
+		t.Fatalf("expected 10 remaining tokens after reconciliation, got %q", got)
+	}
+}
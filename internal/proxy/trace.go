@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/tracing"
+)
+
+// startAttemptSpan opens the client span for one forwardOnce attempt.
+func startAttemptSpan(ctx context.Context, modelGroup string, upstreamModel *models.ModelConfig, candidate int, attempt int) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "janus.upstream.attempt",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("janus.model_group", modelGroup),
+			attribute.String("janus.upstream", upstreamModel.Name),
+			attribute.String("janus.provider", providerName(upstreamModel)),
+			attribute.Int("janus.candidate", candidate),
+			attribute.Int("janus.attempt", attempt),
+		),
+	)
+}
+
+func endAttemptSpan(span trace.Span, status int, retry bool, err error) {
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", status),
+		attribute.Bool("janus.retry", retry),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// recordFirstToken marks time-to-first-token on the attempt span of a stream.
+func recordFirstToken(ctx context.Context, ttft time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent("first_token")
+	span.SetAttributes(attribute.Int64("janus.ttft_ms", latencyMilliseconds(ttft)))
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/tracing"
+)
+
+func TestHandleRequestTracesAttemptsAndPropagatesTraceparent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(previous)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"id\":\"1\",\"choices\":[],\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":1,\"total_tokens\":2}}\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+
+	p := NewProxy()
+	p.RegisterModelGroup(&models.ModelGroup{
+		Name:     "traced",
+		Strategy: "weighted",
+		Models: []models.ModelConfig{
+			{Name: "primary", Type: "openai", BaseURL: failing.URL, Weight: 100},
+			{Name: "backup", Type: "openai", BaseURL: upstream.URL, Weight: 1},
+		},
+	})
+
+	rootCtx, root := tracing.Start(context.Background(), "janus.request")
+	body := []byte(`{"model":"traced","stream":true,"messages":[]}`)
+	rec, _ := serveBody(p, "traced", body, withStream(), withRequestContext(rootCtx))
+	root.End()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected fallback to succeed, got %d %q", rec.Code, rec.Body.String())
+	}
+	traceID := root.SpanContext().TraceID().String()
+	if traceparent == "" || traceparent[3:35] != traceID {
+		t.Fatalf("expected traceparent for trace %s, got %q", traceID, traceparent)
+	}
+
+	var attempts []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "janus.upstream.attempt" {
+			attempts = append(attempts, span)
+		}
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("expected two attempt spans, got %d", len(attempts))
+	}
+	first := spanAttributes(attempts[0])
+	if first["janus.upstream"].AsString() != "primary" || first["janus.candidate"].AsInt64() != 1 || first["http.response.status_code"].AsInt64() != http.StatusBadGateway {
+		t.Fatalf("unexpected first attempt attributes: %v", first)
+	}
+	second := spanAttributes(attempts[1])
+	if second["janus.upstream"].AsString() != "backup" || second["janus.candidate"].AsInt64() != 2 || second["janus.attempt"].AsInt64() != 1 {
+		t.Fatalf("unexpected second attempt attributes: %v", second)
+	}
+	if _, ok := second["janus.ttft_ms"]; !ok {
+		t.Fatalf("expected time-to-first-token on the streaming attempt")
+	}
+	if attempts[1].Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Fatalf("expected attempt span to be a child of the request span")
+	}
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	out := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		out[kv.Key] = kv.Value
+	}
+	return out
+}
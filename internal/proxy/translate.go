@@ -0,0 +1,769 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+const (
+	anthropicMessagesPath     = "/v1/messages"
+	defaultAnthropicMaxTokens = 4096
+)
+
+var errRequestTranslation = errors.New("request cannot be translated for upstream")
+
+// contextStreamIncludeUsage records whether a translated chat stream request asked for the
+// usage chunk (stream_options.include_usage).
+const contextStreamIncludeUsage = "streamIncludeUsage"
+
+// AnthropicChatAdapter serves OpenAI chat/completions requests from an Anthropic Messages upstream.
+type AnthropicChatAdapter struct {
+	AnthropicAdapter
+}
+
+type openAIChatRequest struct {
+	Model               string               `json:"model"`
+	Messages            []openAIChatMessage  `json:"messages"`
+	MaxTokens           *int                 `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int                 `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64             `json:"temperature,omitempty"`
+	TopP                *float64             `json:"top_p,omitempty"`
+	Stream              bool                 `json:"stream,omitempty"`
+	StreamOptions       *openAIStreamOptions `json:"stream_options,omitempty"`
+	Stop                json.RawMessage      `json:"stop,omitempty"`
+	Tools               []openAITool         `json:"tools,omitempty"`
+	ToolChoice          json.RawMessage      `json:"tool_choice,omitempty"`
+	User                string               `json:"user,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIChatMessage struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+type openAIToolCall struct {
+	Index    *int               `json:"index,omitempty"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type openAIChatCompletion struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []openAIChatChoice `json:"choices"`
+	Usage   *openAIUsage       `json:"usage,omitempty"`
+}
+
+type openAIChatChoice struct {
+	Index        int               `json:"index"`
+	Message      *openAIChatOutput `json:"message,omitempty"`
+	Delta        *openAIChatDelta  `json:"delta,omitempty"`
+	FinishReason *string           `json:"finish_reason"`
+}
+
+type openAIChatOutput struct {
+	Role             string           `json:"role"`
+	Content          *string          `json:"content"`
+	ReasoningContent string           `json:"reasoning_content,omitempty"`
+	ToolCalls        []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAIChatDelta struct {
+	Role             string           `json:"role,omitempty"`
+	Content          *string          `json:"content,omitempty"`
+	ReasoningContent *string          `json:"reasoning_content,omitempty"`
+	ToolCalls        []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIErrorBody struct {
+	Error struct {
+		Message string  `json:"message"`
+		Type    string  `json:"type"`
+		Code    *string `json:"code"`
+	} `json:"error"`
+}
+
+type anthropicMessagesRequest struct {
+	Model         string               `json:"model"`
+	System        json.RawMessage      `json:"system,omitempty"`
+	Messages      []anthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	TopP          *float64             `json:"top_p,omitempty"`
+	TopK          *int                 `json:"top_k,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Metadata      *anthropicMetadata   `json:"metadata,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type anthropicContentBlock struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text,omitempty"`
+	Thinking  string                `json:"thinking,omitempty"`
+	ID        string                `json:"id,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	Input     json.RawMessage       `json:"input,omitempty"`
+	ToolUseID string                `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage       `json:"content,omitempty"`
+	IsError   bool                  `json:"is_error,omitempty"`
+	Source    *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+type anthropicMessageResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []anthropicContentBlock `json:"content"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        *anthropicUsage         `json:"usage,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicStreamEvent struct {
+	Type         string                    `json:"type"`
+	Message      *anthropicMessageResponse `json:"message,omitempty"`
+	Index        int                       `json:"index"`
+	ContentBlock *anthropicContentBlock    `json:"content_block,omitempty"`
+	Delta        *anthropicStreamDelta     `json:"delta,omitempty"`
+	Usage        *anthropicUsage           `json:"usage,omitempty"`
+	Error        *anthropicError           `json:"error,omitempty"`
+}
+
+type anthropicStreamDelta struct {
+	Type         string  `json:"type,omitempty"`
+	Text         string  `json:"text,omitempty"`
+	Thinking     string  `json:"thinking,omitempty"`
+	PartialJSON  string  `json:"partial_json,omitempty"`
+	StopReason   *string `json:"stop_reason,omitempty"`
+	StopSequence *string `json:"stop_sequence,omitempty"`
+}
+
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type anthropicErrorBody struct {
+	Type  string          `json:"type"`
+	Error *anthropicError `json:"error"`
+}
+
+func (a *AnthropicChatAdapter) BuildRequest(c *gin.Context, endpointPath string, upstreamModel *models.ModelConfig, rawBody []byte) (*http.Request, error) {
+	translated, err := chatRequestToMessages(rawBody)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(contextStreamIncludeUsage, chatStreamIncludesUsage(rawBody))
+	req, err := a.AnthropicAdapter.BuildRequest(c, anthropicMessagesPath, upstreamModel, translated)
+	if err != nil {
+		return nil, err
+	}
+	// Let the transport negotiate compression so the response body can be decoded for translation.
+	req.Header.Del("Accept-Encoding")
+	return req, nil
+}
+
+func (a *AnthropicChatAdapter) TranslateResponse(statusCode int, respBody []byte) ([]byte, error) {
+	if statusCode >= http.StatusBadRequest {
+		return anthropicErrorToOpenAI(respBody)
+	}
+	return messagesResponseToChat(respBody)
+}
+
+func (a *AnthropicChatAdapter) NewStreamTranslator(c *gin.Context) StreamTranslator {
+	return &chatStreamTranslator{
+		created:      time.Now().Unix(),
+		includeUsage: c.GetBool(contextStreamIncludeUsage),
+		toolIndexes:  make(map[int]int),
+	}
+}
+
+// chatStreamIncludesUsage reports whether an OpenAI chat request asked for the usage chunk.
+// Anthropic always reports usage, which is billed either way; only the chunk is optional.
+func chatStreamIncludesUsage(rawBody []byte) bool {
+	var in openAIChatRequest
+	if err := json.Unmarshal(rawBody, &in); err != nil {
+		return false
+	}
+	return in.StreamOptions != nil && in.StreamOptions.IncludeUsage
+}
+
+func chatRequestToMessages(rawBody []byte) ([]byte, error) {
+	var in openAIChatRequest
+	if err := json.Unmarshal(rawBody, &in); err != nil {
+		return nil, fmt.Errorf("%w: %v", errRequestTranslation, err)
+	}
+
+	out := anthropicMessagesRequest{
+		Model:       in.Model,
+		Temperature: in.Temperature,
+		TopP:        in.TopP,
+		Stream:      in.Stream,
+		MaxTokens:   defaultAnthropicMaxTokens,
+	}
+	if in.MaxCompletionTokens != nil && *in.MaxCompletionTokens > 0 {
+		out.MaxTokens = *in.MaxCompletionTokens
+	} else if in.MaxTokens != nil && *in.MaxTokens > 0 {
+		out.MaxTokens = *in.MaxTokens
+	}
+	if strings.TrimSpace(in.User) != "" {
+		out.Metadata = &anthropicMetadata{UserID: in.User}
+	}
+
+	stop, err := parseStopSequences(in.Stop)
+	if err != nil {
+		return nil, err
+	}
+	out.StopSequences = stop
+
+	for _, tool := range in.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		schema := tool.Function.Parameters
+		if len(schema) == 0 || string(schema) == "null" {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	toolChoice, err := parseOpenAIToolChoice(in.ToolChoice)
+	if err != nil {
+		return nil, err
+	}
+	out.ToolChoice = toolChoice
+
+	var system []string
+	var messages []anthropicMessage
+	var pendingRole string
+	var pendingBlocks []anthropicContentBlock
+	flush := func() error {
+		if pendingRole == "" || len(pendingBlocks) == 0 {
+			pendingRole = ""
+			pendingBlocks = nil
+			return nil
+		}
+		content, err := json.Marshal(pendingBlocks)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, anthropicMessage{Role: pendingRole, Content: content})
+		pendingRole = ""
+		pendingBlocks = nil
+		return nil
+	}
+	appendBlocks := func(role string, blocks []anthropicContentBlock) error {
+		if len(blocks) == 0 {
+			return nil
+		}
+		// Anthropic requires alternating roles, so consecutive turns from the same role are merged.
+		if pendingRole != role {
+			if err := flush(); err != nil {
+				return err
+			}
+			pendingRole = role
+		}
+		pendingBlocks = append(pendingBlocks, blocks...)
+		return nil
+	}
+
+	for _, message := range in.Messages {
+		switch message.Role {
+		case "system", "developer":
+			text, err := openAIContentText(message.Content)
+			if err != nil {
+				return nil, err
+			}
+			if text != "" {
+				system = append(system, text)
+			}
+		case "user":
+			blocks, err := openAIContentBlocks(message.Content)
+			if err != nil {
+				return nil, err
+			}
+			if err := appendBlocks("user", blocks); err != nil {
+				return nil, err
+			}
+		case "assistant":
+			blocks, err := openAIContentBlocks(message.Content)
+			if err != nil {
+				return nil, err
+			}
+			for _, call := range message.ToolCalls {
+				blocks = append(blocks, anthropicContentBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: toolArgumentsObject(call.Function.Arguments),
+				})
+			}
+			if err := appendBlocks("assistant", blocks); err != nil {
+				return nil, err
+			}
+		case "tool", "function":
+			text, err := openAIContentText(message.Content)
+			if err != nil {
+				return nil, err
+			}
+			content, err := json.Marshal(text)
+			if err != nil {
+				return nil, err
+			}
+			if err := appendBlocks("user", []anthropicContentBlock{{
+				Type:      "tool_result",
+				ToolUseID: message.ToolCallID,
+				Content:   content,
+			}}); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported message role %q", errRequestTranslation, message.Role)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("%w: messages must include at least one user or assistant turn", errRequestTranslation)
+	}
+	out.Messages = messages
+
+	if len(system) > 0 {
+		systemText, err := json.Marshal(strings.Join(system, "\n\n"))
+		if err != nil {
+			return nil, err
+		}
+		out.System = systemText
+	}
+
+	return json.Marshal(out)
+}
+
+func parseStopSequences(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if single == "" {
+			return nil, nil
+		}
+		return []string{single}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, fmt.Errorf("%w: stop must be a string or string array", errRequestTranslation)
+	}
+	return many, nil
+}
+
+func parseOpenAIToolChoice(raw json.RawMessage) (*anthropicToolChoice, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		switch mode {
+		case "auto":
+			return &anthropicToolChoice{Type: "auto"}, nil
+		case "required":
+			return &anthropicToolChoice{Type: "any"}, nil
+		case "none":
+			return &anthropicToolChoice{Type: "none"}, nil
+		default:
+			return nil, fmt.Errorf("%w: unsupported tool_choice %q", errRequestTranslation, mode)
+		}
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Function.Name == "" {
+		return nil, fmt.Errorf("%w: invalid tool_choice", errRequestTranslation)
+	}
+	return &anthropicToolChoice{Type: "tool", Name: named.Function.Name}, nil
+}
+
+func openAIContentText(raw json.RawMessage) (string, error) {
+	blocks, err := openAIContentBlocks(raw)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+func openAIContentBlocks(raw json.RawMessage) ([]anthropicContentBlock, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if text == "" {
+			return nil, nil
+		}
+		return []anthropicContentBlock{{Type: "text", Text: text}}, nil
+	}
+
+	var parts []openAIContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, fmt.Errorf("%w: message content must be a string or content part array", errRequestTranslation)
+	}
+	blocks := make([]anthropicContentBlock, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			if part.Text != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: part.Text})
+			}
+		case "image_url":
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return nil, fmt.Errorf("%w: image_url part without url", errRequestTranslation)
+			}
+			blocks = append(blocks, anthropicContentBlock{Type: "image", Source: imageSourceFromURL(part.ImageURL.URL)})
+		default:
+			return nil, fmt.Errorf("%w: unsupported content part type %q", errRequestTranslation, part.Type)
+		}
+	}
+	return blocks, nil
+}
+
+func imageSourceFromURL(url string) *anthropicImageSource {
+	if strings.HasPrefix(url, "data:") {
+		header, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		if ok && strings.HasSuffix(header, ";base64") {
+			return &anthropicImageSource{
+				Type:      "base64",
+				MediaType: strings.TrimSuffix(header, ";base64"),
+				Data:      data,
+			}
+		}
+	}
+	return &anthropicImageSource{Type: "url", URL: url}
+}
+
+func toolArgumentsObject(arguments string) json.RawMessage {
+	arguments = strings.TrimSpace(arguments)
+	if arguments == "" || !json.Valid([]byte(arguments)) {
+		return json.RawMessage(`{}`)
+	}
+	return json.RawMessage(arguments)
+}
+
+func messagesResponseToChat(respBody []byte) ([]byte, error) {
+	var in anthropicMessageResponse
+	if err := json.Unmarshal(respBody, &in); err != nil {
+		return nil, err
+	}
+
+	message := &openAIChatOutput{Role: "assistant"}
+	var text strings.Builder
+	var reasoning strings.Builder
+	for _, block := range in.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "thinking":
+			reasoning.WriteString(block.Thinking)
+		case "tool_use":
+			input := string(block.Input)
+			if input == "" {
+				input = "{}"
+			}
+			message.ToolCalls = append(message.ToolCalls, openAIToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: openAIFunctionCall{Name: block.Name, Arguments: input},
+			})
+		}
+	}
+	if text.Len() > 0 || len(message.ToolCalls) == 0 {
+		content := text.String()
+		message.Content = &content
+	}
+	message.ReasoningContent = reasoning.String()
+
+	out := openAIChatCompletion{
+		ID:      in.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   in.Model,
+		Choices: []openAIChatChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: chatFinishReason(in.StopReason),
+		}},
+	}
+	if in.Usage != nil {
+		out.Usage = &openAIUsage{
+			PromptTokens:     in.Usage.InputTokens,
+			CompletionTokens: in.Usage.OutputTokens,
+			TotalTokens:      in.Usage.InputTokens + in.Usage.OutputTokens,
+		}
+	}
+	return json.Marshal(out)
+}
+
+func chatFinishReason(stopReason *string) *string {
+	if stopReason == nil {
+		return nil
+	}
+	reason := "stop"
+	switch *stopReason {
+	case "max_tokens":
+		reason = "length"
+	case "tool_use":
+		reason = "tool_calls"
+	case "refusal":
+		reason = "content_filter"
+	}
+	return &reason
+}
+
+func anthropicErrorToOpenAI(respBody []byte) ([]byte, error) {
+	var in anthropicErrorBody
+	if err := json.Unmarshal(respBody, &in); err != nil {
+		return nil, err
+	}
+	if in.Error == nil {
+		return nil, errors.New("upstream error body has no error object")
+	}
+	var out openAIErrorBody
+	out.Error.Message = in.Error.Message
+	out.Error.Type = in.Error.Type
+	return json.Marshal(out)
+}
+
+type chatStreamTranslator struct {
+	id            string
+	model         string
+	created       int64
+	promptTokens  int
+	includeUsage  bool
+	toolIndexes   map[int]int
+	nextToolIndex int
+}
+
+func (t *chatStreamTranslator) TranslateStreamLine(line []byte) []byte {
+	data, ok := sseData(line)
+	if !ok {
+		return nil
+	}
+
+	var event anthropicStreamEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return nil
+	}
+
+	switch event.Type {
+	case "message_start":
+		if event.Message != nil {
+			t.id = event.Message.ID
+			t.model = event.Message.Model
+			if event.Message.Usage != nil {
+				t.promptTokens = event.Message.Usage.InputTokens
+			}
+		}
+		empty := ""
+		return t.chunk(&openAIChatDelta{Role: "assistant", Content: &empty}, nil, nil)
+	case "content_block_start":
+		if event.ContentBlock == nil || event.ContentBlock.Type != "tool_use" {
+			return nil
+		}
+		index := t.nextToolIndex
+		t.nextToolIndex++
+		t.toolIndexes[event.Index] = index
+		return t.chunk(&openAIChatDelta{ToolCalls: []openAIToolCall{{
+			Index:    &index,
+			ID:       event.ContentBlock.ID,
+			Type:     "function",
+			Function: openAIFunctionCall{Name: event.ContentBlock.Name, Arguments: ""},
+		}}}, nil, nil)
+	case "content_block_delta":
+		if event.Delta == nil {
+			return nil
+		}
+		switch event.Delta.Type {
+		case "text_delta":
+			text := event.Delta.Text
+			return t.chunk(&openAIChatDelta{Content: &text}, nil, nil)
+		case "thinking_delta":
+			thinking := event.Delta.Thinking
+			return t.chunk(&openAIChatDelta{ReasoningContent: &thinking}, nil, nil)
+		case "input_json_delta":
+			index, ok := t.toolIndexes[event.Index]
+			if !ok {
+				return nil
+			}
+			return t.chunk(&openAIChatDelta{ToolCalls: []openAIToolCall{{
+				Index:    &index,
+				Function: openAIFunctionCall{Arguments: event.Delta.PartialJSON},
+			}}}, nil, nil)
+		}
+		return nil
+	case "message_delta":
+		var finishReason *string
+		if event.Delta != nil {
+			finishReason = chatFinishReason(event.Delta.StopReason)
+		}
+		out := t.chunk(&openAIChatDelta{}, finishReason, nil)
+		// Clients that did not ask for usage expect every chunk to carry a choice.
+		if event.Usage != nil && t.includeUsage {
+			promptTokens := t.promptTokens
+			if event.Usage.InputTokens > 0 {
+				promptTokens = event.Usage.InputTokens
+			}
+			out = append(out, t.chunk(nil, nil, &openAIUsage{
+				PromptTokens:     promptTokens,
+				CompletionTokens: event.Usage.OutputTokens,
+				TotalTokens:      promptTokens + event.Usage.OutputTokens,
+			})...)
+		}
+		return out
+	case "message_stop":
+		return []byte("data: [DONE]\n\n")
+	case "error":
+		if event.Error == nil {
+			return nil
+		}
+		var body openAIErrorBody
+		body.Error.Message = event.Error.Message
+		body.Error.Type = event.Error.Type
+		return sseDataLine(body)
+	}
+	return nil
+}
+
+func (t *chatStreamTranslator) Finish() []byte {
+	return nil
+}
+
+func (t *chatStreamTranslator) chunk(delta *openAIChatDelta, finishReason *string, usage *openAIUsage) []byte {
+	out := openAIChatCompletion{
+		ID:      t.id,
+		Object:  "chat.completion.chunk",
+		Created: t.created,
+		Model:   t.model,
+		Choices: []openAIChatChoice{},
+		Usage:   usage,
+	}
+	if delta != nil {
+		out.Choices = append(out.Choices, openAIChatChoice{Index: 0, Delta: delta, FinishReason: finishReason})
+	}
+	return sseDataLine(out)
+}
+
+func sseData(line []byte) (string, bool) {
+	trimmed := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(trimmed, "data:") {
+		return "", false
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(trimmed, "data:"))
+	if data == "" || data == "[DONE]" {
+		return "", false
+	}
+	return data, true
+}
+
+func sseDataLine(payload interface{}) []byte {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	out := make([]byte, 0, len(encoded)+8)
+	out = append(out, "data: "...)
+	out = append(out, encoded...)
+	out = append(out, "\n\n"...)
+	return out
+}
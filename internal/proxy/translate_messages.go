@@ -0,0 +1,512 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+const openAIChatCompletionsPath = "/v1/chat/completions"
+
+// OpenAIMessagesAdapter serves Anthropic Messages requests from an OpenAI-compatible chat/completions upstream.
+type OpenAIMessagesAdapter struct {
+	OpenAIAdapter
+}
+
+func (a *OpenAIMessagesAdapter) BuildRequest(c *gin.Context, endpointPath string, upstreamModel *models.ModelConfig, rawBody []byte) (*http.Request, error) {
+	translated, err := messagesRequestToChat(rawBody)
+	if err != nil {
+		return nil, err
+	}
+	req, err := a.OpenAIAdapter.BuildRequest(c, openAIChatCompletionsPath, upstreamModel, translated)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del("x-api-key")
+	req.Header.Del("anthropic-version")
+	req.Header.Del("anthropic-beta")
+	return req, nil
+}
+
+func (a *OpenAIMessagesAdapter) TranslateResponse(statusCode int, respBody []byte) ([]byte, error) {
+	if statusCode >= http.StatusBadRequest {
+		return openAIErrorToAnthropic(respBody)
+	}
+	return chatResponseToMessages(respBody)
+}
+
+func (a *OpenAIMessagesAdapter) NewStreamTranslator(c *gin.Context) StreamTranslator {
+	return &messagesStreamTranslator{
+		toolBlocks: make(map[int]int),
+	}
+}
+
+func messagesRequestToChat(rawBody []byte) ([]byte, error) {
+	var in anthropicMessagesRequest
+	if err := json.Unmarshal(rawBody, &in); err != nil {
+		return nil, fmt.Errorf("%w: %v", errRequestTranslation, err)
+	}
+
+	out := openAIChatRequest{
+		Model:       in.Model,
+		Temperature: in.Temperature,
+		TopP:        in.TopP,
+		Stream:      in.Stream,
+	}
+	if in.MaxTokens > 0 {
+		maxTokens := in.MaxTokens
+		out.MaxTokens = &maxTokens
+	}
+	if in.Stream {
+		// Usage is only reported on OpenAI streams when requested; spend accounting depends on it.
+		out.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	if len(in.StopSequences) > 0 {
+		stop, err := json.Marshal(in.StopSequences)
+		if err != nil {
+			return nil, err
+		}
+		out.Stop = stop
+	}
+	if in.Metadata != nil {
+		out.User = in.Metadata.UserID
+	}
+
+	for _, tool := range in.Tools {
+		var converted openAITool
+		converted.Type = "function"
+		converted.Function.Name = tool.Name
+		converted.Function.Description = tool.Description
+		converted.Function.Parameters = tool.InputSchema
+		out.Tools = append(out.Tools, converted)
+	}
+	if in.ToolChoice != nil {
+		toolChoice, err := anthropicToolChoiceToOpenAI(in.ToolChoice)
+		if err != nil {
+			return nil, err
+		}
+		out.ToolChoice = toolChoice
+	}
+
+	system, err := anthropicSystemText(in.System)
+	if err != nil {
+		return nil, err
+	}
+	if system != "" {
+		content, err := json.Marshal(system)
+		if err != nil {
+			return nil, err
+		}
+		out.Messages = append(out.Messages, openAIChatMessage{Role: "system", Content: content})
+	}
+
+	for _, message := range in.Messages {
+		converted, err := anthropicMessageToOpenAI(message)
+		if err != nil {
+			return nil, err
+		}
+		out.Messages = append(out.Messages, converted...)
+	}
+	if len(out.Messages) == 0 {
+		return nil, fmt.Errorf("%w: messages is empty", errRequestTranslation)
+	}
+
+	return json.Marshal(out)
+}
+
+func anthropicToolChoiceToOpenAI(choice *anthropicToolChoice) (json.RawMessage, error) {
+	switch choice.Type {
+	case "auto":
+		return json.RawMessage(`"auto"`), nil
+	case "any":
+		return json.RawMessage(`"required"`), nil
+	case "none":
+		return json.RawMessage(`"none"`), nil
+	case "tool":
+		return json.Marshal(map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": choice.Name},
+		})
+	default:
+		return nil, fmt.Errorf("%w: unsupported tool_choice type %q", errRequestTranslation, choice.Type)
+	}
+}
+
+func anthropicSystemText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var blocks []anthropicContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return "", fmt.Errorf("%w: system must be a string or text block array", errRequestTranslation)
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "text" && block.Text != "" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+func anthropicContentToBlocks(raw json.RawMessage) ([]anthropicContentBlock, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []anthropicContentBlock{{Type: "text", Text: text}}, nil
+	}
+	var blocks []anthropicContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return nil, fmt.Errorf("%w: message content must be a string or content block array", errRequestTranslation)
+	}
+	return blocks, nil
+}
+
+func anthropicMessageToOpenAI(message anthropicMessage) ([]openAIChatMessage, error) {
+	blocks, err := anthropicContentToBlocks(message.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []openAIChatMessage
+	var parts []map[string]interface{}
+	var toolCalls []openAIToolCall
+	textOnly := true
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			parts = append(parts, map[string]interface{}{"type": "text", "text": block.Text})
+		case "image":
+			if block.Source == nil {
+				return nil, fmt.Errorf("%w: image block without source", errRequestTranslation)
+			}
+			url := block.Source.URL
+			if block.Source.Type == "base64" {
+				url = "data:" + block.Source.MediaType + ";base64," + block.Source.Data
+			}
+			parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]string{"url": url}})
+			textOnly = false
+		case "tool_use":
+			input := string(block.Input)
+			if input == "" {
+				input = "{}"
+			}
+			toolCalls = append(toolCalls, openAIToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: openAIFunctionCall{Name: block.Name, Arguments: input},
+			})
+		case "tool_result":
+			// OpenAI expects each tool result as its own message ahead of the remaining user content.
+			result, err := anthropicToolResultText(block.Content)
+			if err != nil {
+				return nil, err
+			}
+			if block.IsError && result != "" {
+				result = "Error: " + result
+			}
+			content, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, openAIChatMessage{Role: "tool", ToolCallID: block.ToolUseID, Content: content})
+		case "thinking", "redacted_thinking":
+			continue
+		default:
+			return nil, fmt.Errorf("%w: unsupported content block type %q", errRequestTranslation, block.Type)
+		}
+	}
+
+	if len(parts) == 0 && len(toolCalls) == 0 {
+		return out, nil
+	}
+	converted := openAIChatMessage{Role: message.Role, ToolCalls: toolCalls}
+	switch {
+	case len(parts) == 0:
+		converted.Content = json.RawMessage("null")
+	case textOnly:
+		texts := make([]string, 0, len(parts))
+		for _, part := range parts {
+			texts = append(texts, part["text"].(string))
+		}
+		converted.Content, err = json.Marshal(strings.Join(texts, "\n"))
+	default:
+		converted.Content, err = json.Marshal(parts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(out, converted), nil
+}
+
+func anthropicToolResultText(raw json.RawMessage) (string, error) {
+	blocks, err := anthropicContentToBlocks(raw)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+func chatResponseToMessages(respBody []byte) ([]byte, error) {
+	var in openAIChatCompletion
+	if err := json.Unmarshal(respBody, &in); err != nil {
+		return nil, err
+	}
+	if len(in.Choices) == 0 || in.Choices[0].Message == nil {
+		return nil, errors.New("upstream chat completion has no choices")
+	}
+
+	choice := in.Choices[0]
+	content := make([]anthropicContentBlock, 0, 1+len(choice.Message.ToolCalls))
+	if choice.Message.Content != nil && *choice.Message.Content != "" {
+		content = append(content, anthropicContentBlock{Type: "text", Text: *choice.Message.Content})
+	}
+	for _, call := range choice.Message.ToolCalls {
+		content = append(content, anthropicContentBlock{
+			Type:  "tool_use",
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: toolArgumentsObject(call.Function.Arguments),
+		})
+	}
+
+	out := anthropicMessageResponse{
+		ID:         in.ID,
+		Type:       "message",
+		Role:       "assistant",
+		Model:      in.Model,
+		Content:    content,
+		StopReason: messagesStopReason(choice.FinishReason),
+		Usage:      &anthropicUsage{},
+	}
+	if in.Usage != nil {
+		out.Usage.InputTokens = in.Usage.PromptTokens
+		out.Usage.OutputTokens = in.Usage.CompletionTokens
+	}
+	return json.Marshal(out)
+}
+
+func messagesStopReason(finishReason *string) *string {
+	if finishReason == nil {
+		return nil
+	}
+	reason := "end_turn"
+	switch *finishReason {
+	case "length":
+		reason = "max_tokens"
+	case "tool_calls", "function_call":
+		reason = "tool_use"
+	case "content_filter":
+		reason = "refusal"
+	}
+	return &reason
+}
+
+func openAIErrorToAnthropic(respBody []byte) ([]byte, error) {
+	var in openAIErrorBody
+	if err := json.Unmarshal(respBody, &in); err != nil {
+		return nil, err
+	}
+	if in.Error.Message == "" {
+		return nil, errors.New("upstream error body has no error message")
+	}
+	errorType := in.Error.Type
+	if errorType == "" {
+		errorType = "api_error"
+	}
+	return json.Marshal(anthropicErrorBody{
+		Type:  "error",
+		Error: &anthropicError{Type: errorType, Message: in.Error.Message},
+	})
+}
+
+type messagesStreamTranslator struct {
+	started    bool
+	finished   bool
+	id         string
+	model      string
+	nextBlock  int
+	openBlock  int
+	openType   string
+	toolBlocks map[int]int
+	stopReason *string
+	usage      *openAIUsage
+}
+
+func (t *messagesStreamTranslator) TranslateStreamLine(line []byte) []byte {
+	trimmed := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(trimmed, "data:") {
+		return nil
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(trimmed, "data:"))
+	if data == "[DONE]" {
+		return t.Finish()
+	}
+	if data == "" {
+		return nil
+	}
+
+	var chunk struct {
+		openAIChatCompletion
+		Error *struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return nil
+	}
+	if chunk.Error != nil {
+		errorType := chunk.Error.Type
+		if errorType == "" {
+			errorType = "api_error"
+		}
+		return sseEvent("error", anthropicErrorBody{
+			Type:  "error",
+			Error: &anthropicError{Type: errorType, Message: chunk.Error.Message},
+		})
+	}
+
+	var out []byte
+	if !t.started {
+		t.started = true
+		t.id = chunk.ID
+		t.model = chunk.Model
+		out = append(out, sseEvent("message_start", map[string]interface{}{
+			"type": "message_start",
+			"message": anthropicMessageResponse{
+				ID:      t.id,
+				Type:    "message",
+				Role:    "assistant",
+				Model:   t.model,
+				Content: []anthropicContentBlock{},
+				Usage:   &anthropicUsage{},
+			},
+		})...)
+	}
+	if chunk.Usage != nil {
+		t.usage = chunk.Usage
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 || choice.Delta == nil {
+			continue
+		}
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			if t.openType != "text" {
+				out = append(out, t.closeBlock()...)
+				out = append(out, t.startBlock("text", map[string]interface{}{"type": "text", "text": ""})...)
+			}
+			out = append(out, sseEvent("content_block_delta", map[string]interface{}{
+				"type":  "content_block_delta",
+				"index": t.openBlock,
+				"delta": map[string]string{"type": "text_delta", "text": *choice.Delta.Content},
+			})...)
+		}
+		for _, call := range choice.Delta.ToolCalls {
+			toolIndex := 0
+			if call.Index != nil {
+				toolIndex = *call.Index
+			}
+			block, known := t.toolBlocks[toolIndex]
+			if !known {
+				out = append(out, t.closeBlock()...)
+				out = append(out, t.startBlock("tool_use", map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Function.Name,
+					"input": map[string]interface{}{},
+				})...)
+				block = t.openBlock
+				t.toolBlocks[toolIndex] = block
+			}
+			if call.Function.Arguments != "" {
+				out = append(out, sseEvent("content_block_delta", map[string]interface{}{
+					"type":  "content_block_delta",
+					"index": block,
+					"delta": map[string]string{"type": "input_json_delta", "partial_json": call.Function.Arguments},
+				})...)
+			}
+		}
+		if choice.FinishReason != nil {
+			t.stopReason = messagesStopReason(choice.FinishReason)
+			out = append(out, t.closeBlock()...)
+		}
+	}
+	return out
+}
+
+func (t *messagesStreamTranslator) Finish() []byte {
+	if !t.started || t.finished {
+		return nil
+	}
+	t.finished = true
+
+	out := t.closeBlock()
+	usage := anthropicUsage{}
+	if t.usage != nil {
+		usage.InputTokens = t.usage.PromptTokens
+		usage.OutputTokens = t.usage.CompletionTokens
+	}
+	stopReason := t.stopReason
+	if stopReason == nil {
+		endTurn := "end_turn"
+		stopReason = &endTurn
+	}
+	out = append(out, sseEvent("message_delta", map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]interface{}{"stop_reason": *stopReason, "stop_sequence": nil},
+		"usage": usage,
+	})...)
+	out = append(out, sseEvent("message_stop", map[string]string{"type": "message_stop"})...)
+	return out
+}
+
+func (t *messagesStreamTranslator) startBlock(blockType string, contentBlock map[string]interface{}) []byte {
+	t.openBlock = t.nextBlock
+	t.openType = blockType
+	t.nextBlock++
+	return sseEvent("content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         t.openBlock,
+		"content_block": contentBlock,
+	})
+}
+
+func (t *messagesStreamTranslator) closeBlock() []byte {
+	if t.openType == "" {
+		return nil
+	}
+	t.openType = ""
+	return sseEvent("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": t.openBlock,
+	})
+}
+
+func sseEvent(eventType string, payload interface{}) []byte {
+	data := sseDataLine(payload)
+	if data == nil {
+		return nil
+	}
+	return append([]byte("event: "+eventType+"\n"), data...)
+}
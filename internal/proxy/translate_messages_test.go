@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/balancer"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+func TestMessagesRequestToChatTranslatesAnthropicBody(t *testing.T) {
+	raw := []byte(`{
+		"model":"deepseek-v3",
+		"system":[{"type":"text","text":"be brief"}],
+		"max_tokens":128,
+		"stream":true,
+		"stop_sequences":["END"],
+		"tools":[{"name":"lookup","description":"find","input_schema":{"type":"object"}}],
+		"tool_choice":{"type":"tool","name":"lookup"},
+		"messages":[
+			{"role":"user","content":"find x"},
+			{"role":"assistant","content":[{"type":"text","text":"ok"},{"type":"tool_use","id":"toolu_1","name":"lookup","input":{"q":"x"}}]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"found"},{"type":"text","text":"thanks"}]}
+		]
+	}`)
+
+	out, err := messagesRequestToChat(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got openAIChatRequest
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("failed to decode translated body: %v", err)
+	}
+	if got.MaxTokens == nil || *got.MaxTokens != 128 {
+		t.Fatalf("unexpected max_tokens: %v", got.MaxTokens)
+	}
+	if got.StreamOptions == nil || !got.StreamOptions.IncludeUsage {
+		t.Fatalf("expected stream_options.include_usage for streaming request")
+	}
+	if string(got.Stop) != `["END"]` {
+		t.Fatalf("unexpected stop: %s", got.Stop)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Function.Name != "lookup" {
+		t.Fatalf("unexpected tools: %+v", got.Tools)
+	}
+	if !strings.Contains(string(got.ToolChoice), `"name":"lookup"`) {
+		t.Fatalf("unexpected tool_choice: %s", got.ToolChoice)
+	}
+
+	roles := make([]string, 0, len(got.Messages))
+	for _, message := range got.Messages {
+		roles = append(roles, message.Role)
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,tool,user" {
+		t.Fatalf("unexpected message roles: %v", roles)
+	}
+	assistant := got.Messages[2]
+	if len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].Function.Arguments != `{"q":"x"}` {
+		t.Fatalf("unexpected assistant tool calls: %+v", assistant.ToolCalls)
+	}
+	if got.Messages[3].ToolCallID != "toolu_1" || string(got.Messages[3].Content) != `"found"` {
+		t.Fatalf("unexpected tool message: %+v", got.Messages[3])
+	}
+}
+
+func TestChatResponseToMessagesTranslatesOpenAIBody(t *testing.T) {
+	out, err := chatResponseToMessages([]byte(`{
+		"id":"chatcmpl-1","object":"chat.completion","model":"deepseek",
+		"choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"length"}],
+		"usage":{"prompt_tokens":7,"completion_tokens":3,"total_tokens":10}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got anthropicMessageResponse
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("failed to decode translated response: %v", err)
+	}
+	if got.Type != "message" || got.Role != "assistant" || len(got.Content) != 1 || got.Content[0].Text != "hello" {
+		t.Fatalf("unexpected message: %s", out)
+	}
+	if got.StopReason == nil || *got.StopReason != "max_tokens" {
+		t.Fatalf("expected stop_reason max_tokens, got %v", got.StopReason)
+	}
+	if got.Usage == nil || got.Usage.InputTokens != 7 || got.Usage.OutputTokens != 3 {
+		t.Fatalf("unexpected usage: %+v", got.Usage)
+	}
+}
+
+func TestOpenAIErrorToAnthropicWrapsError(t *testing.T) {
+	out, err := openAIErrorToAnthropic([]byte(`{"error":{"message":"bad input","type":"invalid_request_error"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != `{"type":"error","error":{"type":"invalid_request_error","message":"bad input"}}` {
+		t.Fatalf("unexpected anthropic error body: %s", out)
+	}
+}
+
+func TestHandleRequestTranslatesMessagesStreamForOpenAIUpstream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var upstreamPath string
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath = r.URL.Path
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &upstreamBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-7\",\"model\":\"deepseek\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n")
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-7\",\"model\":\"deepseek\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-7\",\"model\":\"deepseek\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		_, _ = io.WriteString(w, "data: {\"id\":\"chatcmpl-7\",\"model\":\"deepseek\",\"choices\":[],\"usage\":{\"prompt_tokens\":8,\"completion_tokens\":1,\"total_tokens\":9}}\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+
+	groupName := "deepseek-v3"
+	p := &Proxy{
+		balancers: map[string]balancer.Balancer{
+			groupName: &sequenceBalancer{
+				models: []*models.ModelConfig{
+					{Name: "DeepSeek-V3", Type: "openai", BaseURL: upstream.URL},
+				},
+			},
+		},
+		groups: map[string]models.ModelGroup{
+			groupName: {Name: groupName},
+		},
+	}
+
+	body := []byte(`{"model":"deepseek-v3","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	rec, ctx := serveBody(p, groupName, body,
+		withPath("/v1/messages"),
+		withStream(),
+		withHeader("Accept", "text/event-stream"),
+		withHeader("Content-Type", "application/json"),
+		withHeader("anthropic-version", "2023-06-01"),
+	)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected translated stream to succeed, got status %d body %q", rec.Code, rec.Body.String())
+	}
+	if upstreamPath != "/v1/chat/completions" {
+		t.Fatalf("expected upstream path /v1/chat/completions, got %q", upstreamPath)
+	}
+	if _, ok := upstreamBody["stream_options"]; !ok {
+		t.Fatalf("expected stream_options to be injected, got %v", upstreamBody)
+	}
+
+	got := rec.Body.String()
+	for _, event := range []string{"event: message_start", "event: content_block_start", "event: content_block_delta", "event: content_block_stop", "event: message_delta", "event: message_stop"} {
+		if !strings.Contains(got, event) {
+			t.Fatalf("expected %q in translated stream, got %q", event, got)
+		}
+	}
+	if strings.Contains(got, "[DONE]") || strings.Contains(got, "chat.completion") {
+		t.Fatalf("expected OpenAI framing to be removed, got %q", got)
+	}
+
+	var requestID string
+	var usage *spend.TokenUsage
+	for _, line := range strings.Split(got, "\n") {
+		parseSpendStreamLine([]byte(line), &requestID, &usage, true)
+	}
+	if usage == nil || usage.PromptTokens != 8 || usage.CompletionTokens != 1 {
+		t.Fatalf("expected translated message_delta to carry usage, got %+v", usage)
+	}
+
+	spendValue, exists := ctx.Get(spend.ContextUpstreamResp)
+	if !exists {
+		t.Fatal("expected spend payload for translated stream")
+	}
+	var upstreamResp spend.UpstreamResp
+	if err := json.Unmarshal(spendValue.([]byte), &upstreamResp); err != nil {
+		t.Fatalf("failed to decode upstreamResp: %v", err)
+	}
+	if upstreamResp.Usage.PromptTokens != 8 || upstreamResp.Usage.CompletionTokens != 1 || upstreamResp.Usage.TotalTokens != 9 {
+		t.Fatalf("unexpected spend usage: %+v", upstreamResp.Usage)
+	}
+}
@@ -0,0 +1,335 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/balancer"
+	"github.com/Uuq114/JanusLLM/internal/models"
+	"github.com/Uuq114/JanusLLM/internal/spend"
+)
+
+func TestChatRequestToMessagesTranslatesOpenAIBody(t *testing.T) {
+	raw := []byte(`{
+		"model":"claude-3-7-sonnet",
+		"messages":[
+			{"role":"system","content":"be brief"},
+			{"role":"user","content":"weather in Paris?"},
+			{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"sunny"}
+		],
+		"tools":[{"type":"function","function":{"name":"get_weather","description":"lookup","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}],
+		"tool_choice":"required",
+		"stop":"END",
+		"max_tokens":256,
+		"stream":true,
+		"stream_options":{"include_usage":true}
+	}`)
+
+	out, err := chatRequestToMessages(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got anthropicMessagesRequest
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("failed to decode translated body: %v", err)
+	}
+	if string(got.System) != `"be brief"` {
+		t.Fatalf("expected system prompt to be lifted, got %s", got.System)
+	}
+	if got.MaxTokens != 256 || !got.Stream {
+		t.Fatalf("unexpected max_tokens/stream: %d/%v", got.MaxTokens, got.Stream)
+	}
+	if len(got.StopSequences) != 1 || got.StopSequences[0] != "END" {
+		t.Fatalf("unexpected stop_sequences: %v", got.StopSequences)
+	}
+	if got.ToolChoice == nil || got.ToolChoice.Type != "any" {
+		t.Fatalf("expected required tool_choice to map to any, got %+v", got.ToolChoice)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Name != "get_weather" || !strings.Contains(string(got.Tools[0].InputSchema), "city") {
+		t.Fatalf("unexpected tools: %+v", got.Tools)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("expected user/assistant/user turns, got %d", len(got.Messages))
+	}
+
+	var assistant []anthropicContentBlock
+	if err := json.Unmarshal(got.Messages[1].Content, &assistant); err != nil {
+		t.Fatalf("failed to decode assistant content: %v", err)
+	}
+	if len(assistant) != 1 || assistant[0].Type != "tool_use" || assistant[0].ID != "call_1" || string(assistant[0].Input) != `{"city":"Paris"}` {
+		t.Fatalf("unexpected assistant tool_use block: %+v", assistant)
+	}
+
+	var toolResult []anthropicContentBlock
+	if err := json.Unmarshal(got.Messages[2].Content, &toolResult); err != nil {
+		t.Fatalf("failed to decode tool result content: %v", err)
+	}
+	if got.Messages[2].Role != "user" || len(toolResult) != 1 || toolResult[0].Type != "tool_result" || toolResult[0].ToolUseID != "call_1" {
+		t.Fatalf("unexpected tool result turn: role=%s blocks=%+v", got.Messages[2].Role, toolResult)
+	}
+}
+
+func TestChatRequestToMessagesDefaultsMaxTokens(t *testing.T) {
+	out, err := chatRequestToMessages([]byte(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got anthropicMessagesRequest
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("failed to decode translated body: %v", err)
+	}
+	if got.MaxTokens != defaultAnthropicMaxTokens {
+		t.Fatalf("expected default max_tokens %d, got %d", defaultAnthropicMaxTokens, got.MaxTokens)
+	}
+}
+
+func TestChatRequestToMessagesRejectsUnknownRole(t *testing.T) {
+	_, err := chatRequestToMessages([]byte(`{"model":"m","messages":[{"role":"narrator","content":"hi"}]}`))
+	if !errors.Is(err, errRequestTranslation) {
+		t.Fatalf("expected translation error, got %v", err)
+	}
+}
+
+func TestMessagesResponseToChatTranslatesToolUse(t *testing.T) {
+	out, err := messagesResponseToChat([]byte(`{
+		"id":"msg_1","type":"message","role":"assistant","model":"claude",
+		"content":[{"type":"text","text":"Checking."},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],
+		"stop_reason":"tool_use",
+		"usage":{"input_tokens":11,"output_tokens":7}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got openAIChatCompletion
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("failed to decode translated response: %v", err)
+	}
+	if got.ID != "msg_1" || got.Object != "chat.completion" || len(got.Choices) != 1 {
+		t.Fatalf("unexpected completion envelope: %+v", got)
+	}
+	choice := got.Choices[0]
+	if choice.FinishReason == nil || *choice.FinishReason != "tool_calls" {
+		t.Fatalf("expected finish_reason tool_calls, got %v", choice.FinishReason)
+	}
+	if choice.Message.Content == nil || *choice.Message.Content != "Checking." {
+		t.Fatalf("unexpected content: %v", choice.Message.Content)
+	}
+	if len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Fatalf("unexpected tool calls: %+v", choice.Message.ToolCalls)
+	}
+	if got.Usage == nil || got.Usage.PromptTokens != 11 || got.Usage.CompletionTokens != 7 || got.Usage.TotalTokens != 18 {
+		t.Fatalf("unexpected usage: %+v", got.Usage)
+	}
+}
+
+func TestChatStreamTranslatorEmitsOpenAIChunks(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Set(contextStreamIncludeUsage, true)
+	translator := (&AnthropicChatAdapter{}).NewStreamTranslator(ctx)
+	lines := []string{
+		"event: message_start\n",
+		"data: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude\",\"usage\":{\"input_tokens\":5}}}\n",
+		"\n",
+		"data: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"lo\"}}\n",
+		"data: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"lookup\"}}\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"q\\\":1}\"}}\n",
+		"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":3}}\n",
+		"data: {\"type\":\"message_stop\"}\n",
+	}
+
+	var out bytes.Buffer
+	for _, line := range lines {
+		out.Write(translator.TranslateStreamLine([]byte(line)))
+	}
+	body := out.String()
+
+	if strings.Contains(body, "event:") {
+		t.Fatalf("expected anthropic event lines to be dropped, got %q", body)
+	}
+	if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("expected stream to end with [DONE], got %q", body)
+	}
+
+	var content strings.Builder
+	var finish string
+	var usage *openAIUsage
+	var toolArgs string
+	for _, event := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		data := strings.TrimPrefix(event, "data: ")
+		if data == "[DONE]" {
+			continue
+		}
+		var chunk openAIChatCompletion
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", data, err)
+		}
+		if chunk.Object != "chat.completion.chunk" || chunk.ID != "msg_1" {
+			t.Fatalf("unexpected chunk envelope: %+v", chunk)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != nil {
+				content.WriteString(*choice.Delta.Content)
+			}
+			for _, call := range choice.Delta.ToolCalls {
+				toolArgs += call.Function.Arguments
+			}
+			if choice.FinishReason != nil {
+				finish = *choice.FinishReason
+			}
+		}
+	}
+	if content.String() != "Hello" {
+		t.Fatalf("expected streamed content Hello, got %q", content.String())
+	}
+	if toolArgs != `{"q":1}` {
+		t.Fatalf("expected streamed tool arguments, got %q", toolArgs)
+	}
+	if finish != "stop" {
+		t.Fatalf("expected finish_reason stop, got %q", finish)
+	}
+	if usage == nil || usage.PromptTokens != 5 || usage.CompletionTokens != 3 || usage.TotalTokens != 8 {
+		t.Fatalf("unexpected usage chunk: %+v", usage)
+	}
+}
+
+func TestHandleRequestTranslatesChatCompletionsForAnthropicUpstream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var upstreamPath string
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath = r.URL.Path
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"id":"msg_9","type":"message","role":"assistant","model":"claude-upstream","content":[{"type":"text","text":"hi there"}],"stop_reason":"end_turn","usage":{"input_tokens":4,"output_tokens":2}}`)
+	}))
+	defer upstream.Close()
+
+	groupName := "mixed-group"
+	p := &Proxy{
+		balancers: map[string]balancer.Balancer{
+			groupName: &sequenceBalancer{
+				models: []*models.ModelConfig{
+					{Name: "claude-upstream", Type: "anthropic", BaseURL: upstream.URL, APIKey: "sk-ant"},
+				},
+			},
+		},
+		groups: map[string]models.ModelGroup{
+			groupName: {Name: groupName},
+		},
+	}
+
+	body := []byte(`{"model":"mixed-group","messages":[{"role":"system","content":"sys"},{"role":"user","content":"hi"}]}`)
+	rec, ctx := serveBody(p, groupName, body, withHeader("Content-Type", "application/json"))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected translated request to succeed, got status %d body %q", rec.Code, rec.Body.String())
+	}
+	if upstreamPath != "/v1/messages" {
+		t.Fatalf("expected upstream path /v1/messages, got %q", upstreamPath)
+	}
+	if upstreamBody["system"] != "sys" {
+		t.Fatalf("expected system prompt in upstream body, got %v", upstreamBody["system"])
+	}
+
+	var got openAIChatCompletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode client response: %v", err)
+	}
+	if got.Object != "chat.completion" || got.Choices[0].Message.Content == nil || *got.Choices[0].Message.Content != "hi there" {
+		t.Fatalf("unexpected client response: %s", rec.Body.String())
+	}
+
+	spendValue, exists := ctx.Get(spend.ContextUpstreamResp)
+	if !exists {
+		t.Fatal("expected spend payload for translated response")
+	}
+	var upstreamResp spend.UpstreamResp
+	if err := json.Unmarshal(spendValue.([]byte), &upstreamResp); err != nil {
+		t.Fatalf("failed to decode upstreamResp: %v", err)
+	}
+	if upstreamResp.Usage.PromptTokens != 4 || upstreamResp.Usage.CompletionTokens != 2 {
+		t.Fatalf("unexpected spend usage: %+v", upstreamResp.Usage)
+	}
+}
+
+func TestHandleRequestSendsTranslatedUsageChunkOnlyWhenRequested(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude\",\"usage\":{\"input_tokens\":5}}}\n\n")
+		_, _ = io.WriteString(w, "data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n")
+		_, _ = io.WriteString(w, "data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":3}}\n\n")
+		_, _ = io.WriteString(w, "data: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer upstream.Close()
+
+	for name, includeUsage := range map[string]bool{"requested": true, "not requested": false} {
+		groupName := "mixed-stream-group"
+		p := &Proxy{
+			balancers: map[string]balancer.Balancer{
+				groupName: &sequenceBalancer{models: []*models.ModelConfig{
+					{Name: "claude-upstream", Type: "anthropic", BaseURL: upstream.URL, APIKey: "sk-ant"},
+				}},
+			},
+			groups: map[string]models.ModelGroup{groupName: {Name: groupName}},
+		}
+		body := []byte(`{"model":"mixed-stream-group","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+		if includeUsage {
+			body = []byte(`{"model":"mixed-stream-group","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`)
+		}
+		rec, ctx := serveBody(p, groupName, body, withStream(), withHeader("Accept", "text/event-stream"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected translated stream to succeed, got %d %q", name, rec.Code, rec.Body.String())
+		}
+
+		var usageChunks int
+		for _, event := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+			data := strings.TrimPrefix(event, "data: ")
+			if data == "[DONE]" {
+				continue
+			}
+			var chunk openAIChatCompletion
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				t.Fatalf("%s: invalid chunk %q: %v", name, data, err)
+			}
+			if len(chunk.Choices) == 0 {
+				usageChunks++
+			}
+		}
+		if want := map[bool]int{true: 1, false: 0}[includeUsage]; usageChunks != want {
+			t.Fatalf("%s: expected %d usage chunks, got %d in %q", name, want, usageChunks, rec.Body.String())
+		}
+
+		spendValue, exists := ctx.Get(spend.ContextUpstreamResp)
+		if !exists {
+			t.Fatalf("%s: expected the stream to be billed", name)
+		}
+		var upstreamResp spend.UpstreamResp
+		if err := json.Unmarshal(spendValue.([]byte), &upstreamResp); err != nil {
+			t.Fatalf("%s: failed to decode upstreamResp: %v", name, err)
+		}
+		if upstreamResp.Usage.PromptTokens != 5 || upstreamResp.Usage.CompletionTokens != 3 {
+			t.Fatalf("%s: unexpected spend usage: %+v", name, upstreamResp.Usage)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package request
+
+import (
+	"encoding/json"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// bytesPerToken approximates BPE tokenizers on English text and JSON.
+	bytesPerToken = 4
+	// messageOverheadTokens covers the role and separators chat formats add per message.
+	messageOverheadTokens = 4
+	// replyPrimingTokens covers the assistant header appended after the last message.
+	replyPrimingTokens = 3
+	// imageTokens is charged per image part, about a 1024x1024 image at high detail.
+	imageTokens = 765
+)
+
+// promptFields are the request fields that reach the model across the OpenAI, Anthropic
+// and Gemini request formats.
+var promptFields = []string{"system", "prompt", "input", "tools", "functions", "contents", "systemInstruction", "system_instruction"}
+
+// EstimatePromptTokens estimates the prompt tokens of a request body without a tokenizer.
+// Message text is counted at about four bytes per token and one token per CJK character,
+// images at a fixed cost, and bodies that are not JSON by size alone.
+func EstimatePromptTokens(rawBody []byte) int {
+	var body map[string]interface{}
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		return max(1, (len(rawBody)+bytesPerToken-1)/bytesPerToken)
+	}
+
+	tokens := 0
+	if messages, ok := body["messages"].([]interface{}); ok {
+		for _, message := range messages {
+			tokens += messageOverheadTokens + valueTokens(message)
+		}
+		tokens += replyPrimingTokens
+	}
+	for _, field := range promptFields {
+		tokens += valueTokens(body[field])
+	}
+	return max(1, tokens)
+}
+
+// EstimateTextTokens estimates the tokens of generated text with the same rules as prompts.
+func EstimateTextTokens(text string) int {
+	return textTokens(text)
+}
+
+// RequestedCompletionTokens returns the output token limit the request asks for, or 0.
+func RequestedCompletionTokens(rawBody []byte) int {
+	var body map[string]interface{}
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		return 0
+	}
+	return CompletionLimit(body)
+}
+
+// CompletionLimit reads the output token limit from decoded request fields, e.g. a model
+// group's request defaults.
+func CompletionLimit(fields map[string]interface{}) int {
+	for _, field := range []string{"max_completion_tokens", "max_tokens", "max_output_tokens"} {
+		if limit := intValue(fields[field]); limit > 0 {
+			return limit
+		}
+	}
+	for _, field := range []string{"generationConfig", "generation_config"} {
+		if config, ok := fields[field].(map[string]interface{}); ok {
+			if limit := intValue(config["maxOutputTokens"]); limit > 0 {
+				return limit
+			}
+			if limit := intValue(config["max_output_tokens"]); limit > 0 {
+				return limit
+			}
+		}
+	}
+	return 0
+}
+
+func valueTokens(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return textTokens(v)
+	case []interface{}:
+		tokens := 0
+		for _, item := range v {
+			tokens += valueTokens(item)
+		}
+		return tokens
+	case map[string]interface{}:
+		if isImagePart(v) {
+			return imageTokens
+		}
+		tokens := 0
+		for _, item := range v {
+			tokens += valueTokens(item)
+		}
+		return tokens
+	}
+	return 0
+}
+
+// isImagePart reports image content parts, whose base64 payload says nothing about tokens.
+func isImagePart(part map[string]interface{}) bool {
+	switch part["type"] {
+	case "image_url", "image", "input_image":
+		return true
+	}
+	_, inline := part["inline_data"]
+	_, inlineCamel := part["inlineData"]
+	return inline || inlineCamel
+}
+
+func textTokens(text string) int {
+	wide, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			wide++
+			continue
+		}
+		other += utf8.RuneLen(r)
+	}
+	return wide + (other+bytesPerToken-1)/bytesPerToken
+}
+
+func intValue(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case uint64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package request
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimatePromptTokensCountsMessageText(t *testing.T) {
+	body := []byte(`{"model":"m","messages":[{"role":"user","content":"` + strings.Repeat("abcd", 100) + `"}]}`)
+	// 100 text tokens, one for the role, the message overhead and the reply priming.
+	if got := EstimatePromptTokens(body); got != 100+1+messageOverheadTokens+replyPrimingTokens {
+		t.Fatalf("unexpected estimate %d", got)
+	}
+
+	cjk := []byte(`{"messages":[{"role":"user","content":"你好世界"}]}`)
+	if got := EstimatePromptTokens(cjk); got != 4+1+messageOverheadTokens+replyPrimingTokens {
+		t.Fatalf("expected one token per CJK character, got %d", got)
+	}
+}
+
+func TestEstimatePromptTokensChargesImagesAtFixedCost(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,` + strings.Repeat("A", 40000) + `"}}]}]}`)
+	if got := EstimatePromptTokens(body); got != imageTokens+1+messageOverheadTokens+replyPrimingTokens {
+		t.Fatalf("expected image payload to be charged at a fixed cost, got %d", got)
+	}
+}
+
+func TestEstimatePromptTokensFallsBackToBodySize(t *testing.T) {
+	if got := EstimatePromptTokens([]byte("not json at all!")); got != 4 {
+		t.Fatalf("expected size-based estimate, got %d", got)
+	}
+	if got := EstimatePromptTokens(nil); got != 1 {
+		t.Fatalf("expected minimum estimate of 1, got %d", got)
+	}
+}
+
+func TestRequestedCompletionTokens(t *testing.T) {
+	cases := map[string]int{
+		`{"max_tokens":512}`:                             512,
+		`{"max_completion_tokens":256,"max_tokens":512}`: 256,
+		`{"generationConfig":{"maxOutputTokens":1024}}`:  1024,
+		`{"messages":[]}`:                                0,
+	}
+	for body, want := range cases {
+		if got := RequestedCompletionTokens([]byte(body)); got != want {
+			t.Fatalf("RequestedCompletionTokens(%s) = %d, want %d", body, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrUnsupportedScheme is returned for references without a registered scheme.
+var ErrUnsupportedScheme = errors.New("unsupported secret reference scheme")
+
+// Resolver returns the secret value a reference points to.
+type Resolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// Registry dispatches references such as env://OPENAI_API_KEY to the resolver registered for
+// their scheme.
+type Registry struct {
+	resolvers map[string]Resolver
+}
+
+// NewRegistry returns a registry with the env:// and file:// resolvers.
+func NewRegistry() *Registry {
+	r := &Registry{resolvers: make(map[string]Resolver)}
+	r.Register("env", EnvResolver{})
+	r.Register("file", FileResolver{})
+	return r
+}
+
+func (r *Registry) Register(scheme string, resolver Resolver) {
+	r.resolvers[strings.ToLower(scheme)] = resolver
+}
+
+func (r *Registry) Resolve(ctx context.Context, ref string) (string, error) {
+	scheme, _, ok := strings.Cut(strings.TrimSpace(ref), "://")
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedScheme, ref)
+	}
+	resolver, ok := r.resolvers[strings.ToLower(scheme)]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedScheme, scheme)
+	}
+	value, err := resolver.Resolve(ctx, strings.TrimSpace(ref))
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", fmt.Errorf("secret %s is empty", Redact(ref))
+	}
+	return value, nil
+}
+
+// EnvResolver reads env://NAME from the process environment.
+type EnvResolver struct{}
+
+func (EnvResolver) Resolve(_ context.Context, ref string) (string, error) {
+	name := strings.TrimPrefix(ref, "env://")
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// FileResolver reads file:///path, such as a Kubernetes secret volume. Surrounding whitespace,
+// including the trailing newline most secret tooling writes, is trimmed.
+type FileResolver struct{}
+
+func (FileResolver) Resolve(_ context.Context, ref string) (string, error) {
+	path := strings.TrimPrefix(ref, "file://")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Redact keeps the scheme and location of a reference for logs, dropping any field selector.
+func Redact(ref string) string {
+	ref, _, _ = strings.Cut(ref, "#")
+	return ref
+}
@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistryResolvesEnvAndFile(t *testing.T) {
+	t.Setenv("JANUS_TEST_PROVIDER_KEY", "sk-env")
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("sk-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret failed: %v", err)
+	}
+
+	registry := NewRegistry()
+	if got, err := registry.Resolve(context.Background(), "env://JANUS_TEST_PROVIDER_KEY"); err != nil || got != "sk-env" {
+		t.Fatalf("expected env secret, got %q err=%v", got, err)
+	}
+	if got, err := registry.Resolve(context.Background(), "file://"+path); err != nil || got != "sk-file" {
+		t.Fatalf("expected trimmed file secret, got %q err=%v", got, err)
+	}
+}
+
+func TestRegistryRejectsUnknownSchemesAndMissingValues(t *testing.T) {
+	registry := NewRegistry()
+	if _, err := registry.Resolve(context.Background(), "openai-prod-key"); !errors.Is(err, ErrUnsupportedScheme) {
+		t.Fatalf("expected unsupported scheme for a bare name, got %v", err)
+	}
+	if _, err := registry.Resolve(context.Background(), "vault://secret/data/openai"); !errors.Is(err, ErrUnsupportedScheme) {
+		t.Fatalf("expected vault to be unsupported until registered, got %v", err)
+	}
+	if _, err := registry.Resolve(context.Background(), "env://JANUS_TEST_UNSET_KEY"); err == nil {
+		t.Fatalf("expected an unset variable to fail")
+	}
+}
+
+func TestVaultResolverReadsKVv2AndKVv1(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/openai":
+			_, _ = w.Write([]byte(`{"data":{"data":{"api_key":"sk-v2","org":"o"},"metadata":{"version":3}}}`))
+		case "/v1/kv/anthropic":
+			_, _ = w.Write([]byte(`{"data":{"key":"sk-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := NewRegistry()
+	registry.Register("vault", NewVaultResolver(VaultConfig{Address: server.URL, Token: "root"}))
+
+	if got, err := registry.Resolve(context.Background(), "vault://secret/data/openai#api_key"); err != nil || got != "sk-v2" {
+		t.Fatalf("expected KV v2 field, got %q err=%v", got, err)
+	}
+	if got, err := registry.Resolve(context.Background(), "vault://kv/anthropic"); err != nil || got != "sk-v1" {
+		t.Fatalf("expected the only KV v1 field, got %q err=%v", got, err)
+	}
+	if _, err := registry.Resolve(context.Background(), "vault://secret/data/openai"); err == nil {
+		t.Fatalf("expected an ambiguous field to fail")
+	}
+	if _, err := registry.Resolve(context.Background(), "vault://secret/data/missing#api_key"); err == nil {
+		t.Fatalf("expected a missing secret to fail")
+	}
+}
@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultVaultTimeout = 10 * time.Second
+
+// VaultConfig points vault:// references at a Vault-compatible HTTP API. Address and Token fall
+// back to VAULT_ADDR and VAULT_TOKEN.
+type VaultConfig struct {
+	Address        string `yaml:"address"`
+	Token          string `yaml:"token"`
+	Namespace      string `yaml:"namespace"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+}
+
+// VaultResolver reads vault://<mount>/<path>#<field>. Both KV v2 (vault://secret/data/openai)
+// and KV v1 responses are understood; the field may be omitted when the secret has one key.
+type VaultResolver struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+func NewVaultResolver(cfg VaultConfig) *VaultResolver {
+	address := strings.TrimSpace(cfg.Address)
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	token := strings.TrimSpace(cfg.Token)
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	timeout := defaultVaultTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return &VaultResolver{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: strings.TrimSpace(cfg.Namespace),
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (v *VaultResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	if v.address == "" {
+		return "", fmt.Errorf("vault address is not configured for %s", Redact(ref))
+	}
+	path, field, _ := strings.Cut(strings.TrimPrefix(ref, "vault://"), "#")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", fmt.Errorf("vault reference %q has no path", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.address+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d for %s", resp.StatusCode, Redact(ref))
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode vault response for %s: %w", Redact(ref), err)
+	}
+	values := body.Data
+	if nested, ok := body.Data["data"]; ok {
+		var kv2 map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &kv2); err == nil {
+			values = kv2
+		}
+	}
+	return vaultField(values, field, ref)
+}
+
+func vaultField(values map[string]json.RawMessage, field string, ref string) (string, error) {
+	if field == "" {
+		if len(values) != 1 {
+			return "", fmt.Errorf("vault secret %s has %d fields; select one with #field", Redact(ref), len(values))
+		}
+		for name := range values {
+			field = name
+		}
+	}
+	raw, ok := values[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %q", Redact(ref), field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("vault secret %s field %q is not a string", Redact(ref), field)
+	}
+	return value, nil
+}
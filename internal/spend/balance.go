@@ -0,0 +1,202 @@
+package spend
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextBalanceHold carries the request's *BalanceHold until its spend is known.
+const ContextBalanceHold = "balanceHold"
+
+// Balances tracks available key balance in this process.
+var Balances = NewBalanceLedger()
+
+// BalanceBackend sets aside balance for in-flight requests.
+type BalanceBackend interface {
+	// Hold reserves amount from keyID's available balance. balance is the key's balance as
+	// last read from the database, used when the backend has nothing fresher.
+	Hold(keyID int, balance float64, amount float64) (*BalanceHold, bool)
+}
+
+var (
+	balanceBackendMu sync.RWMutex
+	balanceBackend   BalanceBackend = Balances
+)
+
+func SetBalanceBackend(backend BalanceBackend) {
+	if backend == nil {
+		backend = Balances
+	}
+	balanceBackendMu.Lock()
+	defer balanceBackendMu.Unlock()
+	balanceBackend = backend
+}
+
+// HoldBalance reserves amount for one request with the configured backend.
+func HoldBalance(keyID int, balance float64, amount float64) (*BalanceHold, bool) {
+	balanceBackendMu.RLock()
+	backend := balanceBackend
+	balanceBackendMu.RUnlock()
+	return backend.Hold(keyID, balance, amount)
+}
+
+// BalanceHold is balance set aside for one in-flight request.
+type BalanceHold struct {
+	keyID   int
+	amount  float64
+	ledger  *BalanceLedger
+	release func()
+	once    sync.Once
+}
+
+func (h *BalanceHold) Amount() float64 {
+	if h == nil {
+		return 0
+	}
+	return h.amount
+}
+
+// Settle releases the hold and charges actual spend against the available balance until
+// it is flushed to the database. Only the first call has an effect.
+func (h *BalanceHold) Settle(actual float64) {
+	if h == nil {
+		return
+	}
+	h.once.Do(func() {
+		if h.release != nil {
+			h.release()
+		}
+		h.ledger.Charge(h.keyID, actual)
+	})
+}
+
+// SettleBalanceHold settles the request's hold with the spend recorded for it, or releases
+// it when the request produced none.
+func SettleBalanceHold(c *gin.Context) {
+	value, ok := c.Get(ContextBalanceHold)
+	if !ok {
+		return
+	}
+	hold, ok := value.(*BalanceHold)
+	if !ok {
+		return
+	}
+	amount, _ := SpendFromContext(c)
+	hold.Settle(amount)
+}
+
+// BalanceLedger derives each key's available balance from the balance last read from the
+// database, spend settled but not yet flushed to it, and holds of in-flight requests.
+type BalanceLedger struct {
+	mu   sync.Mutex
+	keys map[int]*keyBalance
+}
+
+type keyBalance struct {
+	balance   float64
+	synced    bool
+	unflushed float64
+	held      float64
+}
+
+func NewBalanceLedger() *BalanceLedger {
+	return &BalanceLedger{keys: make(map[int]*keyBalance)}
+}
+
+func (l *BalanceLedger) entryLocked(keyID int) *keyBalance {
+	entry, ok := l.keys[keyID]
+	if !ok {
+		entry = &keyBalance{}
+		l.keys[keyID] = entry
+	}
+	return entry
+}
+
+// Sync records the key balance just read from the database.
+func (l *BalanceLedger) Sync(keyID int, balance float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := l.entryLocked(keyID)
+	entry.balance = balance
+	entry.synced = true
+}
+
+func (l *BalanceLedger) Hold(keyID int, balance float64, amount float64) (*BalanceHold, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := l.entryLocked(keyID)
+	if !entry.synced {
+		entry.balance = balance
+		entry.synced = true
+	}
+	available := entry.balance - entry.unflushed - entry.held
+	if available <= 0 || amount > available {
+		return nil, false
+	}
+	entry.held += amount
+	return &BalanceHold{
+		keyID:  keyID,
+		amount: amount,
+		ledger: l,
+		release: func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			entry := l.entryLocked(keyID)
+			entry.held = max(entry.held-amount, 0)
+		},
+	}, true
+}
+
+// Charge records spend that has not reached the database balance yet.
+func (l *BalanceLedger) Charge(keyID int, amount float64) {
+	if amount <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entryLocked(keyID).unflushed += amount
+}
+
+// Flushed records that amount was deducted from the database balance, keeping the local
+// balance in step until the next Sync.
+func (l *BalanceLedger) Flushed(keyID int, amount float64) {
+	if amount <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := l.entryLocked(keyID)
+	entry.balance -= amount
+	entry.unflushed = max(entry.unflushed-amount, 0)
+}
+
+// Unflushed returns keyID's settled spend not yet deducted in the database.
+func (l *BalanceLedger) Unflushed(keyID int) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.keys[keyID]; ok {
+		return entry.unflushed
+	}
+	return 0
+}
+
+// Available returns keyID's balance net of unflushed spend and holds, if it is known.
+func (l *BalanceLedger) Available(keyID int) (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.keys[keyID]
+	if !ok || !entry.synced {
+		return 0, false
+	}
+	return entry.balance - entry.unflushed - entry.held, true
+}
+
+// Remove forgets keyID once nothing is held or waiting to be flushed for it.
+func (l *BalanceLedger) Remove(keyID int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.keys[keyID]; ok && entry.held == 0 && entry.unflushed == 0 {
+		delete(l.keys, keyID)
+	}
+}
@@ -0,0 +1,118 @@
+package spend
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+)
+
+// balanceHoldTTL bounds how long a hold outlives a replica that died before settling it.
+const balanceHoldTTL = time.Hour
+
+// PostgresBalanceBackend keeps holds in janus_balance_hold so that every replica pointing at
+// the same database checks one shared available balance. Spend a replica has settled but not
+// flushed yet is only known locally and is subtracted on top. When the database is
+// unreachable, holds fall back to the local ledger.
+type PostgresBalanceBackend struct {
+	connect func() (*gorm.DB, error)
+	ledger  *BalanceLedger
+
+	mu        sync.Mutex
+	db        *gorm.DB
+	lastPrune time.Time
+}
+
+func NewPostgresBalanceBackend() *PostgresBalanceBackend {
+	return &PostgresBalanceBackend{
+		connect: janusDb.ConnectDatabase,
+		ledger:  Balances,
+	}
+}
+
+func (b *PostgresBalanceBackend) database() (*gorm.DB, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.db != nil {
+		return b.db, nil
+	}
+	db, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	b.db = db
+	return db, nil
+}
+
+func (b *PostgresBalanceBackend) Hold(keyID int, balance float64, amount float64) (*BalanceHold, bool) {
+	db, err := b.database()
+	if err != nil {
+		log.Printf("PostgresBalanceBackend: connect database failed, using local ledger: %v", err)
+		return b.ledger.Hold(keyID, balance, amount)
+	}
+
+	now := time.Now()
+	unflushed := b.ledger.Unflushed(keyID)
+	var holdID int64
+	var current float64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Locking the key row serializes holds for one key across replicas.
+		if err := tx.Raw("SELECT balance FROM janus_auth_key WHERE key_id = ? FOR UPDATE", keyID).Scan(&current).Error; err != nil {
+			return err
+		}
+		var held float64
+		if err := tx.Raw("SELECT COALESCE(SUM(amount), 0) FROM janus_balance_hold WHERE key_id = ? AND expire_time > ?", keyID, now).
+			Scan(&held).Error; err != nil {
+			return err
+		}
+		available := current - unflushed - held
+		if available <= 0 || amount > available {
+			return nil
+		}
+		return tx.Raw("INSERT INTO janus_balance_hold (key_id, amount, expire_time) VALUES (?, ?, ?) RETURNING hold_id",
+			keyID, amount, now.Add(balanceHoldTTL)).Scan(&holdID).Error
+	})
+	if err != nil {
+		log.Printf("PostgresBalanceBackend: hold failed, using local ledger: %v", err)
+		return b.ledger.Hold(keyID, balance, amount)
+	}
+	b.ledger.Sync(keyID, current)
+	b.maybePrune(db, now)
+	if holdID == 0 {
+		return nil, false
+	}
+
+	return &BalanceHold{
+		keyID:  keyID,
+		amount: amount,
+		ledger: b.ledger,
+		// Releasing runs in the background so that settling adds no database round trip to
+		// the response; a hold whose delete fails lapses at its expire_time.
+		release: func() {
+			go func() {
+				if err := db.Exec("DELETE FROM janus_balance_hold WHERE hold_id = ?", holdID).Error; err != nil {
+					log.Printf("PostgresBalanceBackend: release hold %d failed: %v", holdID, err)
+				}
+			}()
+		},
+	}, true
+}
+
+func (b *PostgresBalanceBackend) maybePrune(db *gorm.DB, now time.Time) {
+	b.mu.Lock()
+	if now.Sub(b.lastPrune) < time.Minute {
+		b.mu.Unlock()
+		return
+	}
+	b.lastPrune = now
+	b.mu.Unlock()
+
+	go func() {
+		if err := db.Exec("DELETE FROM janus_balance_hold WHERE expire_time <= ?", now).Error; err != nil {
+			log.Printf("PostgresBalanceBackend: prune holds failed: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,65 @@
+package spend
+
+import "testing"
+
+func TestBalanceLedgerHoldRejectsBeyondAvailable(t *testing.T) {
+	ledger := NewBalanceLedger()
+
+	first, ok := ledger.Hold(1, 1, 0.6)
+	if !ok {
+		t.Fatal("expected the first hold to fit the balance")
+	}
+	if _, ok := ledger.Hold(1, 1, 0.6); ok {
+		t.Fatal("expected a concurrent hold beyond the remaining balance to be rejected")
+	}
+
+	first.Settle(0)
+	if _, ok := ledger.Hold(1, 1, 0.6); !ok {
+		t.Fatal("expected the released hold to free its balance")
+	}
+}
+
+func TestBalanceLedgerSettleChargesUntilFlushed(t *testing.T) {
+	ledger := NewBalanceLedger()
+	ledger.Sync(1, 1)
+
+	hold, ok := ledger.Hold(1, 1, 0.5)
+	if !ok {
+		t.Fatal("expected hold to succeed")
+	}
+	hold.Settle(0.2)
+	hold.Settle(0.2)
+	if got, _ := ledger.Available(1); got != 0.8 {
+		t.Fatalf("expected settled spend to be charged once, available %v", got)
+	}
+
+	ledger.Flushed(1, 0.2)
+	if got, _ := ledger.Available(1); got != 0.8 {
+		t.Fatalf("expected flushing to keep available balance, got %v", got)
+	}
+	if got := ledger.Unflushed(1); got != 0 {
+		t.Fatalf("expected nothing left to flush, got %v", got)
+	}
+
+	// A database read taken before the flush must not hide spend still waiting to flush.
+	ledger.Charge(1, 0.3)
+	ledger.Sync(1, 0.8)
+	if got, _ := ledger.Available(1); got != 0.5 {
+		t.Fatalf("expected unflushed spend to survive Sync, got %v", got)
+	}
+}
+
+func TestBalanceLedgerRemoveKeepsPendingKeys(t *testing.T) {
+	ledger := NewBalanceLedger()
+	ledger.Sync(1, 1)
+	ledger.Charge(1, 0.1)
+	ledger.Remove(1)
+	if _, known := ledger.Available(1); !known {
+		t.Fatal("expected a key with unflushed spend to be kept")
+	}
+	ledger.Flushed(1, 0.1)
+	ledger.Remove(1)
+	if _, known := ledger.Available(1); known {
+		t.Fatal("expected an idle key to be removed")
+	}
+}
@@ -0,0 +1,153 @@
+package spend
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// keySpendDailySummary names the janus_summary_state row that tracks the last spend record
+// folded into janus_key_spend_daily.
+const keySpendDailySummary = "key_spend_daily"
+
+// keySpendDailyFoldBatch bounds the record ids folded per transaction, so the first run
+// backfills history in short transactions instead of one long one.
+const keySpendDailyFoldBatch = 50000
+
+// RefreshKeySpendDaily adds the spend records written since the previous run to the
+// janus_key_spend_daily totals of their UTC day and returns the number of summary rows updated.
+// Each batch locks the summary state row, so replicas refreshing at the same time take turns
+// and never fold a record twice.
+func RefreshKeySpendDaily(db *gorm.DB) (int64, error) {
+	var updated int64
+	for {
+		rows, more, err := foldKeySpendDailyBatch(db)
+		if err != nil {
+			return updated, err
+		}
+		updated += rows
+		if !more {
+			return updated, nil
+		}
+	}
+}
+
+func foldKeySpendDailyBatch(db *gorm.DB) (updated int64, more bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		watermark, err := lockKeySpendDailyWatermark(tx)
+		if err != nil {
+			return err
+		}
+		var latest int64
+		if err := tx.Raw("SELECT COALESCE(MAX(record_id), 0) FROM janus_spend_log").Scan(&latest).Error; err != nil {
+			return err
+		}
+		if latest <= watermark {
+			return nil
+		}
+		upTo := latest
+		if upTo-watermark > keySpendDailyFoldBatch {
+			upTo = watermark + keySpendDailyFoldBatch
+			more = true
+		}
+
+		result := tx.Exec(`INSERT INTO janus_key_spend_daily (
+				summary_date, key_id, key_content, team_id, organization_id, total_spend, prompt_tokens,
+				completion_tokens, total_tokens, request_count, total_latency_ms, cache_hit_count
+			)
+			SELECT (create_time AT TIME ZONE 'UTC')::date, key_id, MAX(key_content), MAX(team_id), MAX(organization_id),
+				SUM(spend), SUM(prompt_tokens), SUM(completion_tokens), SUM(total_tokens), COUNT(*), SUM(latency_ms),
+				COUNT(*) FILTER (WHERE cache_hit)
+			FROM janus_spend_log
+			WHERE record_id > ? AND record_id <= ?
+			GROUP BY 1, key_id
+			ON CONFLICT (summary_date, key_id) DO UPDATE SET
+				key_content = EXCLUDED.key_content,
+				team_id = EXCLUDED.team_id,
+				organization_id = EXCLUDED.organization_id,
+				total_spend = janus_key_spend_daily.total_spend + EXCLUDED.total_spend,
+				prompt_tokens = janus_key_spend_daily.prompt_tokens + EXCLUDED.prompt_tokens,
+				completion_tokens = janus_key_spend_daily.completion_tokens + EXCLUDED.completion_tokens,
+				total_tokens = janus_key_spend_daily.total_tokens + EXCLUDED.total_tokens,
+				request_count = janus_key_spend_daily.request_count + EXCLUDED.request_count,
+				total_latency_ms = janus_key_spend_daily.total_latency_ms + EXCLUDED.total_latency_ms,
+				cache_hit_count = janus_key_spend_daily.cache_hit_count + EXCLUDED.cache_hit_count`,
+			watermark, upTo)
+		if result.Error != nil {
+			return result.Error
+		}
+		updated = result.RowsAffected
+		return setKeySpendDailyWatermark(tx, upTo)
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return updated, more, nil
+}
+
+// ReconcileKeySpendDaily rebuilds the janus_key_spend_daily rows of today and yesterday (UTC)
+// from the spend log and returns the days rebuilt. A record whose transaction commits after
+// a later record id was folded is skipped by RefreshKeySpendDaily; this catches it. It is
+// meant to run occasionally, not on every refresh.
+func ReconcileKeySpendDaily(db *gorm.DB, now time.Time) ([]string, error) {
+	dates := recentSummaryDates(now)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		watermark, err := lockKeySpendDailyWatermark(tx)
+		if err != nil {
+			return err
+		}
+		for _, date := range dates {
+			if err := rebuildKeySpendDay(tx, date, watermark); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dates, nil
+}
+
+// recentSummaryDates returns yesterday and today (UTC), oldest first.
+func recentSummaryDates(now time.Time) []string {
+	today := now.UTC()
+	return []string{today.AddDate(0, 0, -1).Format(time.DateOnly), today.Format(time.DateOnly)}
+}
+
+func lockKeySpendDailyWatermark(tx *gorm.DB) (int64, error) {
+	if err := tx.Exec("INSERT INTO janus_summary_state (summary_name) VALUES (?) ON CONFLICT (summary_name) DO NOTHING",
+		keySpendDailySummary).Error; err != nil {
+		return 0, err
+	}
+	var watermark int64
+	err := tx.Raw("SELECT last_record_id FROM janus_summary_state WHERE summary_name = ? FOR UPDATE",
+		keySpendDailySummary).Scan(&watermark).Error
+	return watermark, err
+}
+
+func setKeySpendDailyWatermark(tx *gorm.DB, recordId int64) error {
+	return tx.Exec("UPDATE janus_summary_state SET last_record_id = ?, update_time = NOW() WHERE summary_name = ?",
+		recordId, keySpendDailySummary).Error
+}
+
+// rebuildKeySpendDay recomputes one day from the records up to the watermark; later records
+// are left for RefreshKeySpendDaily to fold, so none is counted twice.
+func rebuildKeySpendDay(tx *gorm.DB, date string, watermark int64) error {
+	start, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM janus_key_spend_daily WHERE summary_date = ?", date).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO janus_key_spend_daily (
+			summary_date, key_id, key_content, team_id, organization_id, total_spend, prompt_tokens,
+			completion_tokens, total_tokens, request_count, total_latency_ms, cache_hit_count
+		)
+		SELECT ?::date, key_id, MAX(key_content), MAX(team_id), MAX(organization_id), SUM(spend), SUM(prompt_tokens),
+			SUM(completion_tokens), SUM(total_tokens), COUNT(*), SUM(latency_ms), COUNT(*) FILTER (WHERE cache_hit)
+		FROM janus_spend_log
+		WHERE create_time >= ? AND create_time < ? AND record_id <= ?
+		GROUP BY key_id`, date, start, start.AddDate(0, 0, 1), watermark).Error
+}
@@ -0,0 +1,386 @@
+package spend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+	"github.com/Uuq114/JanusLLM/internal/metrics"
+)
+
+const (
+	defaultSpoolDir          = "data/spend-spool"
+	defaultSpendBatchSize    = 500
+	defaultSpendFlushSeconds = 5
+	defaultSpendMaxBackoff   = time.Minute
+	spendMinBackoff          = time.Second
+	// maxSpendBatchAttempts is how often a batch may fail as a whole before its records are
+	// written one by one, so that a record the database rejects cannot block the spool.
+	maxSpendBatchAttempts = 5
+)
+
+// PipelineConfig controls how spend records travel from requests to the database.
+type PipelineConfig struct {
+	// SpoolDir holds the write-ahead log; records survive restarts until they are written.
+	SpoolDir string `yaml:"spool_dir"`
+	// BatchSize caps the records written per transaction; a full batch is flushed right away.
+	BatchSize int `yaml:"batch_size"`
+	// FlushIntervalSeconds is the longest a record waits before it is written.
+	FlushIntervalSeconds int `yaml:"flush_interval_seconds"`
+	// MaxBackoffSeconds caps the wait between attempts while the database is failing.
+	MaxBackoffSeconds int `yaml:"max_backoff_seconds"`
+}
+
+// SpendSink accepts spend records for persistence.
+type SpendSink interface {
+	Enqueue(record SpendRecord) error
+}
+
+// SpendStore persists batches of spend records.
+type SpendStore interface {
+	// WriteSpend inserts the records not written before and deducts their spend from key
+	// balances in one transaction, returning the spend applied per key.
+	WriteSpend(records []SpendRecord) (map[int]float64, error)
+}
+
+// Pipeline spools spend records to disk when a request finishes and writes them to the
+// database in batches in the background, retrying with backoff until they are stored.
+// Enqueue never waits on the database.
+type Pipeline struct {
+	store         SpendStore
+	spool         *spool
+	batchSize     int
+	flushInterval time.Duration
+	maxBackoff    time.Duration
+
+	instance string
+	sequence atomic.Uint64
+	flush    chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	closeCtx context.Context
+	stopOnce sync.Once
+
+	// memory holds records the spool could not write, e.g. on a full disk.
+	memoryMu sync.Mutex
+	memory   []SpendRecord
+
+	attempts int
+}
+
+func NewPipeline(cfg PipelineConfig, store SpendStore) (*Pipeline, error) {
+	if cfg.SpoolDir == "" {
+		cfg.SpoolDir = defaultSpoolDir
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultSpendBatchSize
+	}
+	if cfg.FlushIntervalSeconds <= 0 {
+		cfg.FlushIntervalSeconds = defaultSpendFlushSeconds
+	}
+	maxBackoff := defaultSpendMaxBackoff
+	if cfg.MaxBackoffSeconds > 0 {
+		maxBackoff = time.Duration(cfg.MaxBackoffSeconds) * time.Second
+	}
+
+	s, err := openSpool(cfg.SpoolDir)
+	if err != nil {
+		return nil, err
+	}
+	instance := make([]byte, 8)
+	if _, err := rand.Read(instance); err != nil {
+		return nil, fmt.Errorf("generate spool instance id: %w", err)
+	}
+	return &Pipeline{
+		store:         store,
+		spool:         s,
+		batchSize:     cfg.BatchSize,
+		flushInterval: time.Duration(cfg.FlushIntervalSeconds) * time.Second,
+		maxBackoff:    maxBackoff,
+		instance:      hex.EncodeToString(instance),
+		flush:         make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// Enqueue records spend for writing. The record is on disk when Enqueue returns; if the
+// spool cannot be written it is kept in memory instead and the error is returned.
+func (p *Pipeline) Enqueue(record SpendRecord) error {
+	if record.SpoolId == "" {
+		record.SpoolId = fmt.Sprintf("%s-%d", p.instance, p.sequence.Add(1))
+	}
+	if record.CreateTime.IsZero() {
+		record.CreateTime = time.Now()
+	}
+	count, err := p.spool.append(record)
+	if err != nil {
+		p.memoryMu.Lock()
+		p.memory = append(p.memory, record)
+		p.memoryMu.Unlock()
+		p.requestFlush()
+		return fmt.Errorf("spool spend record: %w", err)
+	}
+	if count >= p.batchSize {
+		p.requestFlush()
+	}
+	return nil
+}
+
+func (p *Pipeline) requestFlush() {
+	select {
+	case p.flush <- struct{}{}:
+	default:
+	}
+}
+
+// Backlog returns the records and spooled bytes not yet written to the database.
+func (p *Pipeline) Backlog() (int, int64) {
+	records, size := p.spool.backlog()
+	p.memoryMu.Lock()
+	records += len(p.memory)
+	p.memoryMu.Unlock()
+	return records, size
+}
+
+// Run writes spooled records until Close is called, then drains what is left.
+func (p *Pipeline) Run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-p.stop:
+			p.drain()
+			return
+		case <-retry:
+		case <-ticker.C:
+			if retry != nil {
+				continue
+			}
+		case <-p.flush:
+			// While backing off, the retry timer fires the next attempt.
+			if retry != nil {
+				continue
+			}
+		}
+		retry = nil
+		if !p.writeAll() {
+			retry = time.After(p.backoff())
+		}
+	}
+}
+
+// drain keeps writing until the backlog is empty or the Close context ends.
+func (p *Pipeline) drain() {
+	for !p.writeAll() {
+		select {
+		case <-p.closeCtx.Done():
+			records, _ := p.Backlog()
+			log.Printf("spend pipeline: stopping with %d records unwritten; spooled records are replayed on restart", records)
+			return
+		case <-time.After(p.backoff()):
+		}
+	}
+}
+
+// Close stops the pipeline after writing the backlog, giving up when ctx ends. Records still
+// in the spool are written after the next start.
+func (p *Pipeline) Close(ctx context.Context) {
+	p.stopOnce.Do(func() {
+		p.closeCtx = ctx
+		close(p.stop)
+	})
+	<-p.done
+	p.spool.close()
+}
+
+func (p *Pipeline) backoff() time.Duration {
+	wait := spendMinBackoff << min(max(p.attempts-1, 0), 16)
+	return min(wait, p.maxBackoff)
+}
+
+// writeAll seals the active segment and writes every pending batch, reporting whether the
+// backlog is empty.
+func (p *Pipeline) writeAll() bool {
+	p.memoryMu.Lock()
+	memory := p.memory
+	p.memory = nil
+	p.memoryMu.Unlock()
+	for len(memory) > 0 {
+		batch := memory[:min(len(memory), p.batchSize)]
+		if err := p.write(batch); err != nil {
+			p.memoryMu.Lock()
+			p.memory = append(memory, p.memory...)
+			p.memoryMu.Unlock()
+			return false
+		}
+		memory = memory[len(batch):]
+	}
+
+	p.spool.seal()
+	for {
+		segment, ok := p.spool.oldest()
+		if !ok {
+			return true
+		}
+		if !p.writeSegment(segment) {
+			return false
+		}
+	}
+}
+
+func (p *Pipeline) writeSegment(segment spoolSegment) bool {
+	records, err := p.spool.read(segment)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("spend pipeline: segment %d disappeared from the spool", segment.seq)
+		return p.spool.remove(segment) == nil
+	}
+	if err != nil {
+		log.Printf("spend pipeline: read segment %d failed: %v", segment.seq, err)
+		return false
+	}
+	for start := 0; start < len(records); start += p.batchSize {
+		batch := records[start:min(start+p.batchSize, len(records))]
+		if err := p.write(batch); err == nil {
+			continue
+		}
+		if p.attempts < maxSpendBatchAttempts {
+			return false
+		}
+		if !p.writeEach(segment, batch) {
+			return false
+		}
+	}
+	if err := p.spool.remove(segment); err != nil {
+		// The records are stored; replaying the segment later is deduplicated by spool id.
+		log.Printf("spend pipeline: remove segment %d failed: %v", segment.seq, err)
+		return false
+	}
+	return true
+}
+
+// writeEach isolates records the database rejects on their own. If none can be written the
+// database itself is failing and the batch is kept.
+func (p *Pipeline) writeEach(segment spoolSegment, batch []SpendRecord) bool {
+	var rejected []SpendRecord
+	for _, record := range batch {
+		if err := p.write([]SpendRecord{record}); err != nil {
+			rejected = append(rejected, record)
+		}
+	}
+	if len(rejected) == len(batch) {
+		return false
+	}
+	if len(rejected) > 0 {
+		if err := p.spool.reject(segment, rejected); err != nil {
+			log.Printf("spend pipeline: set aside %d rejected records failed: %v", len(rejected), err)
+			return false
+		}
+		metrics.AddSpendRecordsRejected(len(rejected))
+		log.Printf("spend pipeline: set aside %d records the database rejected in segment %d", len(rejected), segment.seq)
+	}
+	p.attempts = 0
+	return true
+}
+
+func (p *Pipeline) write(batch []SpendRecord) error {
+	applied, err := p.store.WriteSpend(batch)
+	if err != nil {
+		p.attempts++
+		metrics.IncSpendFlush("error")
+		log.Printf("spend pipeline: write %d records failed (attempt %d): %v", len(batch), p.attempts, err)
+		return err
+	}
+	p.attempts = 0
+	metrics.IncSpendFlush("ok")
+	for keyID, amount := range applied {
+		Balances.Flushed(keyID, amount)
+	}
+	return nil
+}
+
+type postgresSpendStore struct{}
+
+// NewPostgresSpendStore writes spend to janus_spend_log and janus_auth_key.
+func NewPostgresSpendStore() SpendStore {
+	return postgresSpendStore{}
+}
+
+func (postgresSpendStore) WriteSpend(records []SpendRecord) (map[int]float64, error) {
+	db, err := janusDb.ConnectDatabase()
+	if err != nil {
+		return nil, fmt.Errorf("connect database: %w", err)
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	var applied map[int]float64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]string, 0, len(records))
+		for _, record := range records {
+			ids = append(ids, record.SpoolId)
+		}
+		var written []string
+		if err := tx.Table("janus_spend_log").Where("spool_id IN ?", ids).Pluck("spool_id", &written).Error; err != nil {
+			return err
+		}
+		seen := make(map[string]struct{}, len(written))
+		for _, id := range written {
+			seen[id] = struct{}{}
+		}
+		fresh := make([]SpendRecord, 0, len(records))
+		for _, record := range records {
+			if _, ok := seen[record.SpoolId]; ok {
+				continue
+			}
+			seen[record.SpoolId] = struct{}{}
+			fresh = append(fresh, record)
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		if err := tx.Table("janus_spend_log").Create(&fresh).Error; err != nil {
+			return err
+		}
+
+		totals := make(map[int]float64)
+		for _, record := range fresh {
+			totals[record.KeyId] += record.Spend
+		}
+		// Updating keys in a fixed order keeps concurrent replicas from deadlocking.
+		keyIDs := make([]int, 0, len(totals))
+		for keyID := range totals {
+			keyIDs = append(keyIDs, keyID)
+		}
+		sort.Ints(keyIDs)
+		for _, keyID := range keyIDs {
+			if err := tx.Table("janus_auth_key").
+				Where("key_id = ?", keyID).
+				Updates(map[string]interface{}{
+					"balance":     gorm.Expr("balance - ?", totals[keyID]),
+					"total_spend": gorm.Expr("total_spend + ?", totals[keyID]),
+				}).Error; err != nil {
+				return err
+			}
+		}
+		applied = totals
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("write spend batch: %w", err)
+	}
+	return applied, nil
+}
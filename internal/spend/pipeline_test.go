@@ -0,0 +1,211 @@
+package spend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSpendStore struct {
+	mu       sync.Mutex
+	failures int
+	reject   func(SpendRecord) bool
+	written  map[string]SpendRecord
+}
+
+func (s *fakeSpendStore) WriteSpend(records []SpendRecord) (map[int]float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return nil, errors.New("database unavailable")
+	}
+	for _, record := range records {
+		if s.reject != nil && s.reject(record) {
+			return nil, errors.New("foreign key violation")
+		}
+	}
+	if s.written == nil {
+		s.written = make(map[string]SpendRecord)
+	}
+	applied := make(map[int]float64)
+	for _, record := range records {
+		if _, ok := s.written[record.SpoolId]; ok {
+			continue
+		}
+		s.written[record.SpoolId] = record
+		applied[record.KeyId] += record.Spend
+	}
+	return applied, nil
+}
+
+func (s *fakeSpendStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.written)
+}
+
+func TestPipelineRetriesUntilDatabaseRecovers(t *testing.T) {
+	store := &fakeSpendStore{failures: 2}
+	pipeline, err := NewPipeline(PipelineConfig{SpoolDir: t.TempDir()}, store)
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := pipeline.Enqueue(SpendRecord{KeyId: 1, Spend: 0.5}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if pipeline.writeAll() {
+			t.Fatalf("attempt %d: expected the failing database to keep the backlog", attempt)
+		}
+		if records, _ := pipeline.Backlog(); records != 3 {
+			t.Fatalf("attempt %d: expected 3 records in the backlog, got %d", attempt, records)
+		}
+	}
+	if !pipeline.writeAll() {
+		t.Fatal("expected the backlog to be written once the database recovers")
+	}
+	if store.count() != 3 {
+		t.Fatalf("expected 3 records written, got %d", store.count())
+	}
+	if records, size := pipeline.Backlog(); records != 0 || size != 0 {
+		t.Fatalf("expected an empty backlog, got %d records, %d bytes", records, size)
+	}
+}
+
+func TestPipelineEnqueueGroupCommitsConcurrentRecords(t *testing.T) {
+	dir := t.TempDir()
+	pipeline, err := NewPipeline(PipelineConfig{SpoolDir: dir}, &fakeSpendStore{})
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+	defer pipeline.spool.close()
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pipeline.Enqueue(SpendRecord{KeyId: 1, Spend: 1}); err != nil {
+				t.Errorf("Enqueue: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every record is in the segment file once Enqueue returns, without sealing it first.
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt))
+	if len(segments) != 1 {
+		t.Fatalf("expected one active segment, got %v", segments)
+	}
+	data, err := os.ReadFile(segments[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != writers {
+		t.Fatalf("expected %d records on disk, got %d", writers, lines)
+	}
+	if records, _ := pipeline.Backlog(); records != writers {
+		t.Fatalf("expected a backlog of %d records, got %d", writers, records)
+	}
+}
+
+func TestPipelineReplaysSpoolAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	crashed, err := NewPipeline(PipelineConfig{SpoolDir: dir}, &fakeSpendStore{})
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+	if err := crashed.Enqueue(SpendRecord{KeyId: 1, RequestId: "a", Spend: 1}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := crashed.Enqueue(SpendRecord{KeyId: 1, RequestId: "b", Spend: 2}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	// Simulate a crash mid-write by leaving a torn record behind.
+	crashed.spool.mu.Lock()
+	_, _ = crashed.spool.active.WriteString(`{"KeyId":1,"Spe`)
+	crashed.spool.mu.Unlock()
+
+	store := &fakeSpendStore{}
+	restarted, err := NewPipeline(PipelineConfig{SpoolDir: dir}, store)
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+	if records, _ := restarted.Backlog(); records != 2 {
+		t.Fatalf("expected 2 spooled records to be found, got %d", records)
+	}
+	if err := restarted.Enqueue(SpendRecord{KeyId: 1, RequestId: "c", Spend: 3}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if !restarted.writeAll() {
+		t.Fatal("expected the replay to succeed")
+	}
+	if store.count() != 3 {
+		t.Fatalf("expected the spooled and new records to be written, got %d", store.count())
+	}
+}
+
+func TestPipelineSetsAsideRejectedRecords(t *testing.T) {
+	dir := t.TempDir()
+	store := &fakeSpendStore{reject: func(record SpendRecord) bool { return record.KeyId == 404 }}
+	pipeline, err := NewPipeline(PipelineConfig{SpoolDir: dir}, store)
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+	for _, keyID := range []int{1, 404, 2} {
+		if err := pipeline.Enqueue(SpendRecord{KeyId: keyID, Spend: 1}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	written := false
+	for attempt := 0; attempt <= maxSpendBatchAttempts && !written; attempt++ {
+		written = pipeline.writeAll()
+	}
+	if !written {
+		t.Fatal("expected the rejected record not to block the spool")
+	}
+	if store.count() != 2 {
+		t.Fatalf("expected the other records to be written, got %d", store.count())
+	}
+	rejected, _ := filepath.Glob(filepath.Join(dir, "*"+spoolRejectedExt))
+	if len(rejected) != 1 {
+		t.Fatalf("expected one rejected file, got %v", rejected)
+	}
+}
+
+func TestPipelineCloseDrainsBacklog(t *testing.T) {
+	store := &fakeSpendStore{}
+	pipeline, err := NewPipeline(PipelineConfig{SpoolDir: t.TempDir(), FlushIntervalSeconds: 3600}, store)
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+	go pipeline.Run()
+	if err := pipeline.Enqueue(SpendRecord{KeyId: 1, Spend: 1}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pipeline.Close(ctx)
+	if store.count() != 1 {
+		t.Fatalf("expected Close to write the backlog, got %d records", store.count())
+	}
+	entries, err := os.ReadDir(pipeline.spool.dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected an empty spool after draining, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,116 @@
+package spend
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+// Price breakdown item kinds.
+const (
+	PriceInput       = "input"
+	PriceCachedInput = "cached_input"
+	PriceCacheWrite  = "cache_write"
+	PriceImageInput  = "image_input"
+	PriceAudioInput  = "audio_input"
+	PriceOutput      = "output"
+	PriceReasoning   = "reasoning"
+	PriceImageOutput = "image_output"
+	PriceAudioOutput = "audio_output"
+)
+
+// PriceBreakdown records how a spend record's cost was reached. It is stored as JSON in
+// janus_spend_log.price_breakdown.
+type PriceBreakdown struct {
+	// Endpoint names the serving endpoint when its pricing applied instead of the group's.
+	Endpoint string `json:"endpoint,omitempty"`
+	// TierAbovePromptTokens is the threshold of the context-length tier that applied.
+	TierAbovePromptTokens int `json:"tier_above_prompt_tokens,omitempty"`
+	// CostMultiplier scaled the item costs, e.g. for a gateway cache hit.
+	CostMultiplier *float64    `json:"cost_multiplier,omitempty"`
+	Items          []PriceItem `json:"items"`
+}
+
+type PriceItem struct {
+	Kind     string  `json:"kind"`
+	Tokens   int     `json:"tokens"`
+	PerToken float64 `json:"per_token"`
+	Cost     float64 `json:"cost"`
+}
+
+// PriceUsage bills usage at pricing. Detail token counts are billed at their own rates and
+// the rest of the prompt and completion at the plain input and output rates.
+func PriceUsage(pricing models.Pricing, usage TokenUsage) (float64, PriceBreakdown) {
+	rates, tier := pricing.RatesFor(usage.PromptTokens)
+	breakdown := PriceBreakdown{TierAbovePromptTokens: tier, Items: []PriceItem{}}
+	var total float64
+	add := func(kind string, tokens int, perToken float64) {
+		if tokens <= 0 {
+			return
+		}
+		cost := float64(tokens) * perToken
+		breakdown.Items = append(breakdown.Items, PriceItem{Kind: kind, Tokens: tokens, PerToken: perToken, Cost: cost})
+		total += cost
+	}
+
+	input := usage.PromptTokens - usage.CachedTokens - usage.CacheCreationTokens - usage.ImageInputTokens - usage.AudioInputTokens
+	add(PriceInput, max(input, 0), rates.InputPerToken)
+	add(PriceCachedInput, usage.CachedTokens, rateOr(rates.CachedInputPerToken, rates.InputPerToken))
+	add(PriceCacheWrite, usage.CacheCreationTokens, rateOr(rates.CacheWritePerToken, rates.InputPerToken))
+	add(PriceImageInput, usage.ImageInputTokens, rateOr(rates.ImageInputPerToken, rates.InputPerToken))
+	add(PriceAudioInput, usage.AudioInputTokens, rateOr(rates.AudioInputPerToken, rates.InputPerToken))
+
+	output := usage.CompletionTokens - usage.ReasoningTokens - usage.ImageOutputTokens - usage.AudioOutputTokens
+	add(PriceOutput, max(output, 0), rates.OutputPerToken)
+	add(PriceReasoning, usage.ReasoningTokens, rateOr(rates.ReasoningPerToken, rates.OutputPerToken))
+	add(PriceImageOutput, usage.ImageOutputTokens, rateOr(rates.ImageOutputPerToken, rates.OutputPerToken))
+	add(PriceAudioOutput, usage.AudioOutputTokens, rateOr(rates.AudioOutputPerToken, rates.OutputPerToken))
+	return total, breakdown
+}
+
+// scale applies a cost multiplier to every item and returns the new total.
+func (b *PriceBreakdown) scale(factor float64) float64 {
+	b.CostMultiplier = &factor
+	var total float64
+	for i := range b.Items {
+		b.Items[i].Cost *= factor
+		total += b.Items[i].Cost
+	}
+	return total
+}
+
+func rateOr(rate *float64, fallback float64) float64 {
+	if rate != nil {
+		return *rate
+	}
+	return fallback
+}
+
+func (b PriceBreakdown) Value() (driver.Value, error) {
+	if len(b.Items) == 0 && b.Endpoint == "" {
+		return nil, nil
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (b *PriceBreakdown) Scan(value interface{}) error {
+	*b = PriceBreakdown{}
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unsupported data type: %T", value)
+	}
+	return json.Unmarshal(data, b)
+}
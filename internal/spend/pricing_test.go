@@ -0,0 +1,104 @@
+package spend
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Uuq114/JanusLLM/internal/auth"
+	"github.com/Uuq114/JanusLLM/internal/models"
+)
+
+func rate(value float64) *float64 {
+	return &value
+}
+
+func TestPriceUsageBillsDetailTokensAndTiers(t *testing.T) {
+	pricing := models.Pricing{
+		PriceRates: models.PriceRates{
+			InputPerToken:       2,
+			OutputPerToken:      10,
+			CachedInputPerToken: rate(0.5),
+			ReasoningPerToken:   rate(20),
+		},
+		Tiers: []models.PricingTier{
+			{AbovePromptTokens: 1000, PriceRates: models.PriceRates{InputPerToken: 4, OutputPerToken: 15}},
+			{AbovePromptTokens: 100, PriceRates: models.PriceRates{InputPerToken: 3, OutputPerToken: 12, CachedInputPerToken: rate(1)}},
+		},
+	}
+
+	cost, breakdown := PriceUsage(pricing, TokenUsage{PromptTokens: 50, CompletionTokens: 30, CachedTokens: 20, CacheCreationTokens: 10, ReasoningTokens: 10})
+	want := []PriceItem{
+		{Kind: PriceInput, Tokens: 20, PerToken: 2, Cost: 40},
+		{Kind: PriceCachedInput, Tokens: 20, PerToken: 0.5, Cost: 10},
+		{Kind: PriceCacheWrite, Tokens: 10, PerToken: 2, Cost: 20},
+		{Kind: PriceOutput, Tokens: 20, PerToken: 10, Cost: 200},
+		{Kind: PriceReasoning, Tokens: 10, PerToken: 20, Cost: 200},
+	}
+	if cost != 470 || breakdown.TierAbovePromptTokens != 0 || !reflect.DeepEqual(breakdown.Items, want) {
+		t.Fatalf("unexpected base pricing: cost=%v breakdown=%+v", cost, breakdown)
+	}
+
+	cost, breakdown = PriceUsage(pricing, TokenUsage{PromptTokens: 200, CompletionTokens: 10, CachedTokens: 100})
+	if breakdown.TierAbovePromptTokens != 100 || cost != 100*3+100*1+10*12 {
+		t.Fatalf("expected the 100-token tier, got cost=%v breakdown=%+v", cost, breakdown)
+	}
+	if _, breakdown = PriceUsage(pricing, TokenUsage{PromptTokens: 5000}); breakdown.TierAbovePromptTokens != 1000 {
+		t.Fatalf("expected the highest exceeded tier, got %d", breakdown.TierAbovePromptTokens)
+	}
+}
+
+func TestCreateSpendRecordUsesEndpointPricing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	originalPrice := ModelPrice
+	ModelPrice = map[string][]float64{"chat-group": {0.01, 0.02}}
+	t.Cleanup(func() { ModelPrice = originalPrice })
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Set("key", auth.Key{KeyId: 42, KeyContent: "sk-abcdef123456"})
+	ctx.Set("modelGroup", "chat-group")
+	ctx.Set(ContextUpstream, "self-hosted")
+	ctx.Set(ContextPricing, &models.Pricing{PriceRates: models.PriceRates{InputPerToken: 0.001, OutputPerToken: 0.002}})
+	ctx.Set(ContextCostMultiplier, 0.5)
+	payload, _ := json.Marshal(UpstreamResp{Id: "req-1", Usage: TokenUsage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150}})
+	ctx.Set(ContextUpstreamResp, payload)
+	ch := make(chan SpendRecord, 1)
+
+	CreateSpendRecord(ctx, chanSink(ch))
+
+	got := <-ch
+	if math.Abs(got.Spend-0.1) > 1e-12 {
+		t.Fatalf("expected endpoint pricing at half cost 0.1, got %v", got.Spend)
+	}
+	breakdown := got.PriceBreakdown
+	if breakdown.Endpoint != "self-hosted" || breakdown.CostMultiplier == nil || *breakdown.CostMultiplier != 0.5 || len(breakdown.Items) != 2 {
+		t.Fatalf("unexpected breakdown %+v", breakdown)
+	}
+}
+
+func TestPriceBreakdownRoundTripsThroughDatabaseValue(t *testing.T) {
+	breakdown := PriceBreakdown{Endpoint: "a", TierAbovePromptTokens: 100, Items: []PriceItem{{Kind: PriceInput, Tokens: 3, PerToken: 1, Cost: 3}}}
+	value, err := breakdown.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	var scanned PriceBreakdown
+	if err := scanned.Scan([]byte(value.(string))); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if !reflect.DeepEqual(scanned, breakdown) {
+		t.Fatalf("expected %+v, got %+v", breakdown, scanned)
+	}
+
+	if value, err := (PriceBreakdown{}).Value(); err != nil || value != nil {
+		t.Fatalf("expected an empty breakdown to be NULL, got %v, %v", value, err)
+	}
+	if err := scanned.Scan(nil); err != nil || scanned.Endpoint != "" || scanned.Items != nil {
+		t.Fatalf("expected NULL to scan to an empty breakdown, got %+v, %v", scanned, err)
+	}
+}
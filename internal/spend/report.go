@@ -0,0 +1,330 @@
+package spend
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+)
+
+// Report dimensions accepted by ReportQuery.GroupBy.
+const (
+	GroupByKey          = "key"
+	GroupByTeam         = "team"
+	GroupByOrganization = "organization"
+	GroupByModelGroup   = "model_group"
+	GroupByProvider     = "provider"
+	GroupByDay          = "day"
+	GroupByHour         = "hour"
+)
+
+// Report sources: the raw spend log, or the per-key daily summary when it can answer.
+const (
+	ReportSourceAuto  = ""
+	ReportSourceLog   = "log"
+	ReportSourceDaily = "daily"
+)
+
+const (
+	DefaultReportLimit = 100
+	MaxReportLimit     = 1000
+)
+
+// ReportQuery selects and groups spend. Start is inclusive and End exclusive.
+type ReportQuery struct {
+	Start          time.Time
+	End            time.Time
+	GroupBy        []string
+	KeyID          int64
+	TeamID         int64
+	OrganizationID int64
+	ModelGroup     string
+	Provider       string
+	Source         string
+	Limit          int
+	Offset         int
+}
+
+// ReportRow is one group of a spend report. Dimension fields are set only when grouped by.
+type ReportRow struct {
+	KeyID            *int64     `json:"key_id,omitempty" gorm:"column:key_id"`
+	KeyContent       *string    `json:"key_content,omitempty" gorm:"column:key_content"`
+	TeamID           *int64     `json:"team_id,omitempty" gorm:"column:team_id"`
+	OrganizationID   *int64     `json:"organization_id,omitempty" gorm:"column:organization_id"`
+	ModelGroup       *string    `json:"model_group,omitempty" gorm:"column:model_group"`
+	Provider         *string    `json:"provider,omitempty" gorm:"column:provider"`
+	Period           *time.Time `json:"period,omitempty" gorm:"column:period"`
+	Spend            float64    `json:"spend" gorm:"column:spend"`
+	PromptTokens     int64      `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int64      `json:"completion_tokens" gorm:"column:completion_tokens"`
+	TotalTokens      int64      `json:"total_tokens" gorm:"column:total_tokens"`
+	RequestCount     int64      `json:"request_count" gorm:"column:request_count"`
+	AvgLatencyMS     float64    `json:"avg_latency_ms" gorm:"column:avg_latency_ms"`
+	CacheHitRatio    float64    `json:"cache_hit_ratio" gorm:"column:cache_hit_ratio"`
+}
+
+// dailyDimensions are the dimensions janus_key_spend_daily keeps.
+var dailyDimensions = map[string]struct{}{
+	GroupByKey:          {},
+	GroupByTeam:         {},
+	GroupByOrganization: {},
+	GroupByDay:          {},
+}
+
+// Validate checks the query and fills in the default page size.
+func (q *ReportQuery) Validate() error {
+	if q.Start.IsZero() || q.End.IsZero() || !q.Start.Before(q.End) {
+		return errors.New("start must be before end")
+	}
+	seen := make(map[string]struct{}, len(q.GroupBy))
+	for _, dimension := range q.GroupBy {
+		switch dimension {
+		case GroupByKey, GroupByTeam, GroupByOrganization, GroupByModelGroup, GroupByProvider, GroupByDay, GroupByHour:
+		default:
+			return fmt.Errorf("unsupported group_by %q", dimension)
+		}
+		if _, ok := seen[dimension]; ok {
+			return fmt.Errorf("duplicate group_by %q", dimension)
+		}
+		seen[dimension] = struct{}{}
+	}
+	_, byDay := seen[GroupByDay]
+	_, byHour := seen[GroupByHour]
+	if byDay && byHour {
+		return errors.New("group_by cannot contain both day and hour")
+	}
+	switch q.Source {
+	case ReportSourceAuto, ReportSourceLog:
+	case ReportSourceDaily:
+		if !q.dailyCapable() {
+			return errors.New("source=daily supports group_by key, team, organization and day, filters on key, team and organization, and whole UTC days")
+		}
+	default:
+		return fmt.Errorf("unsupported source %q", q.Source)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultReportLimit
+	}
+	if q.Limit > MaxReportLimit {
+		return fmt.Errorf("limit must not exceed %d", MaxReportLimit)
+	}
+	if q.Offset < 0 {
+		return errors.New("offset must be non-negative")
+	}
+	return nil
+}
+
+// UsesDailySummary reports whether the query is answered from janus_key_spend_daily.
+func (q ReportQuery) UsesDailySummary() bool {
+	switch q.Source {
+	case ReportSourceLog:
+		return false
+	case ReportSourceDaily:
+		return true
+	}
+	return q.dailyCapable()
+}
+
+func (q ReportQuery) dailyCapable() bool {
+	if q.ModelGroup != "" || q.Provider != "" || !isUTCDay(q.Start) || !isUTCDay(q.End) {
+		return false
+	}
+	for _, dimension := range q.GroupBy {
+		if _, ok := dailyDimensions[dimension]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func isUTCDay(t time.Time) bool {
+	utc := t.UTC()
+	return utc.Equal(time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// QuerySpendReport returns one page of the report and the number of groups in it.
+func QuerySpendReport(db *gorm.DB, q ReportQuery) ([]ReportRow, int64, error) {
+	query, order := q.build(db)
+
+	var total int64
+	if err := db.Table("(?) AS report", query).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	rows := make([]ReportRow, 0)
+	if err := query.Order(order).Limit(q.Limit).Offset(q.Offset).Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+func (q ReportQuery) build(db *gorm.DB) (*gorm.DB, string) {
+	daily := q.UsesDailySummary()
+
+	var selects, groups []string
+	for _, dimension := range q.GroupBy {
+		switch dimension {
+		case GroupByKey:
+			selects = append(selects, "key_id", "MAX(key_content) AS key_content")
+			groups = append(groups, "key_id")
+		case GroupByTeam:
+			selects = append(selects, "team_id")
+			groups = append(groups, "team_id")
+		case GroupByOrganization:
+			selects = append(selects, "organization_id")
+			groups = append(groups, "organization_id")
+		case GroupByModelGroup:
+			selects = append(selects, "model_group")
+			groups = append(groups, "model_group")
+		case GroupByProvider:
+			selects = append(selects, "provider")
+			groups = append(groups, "provider")
+		case GroupByDay:
+			if daily {
+				selects = append(selects, "summary_date::timestamp AS period")
+			} else {
+				selects = append(selects, "date_trunc('day', create_time AT TIME ZONE 'UTC') AS period")
+			}
+			groups = append(groups, "period")
+		case GroupByHour:
+			selects = append(selects, "date_trunc('hour', create_time AT TIME ZONE 'UTC') AS period")
+			groups = append(groups, "period")
+		}
+	}
+
+	var query *gorm.DB
+	if daily {
+		selects = append(selects,
+			"COALESCE(SUM(total_spend), 0) AS spend",
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens",
+			"COALESCE(SUM(completion_tokens), 0) AS completion_tokens",
+			"COALESCE(SUM(total_tokens), 0) AS total_tokens",
+			"COALESCE(SUM(request_count), 0) AS request_count",
+			"COALESCE(SUM(total_latency_ms)::float8 / NULLIF(SUM(request_count), 0), 0) AS avg_latency_ms",
+			"COALESCE(SUM(cache_hit_count)::float8 / NULLIF(SUM(request_count), 0), 0) AS cache_hit_ratio",
+		)
+		query = db.Table("janus_key_spend_daily").
+			Where("summary_date >= ? AND summary_date < ?", q.Start.UTC().Format(time.DateOnly), q.End.UTC().Format(time.DateOnly))
+	} else {
+		selects = append(selects,
+			"COALESCE(SUM(spend), 0) AS spend",
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens",
+			"COALESCE(SUM(completion_tokens), 0) AS completion_tokens",
+			"COALESCE(SUM(total_tokens), 0) AS total_tokens",
+			"COUNT(*) AS request_count",
+			"COALESCE(AVG(latency_ms), 0) AS avg_latency_ms",
+			"COALESCE(AVG(CASE WHEN cache_hit THEN 1.0 ELSE 0.0 END), 0) AS cache_hit_ratio",
+		)
+		query = db.Table("janus_spend_log").Where("create_time >= ? AND create_time < ?", q.Start, q.End)
+		if q.ModelGroup != "" {
+			query = query.Where("model_group = ?", q.ModelGroup)
+		}
+		if q.Provider != "" {
+			query = query.Where("provider = ?", q.Provider)
+		}
+	}
+	if q.KeyID > 0 {
+		query = query.Where("key_id = ?", q.KeyID)
+	}
+	if q.TeamID > 0 {
+		query = query.Where("team_id = ?", q.TeamID)
+	}
+	if q.OrganizationID > 0 {
+		query = query.Where("organization_id = ?", q.OrganizationID)
+	}
+
+	query = query.Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		query = query.Group(strings.Join(groups, ", "))
+	}
+	// A new session lets the count and the page reuse the query.
+	return query.Session(&gorm.Session{}), reportOrder(groups)
+}
+
+// reportOrder lists time buckets chronologically and other groups by spend, with the group
+// columns as a tie-breaker so pages are stable.
+func reportOrder(groups []string) string {
+	order := make([]string, 0, len(groups)+1)
+	for _, group := range groups {
+		if group == "period" {
+			order = append(order, "period")
+		}
+	}
+	order = append(order, "spend DESC")
+	for _, group := range groups {
+		if group != "period" {
+			order = append(order, group)
+		}
+	}
+	return strings.Join(order, ", ")
+}
+
+// ListSpendRecords returns one page of raw spend records matching q's range and filters,
+// newest first, and the number of matching records.
+func ListSpendRecords(db *gorm.DB, q ReportQuery) ([]SpendRecord, int64, error) {
+	query := db.Table("janus_spend_log").Where("create_time >= ? AND create_time < ?", q.Start, q.End)
+	if q.KeyID > 0 {
+		query = query.Where("key_id = ?", q.KeyID)
+	}
+	if q.TeamID > 0 {
+		query = query.Where("team_id = ?", q.TeamID)
+	}
+	if q.OrganizationID > 0 {
+		query = query.Where("organization_id = ?", q.OrganizationID)
+	}
+	if q.ModelGroup != "" {
+		query = query.Where("model_group = ?", q.ModelGroup)
+	}
+	if q.Provider != "" {
+		query = query.Where("provider = ?", q.Provider)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	records := make([]SpendRecord, 0)
+	if err := query.Order("create_time DESC, record_id DESC").Limit(q.Limit).Offset(q.Offset).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// GetKeyDailySpend returns keyID's spend per UTC day in [start, end), oldest first. Days
+// without spend are omitted. It reads the spend log rather than janus_key_spend_daily so
+// today's total does not trail behind the summary refresh.
+func GetKeyDailySpend(keyID int, start, end time.Time) ([]ReportRow, error) {
+	q := keyDailySpendQuery(keyID, start, end)
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	db, err := janusDb.ConnectDatabase()
+	if err != nil {
+		log.Printf("GetKeyDailySpend: connect database failed: %v", err)
+		return nil, err
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	rows, _, err := QuerySpendReport(db, q)
+	if err != nil {
+		log.Printf("GetKeyDailySpend: query failed: %v", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+func keyDailySpendQuery(keyID int, start, end time.Time) ReportQuery {
+	return ReportQuery{
+		Start:   start,
+		End:     end,
+		GroupBy: []string{GroupByDay},
+		KeyID:   int64(keyID),
+		Limit:   MaxReportLimit,
+		Source:  ReportSourceLog,
+	}
+}
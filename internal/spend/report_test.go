@@ -0,0 +1,133 @@
+package spend
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 dbname=janus"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run database: %v", err)
+	}
+	return db
+}
+
+func reportSQL(t *testing.T, q ReportQuery) string {
+	t.Helper()
+	if err := q.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	db := dryRunDB(t)
+	query, order := q.build(db)
+	stmt := query.Order(order).Limit(q.Limit).Offset(q.Offset).Find(&[]ReportRow{}).Statement
+	return stmt.SQL.String()
+}
+
+func TestReportQueryValidate(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	for name, q := range map[string]ReportQuery{
+		"reversed range":  {Start: end, End: start},
+		"unknown group":   {Start: start, End: end, GroupBy: []string{"region"}},
+		"duplicate group": {Start: start, End: end, GroupBy: []string{"key", "key"}},
+		"day and hour":    {Start: start, End: end, GroupBy: []string{"day", "hour"}},
+		"limit too large": {Start: start, End: end, Limit: MaxReportLimit + 1},
+		"daily by model":  {Start: start, End: end, GroupBy: []string{"model_group"}, Source: ReportSourceDaily},
+		"unknown source":  {Start: start, End: end, Source: "cache"},
+	} {
+		if err := q.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	q := ReportQuery{Start: start, End: end}
+	if err := q.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.Limit != DefaultReportLimit {
+		t.Fatalf("expected default limit %d, got %d", DefaultReportLimit, q.Limit)
+	}
+}
+
+func TestReportQueryChoosesDailySummary(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+
+	if !(ReportQuery{Start: start, End: end, GroupBy: []string{"key", "day"}, TeamID: 3}).UsesDailySummary() {
+		t.Fatal("expected whole days grouped by key and day to use the daily summary")
+	}
+	for name, q := range map[string]ReportQuery{
+		"hour bucket":     {Start: start, End: end, GroupBy: []string{"hour"}},
+		"provider filter": {Start: start, End: end, Provider: "openai"},
+		"partial day":     {Start: start.Add(time.Hour), End: end},
+		"forced log":      {Start: start, End: end, Source: ReportSourceLog},
+	} {
+		if q.UsesDailySummary() {
+			t.Errorf("%s: expected the spend log to be used", name)
+		}
+	}
+}
+
+func TestSpendReportSQL(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	sql := reportSQL(t, ReportQuery{
+		Start:      start,
+		End:        start.Add(36 * time.Hour),
+		GroupBy:    []string{"model_group", "hour"},
+		ModelGroup: "gpt",
+	})
+	for _, want := range []string{
+		`FROM "janus_spend_log"`,
+		"date_trunc('hour', create_time AT TIME ZONE 'UTC') AS period",
+		"model_group = $3",
+		"GROUP BY model_group, period",
+		"ORDER BY period, spend DESC, model_group",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected %q in %s", want, sql)
+		}
+	}
+
+	sql = reportSQL(t, ReportQuery{Start: start, End: start.AddDate(0, 0, 7), GroupBy: []string{"organization"}, Limit: 10, Offset: 20})
+	for _, want := range []string{
+		`FROM "janus_key_spend_daily"`,
+		"SUM(total_spend)",
+		`GROUP BY "organization_id"`,
+		"ORDER BY spend DESC, organization_id",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected %q in %s", want, sql)
+		}
+	}
+}
+
+func TestKeyDailySpendQueryReadsSpendLog(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	q := keyDailySpendQuery(7, start, start.AddDate(0, 0, 7))
+	if q.UsesDailySummary() {
+		t.Fatal("expected the key daily breakdown to bypass janus_key_spend_daily")
+	}
+	if sql := reportSQL(t, q); !strings.Contains(sql, `FROM "janus_spend_log"`) {
+		t.Fatalf("expected the spend log in %s", sql)
+	}
+}
+
+func TestRecentSummaryDatesAreYesterdayAndToday(t *testing.T) {
+	now := time.Date(2026, 10, 17, 0, 30, 0, 0, time.FixedZone("UTC+8", 8*60*60))
+	got := recentSummaryDates(now)
+	want := []string{"2026-10-15", "2026-10-16"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
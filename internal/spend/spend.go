@@ -4,17 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	"github.com/Uuq114/JanusLLM/internal/auth"
-	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+	"github.com/Uuq114/JanusLLM/internal/metrics"
+	"github.com/Uuq114/JanusLLM/internal/models"
 )
 
 var (
-	ModelPrice = map[string][]float64{} // model group -> [input token price, output token price]
+	ModelPrice   = map[string][]float64{} // model group -> [input token price, output token price]
+	modelPriceMu sync.RWMutex
 )
 
 const (
@@ -23,33 +25,66 @@ const (
 	ContextUpstream      = "upstream"
 	ContextUpstreamModel = "upstreamModel"
 	ContextLatencyMS     = "latency_ms"
-	ContextCacheHit      = "cache_hit"
-	ContextSpend         = "spend"
+	// ContextCacheHit marks a response replayed from the gateway cache. Upstream prompt
+	// cache hits are read from the cached token count instead.
+	ContextCacheHit = "cache_hit"
+	// ContextCredential is the redacted provider key that served the request.
+	ContextCredential = "credential"
+	ContextSpend      = "spend"
+	// ContextServedModelGroup is set when a fallback group served the request instead of the
+	// requested one; spend is priced and recorded against it.
+	ContextServedModelGroup = "servedModelGroup"
+	// ContextCostMultiplier scales the computed spend, e.g. for gateway cache hits.
+	ContextCostMultiplier = "cost_multiplier"
+	// ContextPricing holds the serving endpoint's *models.Pricing when it has one.
+	ContextPricing = "pricing"
 )
 
 type SpendRecord struct {
-	RecordId         int       `gorm:"primaryKey;column:record_id"`
-	RequestId        string    `gorm:"column:request_id"`
-	KeyId            int       `gorm:"column:key_id"`
-	KeyContent       string    `gorm:"column:key_content"`
-	TeamId           int       `gorm:"column:team_id"`
-	OrganizationId   int       `gorm:"column:organization_id"`
-	Tenant           string    `gorm:"column:tenant"`
-	ModelGroup       string    `gorm:"column:model_group"`
-	Provider         string    `gorm:"column:provider"`
-	LatencyMS        int64     `gorm:"column:latency_ms"`
-	CacheHit         bool      `gorm:"column:cache_hit"`
-	Spend            float64   `gorm:"column:spend"`
-	TotalTokens      int       `gorm:"column:total_tokens"`
-	PromptTokens     int       `gorm:"column:prompt_tokens"`
-	CompletionTokens int       `gorm:"column:completion_tokens"`
-	CreateTime       time.Time `gorm:"column:create_time"`
+	RecordId         int     `gorm:"primaryKey;column:record_id" json:"record_id"`
+	RequestId        string  `gorm:"column:request_id" json:"request_id"`
+	KeyId            int     `gorm:"column:key_id" json:"key_id"`
+	KeyContent       string  `gorm:"column:key_content" json:"key_content"`
+	TeamId           int     `gorm:"column:team_id" json:"team_id"`
+	OrganizationId   int     `gorm:"column:organization_id" json:"organization_id"`
+	Tenant           string  `gorm:"column:tenant" json:"tenant"`
+	ModelGroup       string  `gorm:"column:model_group" json:"model_group"`
+	Provider         string  `gorm:"column:provider" json:"provider"`
+	Credential       string  `gorm:"column:credential" json:"credential"`
+	LatencyMS        int64   `gorm:"column:latency_ms" json:"latency_ms"`
+	CacheHit         bool    `gorm:"column:cache_hit" json:"cache_hit"`
+	Spend            float64 `gorm:"column:spend" json:"spend"`
+	TotalTokens      int     `gorm:"column:total_tokens" json:"total_tokens"`
+	PromptTokens     int     `gorm:"column:prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int     `gorm:"column:completion_tokens" json:"completion_tokens"`
+	// The detail counts mirror TokenUsage and are zero when the upstream did not report them.
+	CachedTokens        int `gorm:"column:cached_tokens" json:"cached_tokens"`
+	CacheCreationTokens int `gorm:"column:cache_creation_tokens" json:"cache_creation_tokens"`
+	ReasoningTokens     int `gorm:"column:reasoning_tokens" json:"reasoning_tokens"`
+	ImageInputTokens    int `gorm:"column:image_input_tokens" json:"image_input_tokens"`
+	ImageOutputTokens   int `gorm:"column:image_output_tokens" json:"image_output_tokens"`
+	AudioInputTokens    int `gorm:"column:audio_input_tokens" json:"audio_input_tokens"`
+	AudioOutputTokens   int `gorm:"column:audio_output_tokens" json:"audio_output_tokens"`
+	// PriceBreakdown lists the rates applied to each kind of token.
+	PriceBreakdown PriceBreakdown `gorm:"column:price_breakdown" json:"price_breakdown"`
+	// SpoolId identifies the record across spool replays so it is written only once.
+	SpoolId    string    `gorm:"column:spool_id" json:"spool_id"`
+	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
 }
 
 type TokenUsage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// The detail counts below are the parts of PromptTokens and CompletionTokens that
+	// endpoint pricing can bill at their own rates.
+	CachedTokens        int `json:"cached_tokens,omitempty"`
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"`
+	ReasoningTokens     int `json:"reasoning_tokens,omitempty"`
+	ImageInputTokens    int `json:"image_input_tokens,omitempty"`
+	ImageOutputTokens   int `json:"image_output_tokens,omitempty"`
+	AudioInputTokens    int `json:"audio_input_tokens,omitempty"`
+	AudioOutputTokens   int `json:"audio_output_tokens,omitempty"`
 }
 
 type UpstreamResp struct {
@@ -60,8 +95,8 @@ type UpstreamResp struct {
 	Usage      TokenUsage `json:"usage"`
 }
 
-func CreateSpendRecord(c *gin.Context, ch chan<- SpendRecord) {
-	if ch == nil {
+func CreateSpendRecord(c *gin.Context, sink SpendSink) {
+	if sink == nil {
 		return
 	}
 	resp, exists := c.Get(ContextUpstreamResp)
@@ -86,81 +121,94 @@ func CreateSpendRecord(c *gin.Context, ch chan<- SpendRecord) {
 	}
 
 	key := c.MustGet("key").(auth.Key)
-	model := c.MustGet("modelGroup").(string)
-	price, ok := ModelPrice[model]
-	if !ok || len(price) < 2 {
+	model := ServedModelGroup(c)
+	pricing, endpoint, ok := requestPricing(c, model)
+	if !ok {
 		log.Printf("CreateSpendRecord: missing model price config for model group: %s", model)
 		return
 	}
 
-	spend := price[0]*float64(upstreamResp.Usage.PromptTokens) + price[1]*float64(upstreamResp.Usage.CompletionTokens)
+	spend, breakdown := PriceUsage(pricing, upstreamResp.Usage)
+	breakdown.Endpoint = endpoint
+	if multiplier, ok := c.Get(ContextCostMultiplier); ok {
+		if factor, ok := multiplier.(float64); ok {
+			spend = breakdown.scale(factor)
+		}
+	}
+	usage := upstreamResp.Usage
 	record := SpendRecord{
 		RequestId:        upstreamResp.Id,
 		KeyId:            key.KeyId,
 		KeyContent:       auth.RedactKeyContent(key.KeyContent),
 		TeamId:           key.TeamId,
 		OrganizationId:   key.OrganizationId,
-		Tenant:           tenantFromKey(key),
+		Tenant:           TenantFromKey(key),
 		ModelGroup:       model,
 		Provider:         stringContext(c, ContextProvider),
+		Credential:       stringContext(c, ContextCredential),
 		LatencyMS:        int64Context(c, ContextLatencyMS),
-		CacheHit:         boolContext(c, ContextCacheHit),
+		CacheHit:         boolContext(c, ContextCacheHit) || usage.CachedTokens > 0,
 		Spend:            spend,
-		TotalTokens:      upstreamResp.Usage.TotalTokens,
-		PromptTokens:     upstreamResp.Usage.PromptTokens,
-		CompletionTokens: upstreamResp.Usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+
+		CachedTokens:        usage.CachedTokens,
+		CacheCreationTokens: usage.CacheCreationTokens,
+		ReasoningTokens:     usage.ReasoningTokens,
+		ImageInputTokens:    usage.ImageInputTokens,
+		ImageOutputTokens:   usage.ImageOutputTokens,
+		AudioInputTokens:    usage.AudioInputTokens,
+		AudioOutputTokens:   usage.AudioOutputTokens,
+		PriceBreakdown:      breakdown,
 	}
 	c.Set(ContextSpend, spend)
-	ch <- record
+	metrics.AddUsage(model, record.Provider, record.PromptTokens, record.CompletionTokens, spend)
+	if err := sink.Enqueue(record); err != nil {
+		log.Printf("CreateSpendRecord: %v; record kept in memory until written", err)
+	}
 }
 
-func InsertBatchSpendRecord(records []SpendRecord) {
-	db, err := janusDb.ConnectDatabase()
-	if err != nil {
-		log.Printf("InsertBatchSpendRecord: connect database failed: %v", err)
-		return
-	}
-	defer janusDb.CloseDatabaseConnection(db)
+// SetModelPrices replaces the price table, e.g. after a config reload.
+func SetModelPrices(prices map[string][]float64) {
+	modelPriceMu.Lock()
+	defer modelPriceMu.Unlock()
+	ModelPrice = prices
+}
 
-	if err := db.Table("janus_spend_log").Omit("create_time").Create(&records).Error; err != nil {
-		log.Printf("InsertBatchSpendRecord: insert failed: %v", err)
-	}
+func modelPrice(modelGroup string) ([]float64, bool) {
+	modelPriceMu.RLock()
+	defer modelPriceMu.RUnlock()
+	price, ok := ModelPrice[modelGroup]
+	return price, ok
 }
 
-func GetRangeSpendRecords(startTime time.Time, endTime time.Time) ([]SpendRecord, error) {
-	db, err := janusDb.ConnectDatabase()
-	if err != nil {
-		log.Printf("GetRangeSpendRecords: connect database failed: %v", err)
-		return nil, err
+// requestPricing returns the serving endpoint's pricing and name when it has pricing, or the
+// model group's input and output prices.
+func requestPricing(c *gin.Context, modelGroup string) (models.Pricing, string, bool) {
+	if value, ok := c.Get(ContextPricing); ok {
+		if pricing, ok := value.(*models.Pricing); ok && pricing != nil {
+			return *pricing, stringContext(c, ContextUpstream), true
+		}
 	}
-	defer janusDb.CloseDatabaseConnection(db)
-
-	var records []SpendRecord
-	if err := db.Table("janus_spend_log").Where("create_time BETWEEN ? AND ?", startTime, endTime).Find(&records).Error; err != nil {
-		log.Printf("GetRangeSpendRecords: query failed: %v", err)
-		return nil, err
+	price, ok := modelPrice(modelGroup)
+	if !ok || len(price) < 2 {
+		return models.Pricing{}, "", false
 	}
-
-	return records, nil
+	return models.Pricing{PriceRates: models.PriceRates{InputPerToken: price[0], OutputPerToken: price[1]}}, "", true
 }
 
-func CreateKeySpendRecord(c *gin.Context, ch chan<- float64) {
-	if ch == nil {
-		return
+// ServedModelGroup returns the model group that served the request, which differs from the
+// requested group after a fallback.
+func ServedModelGroup(c *gin.Context) string {
+	if served := stringContext(c, ContextServedModelGroup); served != "" {
+		return served
 	}
-	spendValue, exists := c.Get(ContextSpend)
-	if !exists {
-		return
-	}
-	spendAmount, ok := spendValue.(float64)
-	if !ok {
-		log.Printf("CreateKeySpendRecord: spend type mismatch: %T", spendValue)
-		return
-	}
-	ch <- spendAmount
+	return c.GetString("modelGroup")
 }
 
-func tenantFromKey(key auth.Key) string {
+// TenantFromKey identifies the organization and team a key belongs to.
+func TenantFromKey(key auth.Key) string {
 	return fmt.Sprintf("org:%d/team:%d", key.OrganizationId, key.TeamId)
 }
 
@@ -205,21 +253,3 @@ func boolContext(c *gin.Context, key string) bool {
 	}
 	return typed
 }
-
-func UpdateKeySpendRecord(totalSpend float64, keyID int) {
-	db, err := janusDb.ConnectDatabase()
-	if err != nil {
-		log.Printf("UpdateKeySpendRecord: connect database failed: %v", err)
-		return
-	}
-	defer janusDb.CloseDatabaseConnection(db)
-
-	if err := db.Table("janus_auth_key").
-		Where("key_id = ?", keyID).
-		Updates(map[string]interface{}{
-			"balance":     gorm.Expr("balance - ?", totalSpend),
-			"total_spend": gorm.Expr("total_spend + ?", totalSpend),
-		}).Error; err != nil {
-		log.Printf("UpdateKeySpendRecord: update failed: %v", err)
-	}
-}
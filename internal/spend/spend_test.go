@@ -2,6 +2,7 @@ package spend
 
 import (
 	"encoding/json"
+	"math"
 	"net/http/httptest"
 	"testing"
 
@@ -9,46 +10,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func TestCreateKeySpendRecordSkipsWhenSpendMissing(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
-	rec := httptest.NewRecorder()
-	ctx, _ := gin.CreateTestContext(rec)
-	ch := make(chan float64, 1)
-
-	defer func() {
-		if r := recover(); r != nil {
-			t.Fatalf("expected no panic when spend context is missing, got %v", r)
-		}
-	}()
-
-	CreateKeySpendRecord(ctx, ch)
+// chanSink adapts a channel to SpendSink for inspecting enqueued records.
+type chanSink chan SpendRecord
 
-	select {
-	case got := <-ch:
-		t.Fatalf("expected no spend to be enqueued, got %v", got)
-	default:
-	}
-}
-
-func TestCreateKeySpendRecordEnqueuesSpend(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
-	rec := httptest.NewRecorder()
-	ctx, _ := gin.CreateTestContext(rec)
-	ctx.Set(ContextSpend, 1.25)
-	ch := make(chan float64, 1)
-
-	CreateKeySpendRecord(ctx, ch)
-
-	select {
-	case got := <-ch:
-		if got != 1.25 {
-			t.Fatalf("expected spend 1.25, got %v", got)
-		}
-	default:
-		t.Fatalf("expected spend to be enqueued")
-	}
+func (s chanSink) Enqueue(record SpendRecord) error {
+	s <- record
+	return nil
 }
 
 func TestCreateSpendRecordEnqueuesBillingMetadata(t *testing.T) {
@@ -85,7 +52,7 @@ func TestCreateSpendRecordEnqueuesBillingMetadata(t *testing.T) {
 	ctx.Set(ContextUpstreamResp, payload)
 	ch := make(chan SpendRecord, 1)
 
-	CreateSpendRecord(ctx, ch)
+	CreateSpendRecord(ctx, chanSink(ch))
 
 	select {
 	case got := <-ch:
@@ -120,7 +87,7 @@ func TestCreateSpendRecordSkipsZeroTokenUsage(t *testing.T) {
 	ctx.Set(ContextUpstreamResp, []byte(`{"id":"req-123","usage":{}}`))
 	ch := make(chan SpendRecord, 1)
 
-	CreateSpendRecord(ctx, ch)
+	CreateSpendRecord(ctx, chanSink(ch))
 
 	select {
 	case got := <-ch:
@@ -131,3 +98,86 @@ func TestCreateSpendRecordSkipsZeroTokenUsage(t *testing.T) {
 		t.Fatalf("expected key spend context not to be set")
 	}
 }
+
+func TestCreateSpendRecordAppliesCostMultiplier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	originalPrice := ModelPrice
+	ModelPrice = map[string][]float64{"chat-group": {0.01, 0.02}}
+	t.Cleanup(func() { ModelPrice = originalPrice })
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Set("key", auth.Key{KeyId: 42, KeyContent: "sk-abcdef123456", TeamId: 7, OrganizationId: 3})
+	ctx.Set("modelGroup", "chat-group")
+	ctx.Set(ContextCacheHit, true)
+	ctx.Set(ContextCostMultiplier, 0.0)
+	ctx.Set(ContextUpstreamResp, []byte(`{"id":"req-123","usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+	ch := make(chan SpendRecord, 1)
+
+	CreateSpendRecord(ctx, chanSink(ch))
+
+	select {
+	case got := <-ch:
+		if got.Spend != 0 || !got.CacheHit || got.TotalTokens != 15 {
+			t.Fatalf("expected free cache hit with usage, got spend=%v cache_hit=%v tokens=%d", got.Spend, got.CacheHit, got.TotalTokens)
+		}
+	default:
+		t.Fatalf("expected spend record to be enqueued")
+	}
+}
+
+func TestCreateSpendRecordStoresUsageDetailsAndUpstreamCacheHits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	originalPrice := ModelPrice
+	ModelPrice = map[string][]float64{"chat-group": {0.01, 0.02}}
+	t.Cleanup(func() { ModelPrice = originalPrice })
+
+	ch := make(chan SpendRecord, 2)
+	for _, usage := range []string{
+		`{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15,"cached_tokens":6,"cache_creation_tokens":2,"reasoning_tokens":3,"audio_output_tokens":1}`,
+		`{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}`,
+	} {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set("key", auth.Key{KeyId: 42, KeyContent: "sk-abcdef123456"})
+		ctx.Set("modelGroup", "chat-group")
+		ctx.Set(ContextUpstreamResp, []byte(`{"id":"req-1","usage":`+usage+`}`))
+		CreateSpendRecord(ctx, chanSink(ch))
+	}
+
+	cached := <-ch
+	if !cached.CacheHit || cached.CachedTokens != 6 || cached.CacheCreationTokens != 2 || cached.ReasoningTokens != 3 || cached.AudioOutputTokens != 1 {
+		t.Fatalf("expected detail counts and an upstream cache hit, got %+v", cached)
+	}
+	if uncached := <-ch; uncached.CacheHit || uncached.CachedTokens != 0 {
+		t.Fatalf("expected no cache hit without cached tokens, got %+v", uncached)
+	}
+}
+
+func TestCreateSpendRecordPricesServedFallbackGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	originalPrice := ModelPrice
+	ModelPrice = map[string][]float64{"chat-group": {0.01, 0.02}, "fallback-group": {0.001, 0.002}}
+	t.Cleanup(func() { ModelPrice = originalPrice })
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Set("key", auth.Key{KeyId: 42, KeyContent: "sk-abcdef123456", TeamId: 7, OrganizationId: 3})
+	ctx.Set("modelGroup", "chat-group")
+	ctx.Set(ContextServedModelGroup, "fallback-group")
+	ctx.Set(ContextUpstreamResp, []byte(`{"id":"req-123","usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+	ch := make(chan SpendRecord, 1)
+
+	CreateSpendRecord(ctx, chanSink(ch))
+
+	select {
+	case got := <-ch:
+		if got.ModelGroup != "fallback-group" || math.Abs(got.Spend-0.02) > 1e-9 {
+			t.Fatalf("expected fallback group pricing, got model_group=%s spend=%v", got.ModelGroup, got.Spend)
+		}
+	default:
+		t.Fatalf("expected spend record to be enqueued")
+	}
+}
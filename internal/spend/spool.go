@@ -0,0 +1,352 @@
+package spend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	spoolSegmentPrefix = "spend-"
+	spoolSegmentExt    = ".wal"
+	// spoolRejectedExt marks records the database refused on their own; they are kept for
+	// inspection instead of blocking the segments behind them.
+	spoolRejectedExt = ".rejected"
+	// spoolBufferSize holds the appends of one commit; a fuller buffer is written early.
+	spoolBufferSize = 64 << 10
+)
+
+// spool is a write-ahead log of spend records on local disk. Records are appended as JSON
+// lines to the active segment; sealed segments are handed to the database writer oldest
+// first and deleted once written.
+//
+// Appends are group committed: records are buffered and a single committer goroutine
+// flushes and fsyncs everything buffered so far, so concurrent appends share one fsync
+// instead of queueing behind one each. An append returns once its record is on disk.
+type spool struct {
+	dir string
+
+	mu            sync.Mutex
+	active        *os.File
+	buf           *bufio.Writer
+	activeSeq     uint64
+	activeRecords int
+	sealed        []spoolSegment
+	nextSeq       uint64
+	records       int
+	bytes         int64
+	// pending collects the appends waiting for the next commit.
+	pending *spoolCommit
+	// closed is set once the committer has been stopped; later appends commit themselves.
+	closed bool
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// spoolCommit is one group of appends made durable by the same fsync.
+type spoolCommit struct {
+	records int
+	bytes   int64
+	done    chan struct{}
+	err     error
+}
+
+type spoolSegment struct {
+	seq     uint64
+	records int
+	bytes   int64
+}
+
+// openSpool opens dir, sealing every segment left by a previous process so it is replayed.
+func openSpool(dir string) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create spend spool %s: %w", dir, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read spend spool %s: %w", dir, err)
+	}
+
+	s := &spool{
+		dir:     dir,
+		nextSeq: 1,
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, entry := range entries {
+		seq, ok := parseSegmentName(entry.Name())
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read spend spool segment %s: %w", entry.Name(), err)
+		}
+		segment := spoolSegment{seq: seq, records: bytes.Count(data, []byte{'\n'}), bytes: int64(len(data))}
+		s.sealed = append(s.sealed, segment)
+		s.records += segment.records
+		s.bytes += segment.bytes
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(s.sealed, func(i, j int) bool { return s.sealed[i].seq < s.sealed[j].seq })
+	go s.commitLoop()
+	return s, nil
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, spoolSegmentPrefix) || !strings.HasSuffix(name, spoolSegmentExt) {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, spoolSegmentPrefix), spoolSegmentExt), 10, 64)
+	return seq, err == nil
+}
+
+func (s *spool) segmentPath(seq uint64, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%020d%s", spoolSegmentPrefix, seq, ext))
+}
+
+// append durably writes one record and returns how many records the active segment holds.
+func (s *spool) append(record SpendRecord) (int, error) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return 0, err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	if s.active == nil {
+		file, err := os.OpenFile(s.segmentPath(s.nextSeq, spoolSegmentExt), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			s.mu.Unlock()
+			return 0, err
+		}
+		s.active = file
+		s.buf = bufio.NewWriterSize(file, spoolBufferSize)
+		s.activeSeq = s.nextSeq
+		s.activeRecords = 0
+		s.nextSeq++
+	}
+	if _, err := s.buf.Write(line); err != nil {
+		s.sealLocked()
+		s.mu.Unlock()
+		return 0, err
+	}
+	if s.pending == nil {
+		s.pending = &spoolCommit{done: make(chan struct{})}
+	}
+	commit := s.pending
+	commit.records++
+	commit.bytes += int64(len(line))
+	s.activeRecords++
+	s.records++
+	s.bytes += int64(len(line))
+	count := s.activeRecords
+	closed := s.closed
+	s.mu.Unlock()
+
+	if closed {
+		s.commit()
+	} else {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	<-commit.done
+	if commit.err != nil {
+		return 0, commit.err
+	}
+	return count, nil
+}
+
+// commitLoop makes buffered appends durable until the spool is closed. Appends that arrive
+// while an fsync runs are committed together by the next one.
+func (s *spool) commitLoop() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.kick:
+			s.commit()
+		}
+	}
+}
+
+func (s *spool) commit() {
+	s.mu.Lock()
+	commit := s.pending
+	if commit == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.pending = nil
+	file := s.active
+	err := s.buf.Flush()
+	s.mu.Unlock()
+
+	if err == nil {
+		// The fsync runs without the lock so appends keep filling the next commit. A seal in
+		// the meantime syncs and closes the file itself, which covers this commit too.
+		if err = file.Sync(); errors.Is(err, os.ErrClosed) {
+			err = nil
+		}
+	}
+	if err != nil {
+		s.mu.Lock()
+		if s.active == file {
+			// A failed write may leave a partial line behind, so later records go to a new
+			// segment.
+			s.records -= commit.records
+			s.bytes -= commit.bytes
+			s.activeRecords -= commit.records
+			s.sealLocked()
+		}
+		s.mu.Unlock()
+	}
+	commit.err = err
+	close(commit.done)
+}
+
+// seal closes the active segment so the writer can pick it up.
+func (s *spool) seal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sealLocked()
+}
+
+// sealLocked flushes, syncs and closes the active segment, completing the pending commit.
+func (s *spool) sealLocked() {
+	if s.active == nil {
+		return
+	}
+	err := s.buf.Flush()
+	if err == nil {
+		err = s.active.Sync()
+	}
+	if err != nil {
+		log.Printf("spend spool: sync segment %d failed: %v", s.activeSeq, err)
+	}
+	if commit := s.pending; commit != nil {
+		s.pending = nil
+		if err != nil {
+			s.records -= commit.records
+			s.bytes -= commit.bytes
+			s.activeRecords -= commit.records
+		}
+		commit.err = err
+		close(commit.done)
+	}
+
+	info, statErr := s.active.Stat()
+	size := int64(0)
+	if statErr == nil {
+		size = info.Size()
+	}
+	if err := s.active.Close(); err != nil {
+		log.Printf("spend spool: close segment %d failed: %v", s.activeSeq, err)
+	}
+	s.sealed = append(s.sealed, spoolSegment{seq: s.activeSeq, records: s.activeRecords, bytes: size})
+	s.active = nil
+	s.buf = nil
+}
+
+func (s *spool) oldest() (spoolSegment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sealed) == 0 {
+		return spoolSegment{}, false
+	}
+	return s.sealed[0], true
+}
+
+// read decodes a sealed segment. A torn last line from a crash mid-write is skipped.
+func (s *spool) read(segment spoolSegment) ([]SpendRecord, error) {
+	file, err := os.Open(s.segmentPath(segment.seq, spoolSegmentExt))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []SpendRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record SpendRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			log.Printf("spend spool: skipping malformed record in segment %d: %v", segment.seq, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// remove deletes a segment whose records reached the database.
+func (s *spool) remove(segment spoolSegment) error {
+	if err := os.Remove(s.segmentPath(segment.seq, spoolSegmentExt)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sealed := range s.sealed {
+		if sealed.seq == segment.seq {
+			s.sealed = append(s.sealed[:i], s.sealed[i+1:]...)
+			s.records -= sealed.records
+			s.bytes -= sealed.bytes
+			break
+		}
+	}
+	return nil
+}
+
+// reject sets records aside next to the segment they came from.
+func (s *spool) reject(segment spoolSegment, records []SpendRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(s.segmentPath(segment.seq, spoolRejectedExt), buf.Bytes(), 0o600)
+}
+
+// backlog returns the records and bytes not yet written to the database.
+func (s *spool) backlog() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records, s.bytes
+}
+
+func (s *spool) close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sealLocked()
+}
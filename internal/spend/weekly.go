@@ -0,0 +1,121 @@
+package spend
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	janusDb "github.com/Uuq114/JanusLLM/internal/db"
+)
+
+// WeeklySpend tracks per-key spend for the current calendar week.
+var WeeklySpend = NewWeeklySpendTracker()
+
+// WeeklySpendTracker accumulates key spend for one calendar week (UTC, starting Monday).
+// A key is seeded from janus_spend_log when first seen and re-seeded on every key refresh,
+// so spend recorded by other replicas is picked up; in between it grows with queued spend.
+type WeeklySpendTracker struct {
+	mu        sync.Mutex
+	weekStart time.Time
+	spend     map[int]float64
+	seeded    map[int]struct{}
+}
+
+func NewWeeklySpendTracker() *WeeklySpendTracker {
+	return &WeeklySpendTracker{
+		spend:  make(map[int]float64),
+		seeded: make(map[int]struct{}),
+	}
+}
+
+// WeekStart returns the Monday 00:00 UTC that starts the week containing now.
+func WeekStart(now time.Time) time.Time {
+	utc := now.UTC()
+	daysSinceMonday := (int(utc.Weekday()) + 6) % 7
+	day := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -daysSinceMonday)
+}
+
+// NextWeekStart returns the time at which the weekly spend window containing now resets.
+func NextWeekStart(now time.Time) time.Time {
+	return WeekStart(now).AddDate(0, 0, 7)
+}
+
+func (t *WeeklySpendTracker) Seeded(keyID int, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked(now)
+	_, ok := t.seeded[keyID]
+	return ok
+}
+
+// Seed replaces the tracked spend for keyID. amount must cover both the spend persisted by
+// every replica and this replica's spend not flushed yet, since neither side includes all
+// of the other.
+func (t *WeeklySpendTracker) Seed(keyID int, amount float64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked(now)
+	t.spend[keyID] = amount
+	t.seeded[keyID] = struct{}{}
+}
+
+func (t *WeeklySpendTracker) Add(keyID int, amount float64, at time.Time) {
+	if amount <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked(at)
+	t.spend[keyID] += amount
+}
+
+// Spend returns the tracked weekly spend of keyID and whether it has been seeded this week.
+func (t *WeeklySpendTracker) Spend(keyID int, now time.Time) (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked(now)
+	_, seeded := t.seeded[keyID]
+	return t.spend[keyID], seeded
+}
+
+func (t *WeeklySpendTracker) rollLocked(now time.Time) {
+	weekStart := WeekStart(now)
+	if weekStart.Equal(t.weekStart) {
+		return
+	}
+	t.weekStart = weekStart
+	t.spend = make(map[int]float64)
+	t.seeded = make(map[int]struct{})
+}
+
+// SpendFromContext returns the spend computed by CreateSpendRecord for the current request.
+func SpendFromContext(c *gin.Context) (float64, bool) {
+	value, exists := c.Get(ContextSpend)
+	if !exists {
+		return 0, false
+	}
+	amount, ok := value.(float64)
+	return amount, ok
+}
+
+func GetKeySpendSince(keyID int, since time.Time) (float64, error) {
+	db, err := janusDb.ConnectDatabase()
+	if err != nil {
+		log.Printf("GetKeySpendSince: connect database failed: %v", err)
+		return 0, err
+	}
+	defer janusDb.CloseDatabaseConnection(db)
+
+	var total float64
+	if err := db.Table("janus_spend_log").
+		Select("COALESCE(SUM(spend), 0)").
+		Where("key_id = ? AND create_time >= ?", keyID, since).
+		Scan(&total).Error; err != nil {
+		log.Printf("GetKeySpendSince: query failed: %v", err)
+		return 0, err
+	}
+	return total, nil
+}
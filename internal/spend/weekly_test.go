@@ -0,0 +1,45 @@
+package spend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeekStartIsMondayUTC(t *testing.T) {
+	sunday := time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)
+	if got, want := WeekStart(sunday), time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	if got := WeekStart(monday); !got.Equal(monday) {
+		t.Fatalf("expected %v, got %v", monday, got)
+	}
+}
+
+func TestWeeklySpendTrackerSeedReplacesTrackedSpend(t *testing.T) {
+	tracker := NewWeeklySpendTracker()
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	tracker.Add(1, 3, now)
+	if _, seeded := tracker.Spend(1, now); seeded {
+		t.Fatal("expected key to be unseeded before Seed")
+	}
+	tracker.Seed(1, 2, now)
+	if got, seeded := tracker.Spend(1, now); !seeded || got != 2 {
+		t.Fatalf("expected seeded spend 2, got %v (seeded=%v)", got, seeded)
+	}
+	tracker.Add(1, 1.5, now)
+	if got, _ := tracker.Spend(1, now); got != 3.5 {
+		t.Fatalf("expected spend 3.5, got %v", got)
+	}
+	// Another replica's spend shows up in the database on the next refresh.
+	tracker.Seed(1, 9, now)
+	if got, _ := tracker.Spend(1, now); got != 9 {
+		t.Fatalf("expected re-seeded spend 9, got %v", got)
+	}
+
+	nextWeek := NextWeekStart(now)
+	if got, seeded := tracker.Spend(1, nextWeek); seeded || got != 0 {
+		t.Fatalf("expected tracker to reset next week, got %v (seeded=%v)", got, seeded)
+	}
+}
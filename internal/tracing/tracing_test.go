@@ -0,0 +1,32 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Fatalf("expected unknown exporter to be rejected")
+	}
+}
+
+func TestSetupWithoutExporterStillPropagatesTraceparent(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{})
+	if err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	defer shutdown(context.Background())
+
+	incoming := http.Header{}
+	incoming.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := Start(Extract(context.Background(), incoming), "janus.request")
+	defer span.End()
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	if got := outgoing.Get("traceparent"); len(got) < 35 || got[3:35] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected incoming trace id to be forwarded, got %q", got)
+	}
+}
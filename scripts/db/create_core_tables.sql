@@ -25,6 +25,7 @@ CREATE TABLE IF NOT EXISTS janus_auth_team (
   team_name TEXT NOT NULL UNIQUE,
   model_list TEXT NOT NULL DEFAULT '*',
   organization_id BIGINT NOT NULL REFERENCES janus_auth_organization(organization_id) ON DELETE RESTRICT,
+  tokens_per_minute INTEGER NOT NULL DEFAULT 0 CHECK (tokens_per_minute >= 0),
   create_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   update_time TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -50,6 +51,7 @@ CREATE TABLE IF NOT EXISTS janus_auth_key (
   total_spend NUMERIC(20, 8) NOT NULL DEFAULT 0,
   request_per_minute INTEGER NOT NULL DEFAULT 0 CHECK (request_per_minute >= 0),
   spend_limit_per_week NUMERIC(20, 8) NOT NULL DEFAULT 0,
+  tokens_per_minute INTEGER NOT NULL DEFAULT 0 CHECK (tokens_per_minute >= 0),
   create_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   update_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   expire_time TIMESTAMPTZ
@@ -73,6 +75,13 @@ CREATE TABLE IF NOT EXISTS janus_model_group (
   cost_per_input_token NUMERIC(20, 10) NOT NULL DEFAULT 0,
   cost_per_output_token NUMERIC(20, 10) NOT NULL DEFAULT 0,
   request_defaults JSONB NOT NULL DEFAULT '{}'::jsonb,
+  tokens_per_minute INTEGER NOT NULL DEFAULT 0 CHECK (tokens_per_minute >= 0),
+  cache_ttl_seconds INTEGER NOT NULL DEFAULT 0 CHECK (cache_ttl_seconds >= 0),
+  cache_hit_cost_ratio NUMERIC(10, 6) NOT NULL DEFAULT 0 CHECK (cache_hit_cost_ratio >= 0),
+  -- Retry settings: {"on", "backoff_ms", "max_backoff_ms", "timeout_seconds"}; NULL uses defaults.
+  retry_policy JSONB,
+  -- Comma-separated model groups tried in order when this group cannot serve a request.
+  fallbacks TEXT NOT NULL DEFAULT '',
   enabled BOOLEAN NOT NULL DEFAULT TRUE,
   create_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   update_time TIMESTAMPTZ NOT NULL DEFAULT NOW()
@@ -87,10 +96,21 @@ CREATE TABLE IF NOT EXISTS janus_model_endpoint (
   base_url TEXT NOT NULL,
   -- Use secret ref in production (k8s secret/config center), avoid plaintext key.
   api_key_secret_ref TEXT,
+  -- Additional secret refs rotated with api_key_secret_ref, comma separated.
+  api_key_secret_refs TEXT NOT NULL DEFAULT '',
   weight INTEGER NOT NULL DEFAULT 100 CHECK (weight > 0),
   timeout_seconds INTEGER NOT NULL DEFAULT 60 CHECK (timeout_seconds > 0),
+  -- Prompt plus completion token limit; 0 means unknown and never skipped.
+  context_window INTEGER NOT NULL DEFAULT 0 CHECK (context_window >= 0),
   retry_times INTEGER NOT NULL DEFAULT 1 CHECK (retry_times >= 0),
   skip_tls_verify BOOLEAN NOT NULL DEFAULT FALSE,
+  -- Skip adding stream_options.include_usage to streaming OpenAI-compatible requests.
+  disable_stream_usage BOOLEAN NOT NULL DEFAULT FALSE,
+  -- Active probe settings: {"path", "interval_seconds", "timeout_seconds", "expected_status"}.
+  health_check JSONB,
+  -- Endpoint pricing overriding the group's token costs: per-token rates for input, output,
+  -- cached input, cache writes, reasoning, image and audio tokens, plus context-length tiers.
+  pricing JSONB,
   enabled BOOLEAN NOT NULL DEFAULT TRUE,
   create_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
   update_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
@@ -114,12 +134,24 @@ CREATE TABLE IF NOT EXISTS janus_spend_log (
   tenant TEXT NOT NULL DEFAULT '',
   model_group TEXT NOT NULL,
   provider TEXT NOT NULL DEFAULT '',
+  credential TEXT NOT NULL DEFAULT '',
   latency_ms BIGINT NOT NULL DEFAULT 0,
   cache_hit BOOLEAN NOT NULL DEFAULT FALSE,
   spend NUMERIC(20, 8) NOT NULL DEFAULT 0,
   total_tokens INTEGER NOT NULL DEFAULT 0,
   prompt_tokens INTEGER NOT NULL DEFAULT 0,
   completion_tokens INTEGER NOT NULL DEFAULT 0,
+  -- Detail counts are parts of prompt_tokens and completion_tokens as reported upstream.
+  cached_tokens INTEGER NOT NULL DEFAULT 0,
+  cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+  reasoning_tokens INTEGER NOT NULL DEFAULT 0,
+  image_input_tokens INTEGER NOT NULL DEFAULT 0,
+  image_output_tokens INTEGER NOT NULL DEFAULT 0,
+  audio_input_tokens INTEGER NOT NULL DEFAULT 0,
+  audio_output_tokens INTEGER NOT NULL DEFAULT 0,
+  -- Rates applied to each kind of token: {"endpoint", "tier_above_prompt_tokens", "items"}.
+  price_breakdown JSONB,
+  spool_id TEXT NOT NULL DEFAULT '',
   create_time TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
@@ -130,29 +162,103 @@ ALTER TABLE janus_model_group
   ADD CONSTRAINT janus_model_group_strategy_check
   CHECK (strategy IN ('round-robin', 'weighted', 'least-inflight', 'latency-based', 'latency', 'client-sticky'));
 
+ALTER TABLE janus_model_group
+  ADD COLUMN IF NOT EXISTS tokens_per_minute INTEGER NOT NULL DEFAULT 0 CHECK (tokens_per_minute >= 0),
+  ADD COLUMN IF NOT EXISTS cache_ttl_seconds INTEGER NOT NULL DEFAULT 0 CHECK (cache_ttl_seconds >= 0),
+  ADD COLUMN IF NOT EXISTS cache_hit_cost_ratio NUMERIC(10, 6) NOT NULL DEFAULT 0 CHECK (cache_hit_cost_ratio >= 0),
+  ADD COLUMN IF NOT EXISTS retry_policy JSONB,
+  ADD COLUMN IF NOT EXISTS fallbacks TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE janus_model_endpoint
+  ADD COLUMN IF NOT EXISTS health_check JSONB,
+  ADD COLUMN IF NOT EXISTS api_key_secret_refs TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS context_window INTEGER NOT NULL DEFAULT 0 CHECK (context_window >= 0),
+  ADD COLUMN IF NOT EXISTS pricing JSONB,
+  ADD COLUMN IF NOT EXISTS disable_stream_usage BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE janus_auth_team
+  ADD COLUMN IF NOT EXISTS tokens_per_minute INTEGER NOT NULL DEFAULT 0 CHECK (tokens_per_minute >= 0);
+
+ALTER TABLE janus_auth_key
+  ADD COLUMN IF NOT EXISTS tokens_per_minute INTEGER NOT NULL DEFAULT 0 CHECK (tokens_per_minute >= 0);
+
 ALTER TABLE janus_spend_log
   ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '',
   ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT '',
   ADD COLUMN IF NOT EXISTS latency_ms BIGINT NOT NULL DEFAULT 0,
-  ADD COLUMN IF NOT EXISTS cache_hit BOOLEAN NOT NULL DEFAULT FALSE;
+  ADD COLUMN IF NOT EXISTS cache_hit BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS credential TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS spool_id TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS price_breakdown JSONB,
+  ADD COLUMN IF NOT EXISTS cached_tokens INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS reasoning_tokens INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS image_input_tokens INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS image_output_tokens INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS audio_input_tokens INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS audio_output_tokens INTEGER NOT NULL DEFAULT 0;
 
 CREATE INDEX IF NOT EXISTS idx_spend_log_create_time ON janus_spend_log (create_time);
 CREATE INDEX IF NOT EXISTS idx_spend_log_key_id ON janus_spend_log (key_id);
 CREATE INDEX IF NOT EXISTS idx_spend_log_team_time ON janus_spend_log (team_id, create_time);
 CREATE INDEX IF NOT EXISTS idx_spend_log_org_time ON janus_spend_log (organization_id, create_time);
+-- Spooled records replayed after a crash are skipped by spool_id instead of billed twice.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_spend_log_spool_id ON janus_spend_log (spool_id) WHERE spool_id <> '';
+
+-- Shared per-key request counters for service.rate_limit_backend=postgres.
+-- limit_key is a SHA-256 of the API key; rows older than one window are pruned by the gateway.
+CREATE TABLE IF NOT EXISTS janus_rate_limit_counter (
+  limit_key TEXT NOT NULL,
+  window_start TIMESTAMPTZ NOT NULL,
+  request_count INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (limit_key, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_counter_window ON janus_rate_limit_counter (window_start);
 
--- Optional summary table for faster dashboard query.
+-- Balance set aside for in-flight requests when service.balance_backend is postgres.
+CREATE TABLE IF NOT EXISTS janus_balance_hold (
+  hold_id BIGSERIAL PRIMARY KEY,
+  key_id BIGINT NOT NULL REFERENCES janus_auth_key(key_id) ON DELETE CASCADE,
+  amount NUMERIC(20, 8) NOT NULL CHECK (amount >= 0),
+  create_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  expire_time TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_hold_key_expire ON janus_balance_hold (key_id, expire_time);
+
+-- Per-key daily spend (UTC days) rebuilt from janus_spend_log by the gateway; answers
+-- /v1/admin/spend reports that only need key, team, organization and day.
 CREATE TABLE IF NOT EXISTS janus_key_spend_daily (
   summary_date DATE NOT NULL,
   key_id BIGINT NOT NULL REFERENCES janus_auth_key(key_id) ON DELETE RESTRICT,
   key_content TEXT NOT NULL,
+  team_id BIGINT NOT NULL DEFAULT 0,
   organization_id BIGINT NOT NULL REFERENCES janus_auth_organization(organization_id) ON DELETE RESTRICT,
   total_spend NUMERIC(20, 8) NOT NULL DEFAULT 0,
+  prompt_tokens BIGINT NOT NULL DEFAULT 0,
+  completion_tokens BIGINT NOT NULL DEFAULT 0,
   total_tokens BIGINT NOT NULL DEFAULT 0,
   request_count BIGINT NOT NULL DEFAULT 0,
+  total_latency_ms BIGINT NOT NULL DEFAULT 0,
+  cache_hit_count BIGINT NOT NULL DEFAULT 0,
   PRIMARY KEY (summary_date, key_id)
 );
 
+ALTER TABLE janus_key_spend_daily
+  ADD COLUMN IF NOT EXISTS team_id BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS prompt_tokens BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS completion_tokens BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS total_latency_ms BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS cache_hit_count BIGINT NOT NULL DEFAULT 0;
+
+-- Progress of incremental summaries: the last janus_spend_log record folded in.
+CREATE TABLE IF NOT EXISTS janus_summary_state (
+  summary_name TEXT PRIMARY KEY,
+  last_record_id BIGINT NOT NULL DEFAULT 0,
+  update_time TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 -- ------------------------
 -- Triggers for update_time
 -- ------------------------